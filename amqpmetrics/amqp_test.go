@@ -0,0 +1,73 @@
+package amqpmetrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/OkanUysal/go-metrics"
+	"github.com/OkanUysal/go-metrics/metricstest"
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// fakeAcknowledger records acknowledgements, failing them if err is set
+type fakeAcknowledger struct {
+	calls []string
+	err   error
+}
+
+func (a *fakeAcknowledger) Ack(tag uint64, multiple bool) error {
+	a.calls = append(a.calls, "ack")
+	return a.err
+}
+
+func (a *fakeAcknowledger) Nack(tag uint64, multiple, requeue bool) error {
+	a.calls = append(a.calls, "nack")
+	return a.err
+}
+
+func (a *fakeAcknowledger) Reject(tag uint64, requeue bool) error {
+	a.calls = append(a.calls, "reject")
+	return a.err
+}
+
+func TestDeliveries(t *testing.T) {
+	m := metrics.New(metrics.WithoutHTTPMetrics())
+	acks := &fakeAcknowledger{}
+
+	in := make(chan amqp.Delivery, 5)
+	for range 4 {
+		in <- amqp.Delivery{Acknowledger: acks}
+	}
+	// Deliveries of a closed channel have no acknowledger
+	in <- amqp.Delivery{}
+	close(in)
+
+	var received []amqp.Delivery
+	for d := range Deliveries(m.NewAMQPMetrics(), "orders", in) {
+		received = append(received, d)
+	}
+	if len(received) != 5 {
+		t.Fatalf("Expected 5 deliveries, got %d", len(received))
+	}
+	if received[4].Acknowledger != nil {
+		t.Error("Expected a missing acknowledger to stay nil")
+	}
+
+	received[0].Ack(false)
+	received[1].Nack(false, true)
+	received[2].Reject(false)
+	acks.err = errors.New("channel closed")
+	if err := received[3].Ack(false); err == nil {
+		t.Error("Expected the error of the acknowledger to be returned")
+	}
+
+	if len(acks.calls) != 4 {
+		t.Errorf("Expected acknowledgements to be passed on, got %v", acks.calls)
+	}
+	orders := metrics.MetricLabels{"queue": "orders"}
+	metricstest.AssertCounterValue(t, m, "amqp_messages_consumed_total", orders, 5)
+	// The failed Ack is not counted
+	metricstest.AssertCounterValue(t, m, "amqp_messages_acked_total", orders, 1)
+	metricstest.AssertCounterValue(t, m, "amqp_messages_nacked_total", metrics.MetricLabels{"queue": "orders", "requeue": "true"}, 1)
+	metricstest.AssertCounterValue(t, m, "amqp_messages_nacked_total", metrics.MetricLabels{"queue": "orders", "requeue": "false"}, 1)
+}
@@ -0,0 +1,311 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Signals of the HTTP metrics that AnomalyMetrics can watch besides metric
+// names
+const (
+	AnomalyHTTPErrorRate  = "http_error_rate"  // Fraction of requests answered with a 5xx status
+	AnomalyHTTPLatencyP95 = "http_latency_p95" // 95th percentile of the request duration
+)
+
+// anomalyWarmup is the number of samples a baseline needs before deviations
+// are reported
+const anomalyWarmup = 10
+
+// anomalyMinDeviation is the smallest standard deviation assumed for a
+// baseline, relative to its mean
+const anomalyMinDeviation = 0.01
+
+// anomalyDetector keeps an EWMA baseline of every watched signal
+type anomalyDetector struct {
+	mu        sync.RWMutex
+	alpha     float64
+	sigma     float64
+	baselines map[string]*ewma
+	anomalous map[string]bool
+
+	// Previous cumulative values, to turn counters into rates
+	totals  map[string]float64
+	buckets map[string]map[float64]uint64
+	last    time.Time
+}
+
+// ewma is an exponentially weighted moving average and variance
+type ewma struct {
+	mean, variance float64
+	samples        int
+}
+
+// StartAnomalyDetection samples the signals listed in AnomalyMetrics every
+// AnomalyInterval until ctx is cancelled. Each signal is compared with its
+// exponentially weighted moving average; while it deviates by more than
+// AnomalySigma standard deviations, metric_anomaly{metric} is 1 and
+// Anomalous reports true, e.g. to shed load. The deviation in standard
+// deviations is exported as metric_anomaly_score. It is started
+// automatically when AnomalyMetrics is set.
+//
+// Signals are AnomalyHTTPErrorRate, AnomalyHTTPLatencyP95 and metric names:
+// counters are watched as their rate per second, gauges as their value and
+// histograms as their 95th percentile, each summed or merged over all series.
+func (m *Metrics) StartAnomalyDetection(ctx context.Context) error {
+	if len(m.config.AnomalyMetrics) == 0 {
+		return fmt.Errorf("AnomalyMetrics is not configured")
+	}
+
+	interval := m.config.AnomalyInterval
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	d := m.newAnomalyDetector()
+
+	m.mu.Lock()
+	m.anomalies = d
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			m.detectAnomalies(d, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// newAnomalyDetector creates a detector without baselines
+func (m *Metrics) newAnomalyDetector() *anomalyDetector {
+	d := &anomalyDetector{
+		alpha:     m.config.AnomalyAlpha,
+		sigma:     m.config.AnomalySigma,
+		baselines: make(map[string]*ewma),
+		anomalous: make(map[string]bool),
+		totals:    make(map[string]float64),
+		buckets:   make(map[string]map[float64]uint64),
+	}
+	if d.alpha <= 0 || d.alpha > 1 {
+		d.alpha = 0.1
+	}
+	if d.sigma <= 0 {
+		d.sigma = 3
+	}
+	return d
+}
+
+// Anomalous reports whether a signal of AnomalyMetrics currently deviates
+// from its baseline
+func (m *Metrics) Anomalous(metric string) bool {
+	m.mu.RLock()
+	d := m.anomalies
+	m.mu.RUnlock()
+	if d == nil {
+		return false
+	}
+
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	return d.anomalous[metric]
+}
+
+// detectAnomalies gathers the registry, computes the watched signals and
+// updates their baselines and gauges
+func (m *Metrics) detectAnomalies(d *anomalyDetector, now time.Time) {
+	families, err := m.gatherer().Gather()
+	if err != nil {
+		m.logf("Failed to gather metrics for anomaly detection: %v", err)
+		// Gather returns everything it could collect despite errors
+	}
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, mf := range families {
+		byName[mf.GetName()] = mf
+	}
+
+	d.mu.Lock()
+	elapsed := now.Sub(d.last).Seconds()
+	if d.last.IsZero() {
+		elapsed = 0
+	}
+	d.last = now
+
+	type result struct {
+		metric    string
+		score     float64
+		anomalous bool
+	}
+	var results []result
+	for _, metric := range m.config.AnomalyMetrics {
+		value, ok := d.signal(m, metric, byName, elapsed)
+		if !ok {
+			continue
+		}
+		score, anomalous := d.baseline(metric).update(value, d.alpha, d.sigma)
+		d.anomalous[metric] = anomalous
+		results = append(results, result{metric, score, anomalous})
+	}
+	d.mu.Unlock()
+
+	for _, r := range results {
+		flag := 0.0
+		if r.anomalous {
+			flag = 1
+		}
+		labels := MetricLabels{"metric": r.metric}
+		m.SetGauge("metric_anomaly", flag, labels)
+		m.SetGauge("metric_anomaly_score", r.score, labels)
+	}
+}
+
+// signal computes the current value of a watched signal. It reports false
+// while there is nothing to compare, e.g. before a rate has two samples or
+// without requests. Must be called with d.mu held.
+func (d *anomalyDetector) signal(m *Metrics, metric string, families map[string]*dto.MetricFamily, elapsed float64) (float64, bool) {
+	fqName := func(name string) string {
+		return prometheus.BuildFQName(m.config.Namespace, m.config.Subsystem, name)
+	}
+
+	switch metric {
+	case AnomalyHTTPErrorRate:
+		mf := families[fqName("http_requests_total")]
+		if mf == nil {
+			return 0, false
+		}
+		var total, errors float64
+		for _, pm := range mf.GetMetric() {
+			total += pm.GetCounter().GetValue()
+			if serverError(labelMap(pm)) {
+				errors += pm.GetCounter().GetValue()
+			}
+		}
+		requests, okTotal := d.delta(metric+"\xfftotal", total)
+		failed, okErrors := d.delta(metric+"\xfferrors", errors)
+		if !okTotal || !okErrors || requests <= 0 {
+			return 0, false
+		}
+		return failed / requests, true
+
+	case AnomalyHTTPLatencyP95:
+		return d.quantile(metric, families[fqName("http_request_duration"+m.config.DurationUnit.suffix())])
+	}
+
+	mf := families[fqName(metric)]
+	if mf == nil {
+		mf = families[metric]
+	}
+	if mf == nil {
+		return 0, false
+	}
+
+	switch mf.GetType() {
+	case dto.MetricType_COUNTER:
+		var total float64
+		for _, pm := range mf.GetMetric() {
+			total += pm.GetCounter().GetValue()
+		}
+		increase, ok := d.delta(metric, total)
+		if !ok || elapsed <= 0 {
+			return 0, false
+		}
+		return increase / elapsed, true
+	case dto.MetricType_GAUGE:
+		var total float64
+		for _, pm := range mf.GetMetric() {
+			total += pm.GetGauge().GetValue()
+		}
+		return total, true
+	case dto.MetricType_HISTOGRAM:
+		return d.quantile(metric, mf)
+	}
+	return 0, false
+}
+
+// delta returns the increase of a cumulative value since the last call,
+// reporting false on the first call and after a reset
+func (d *anomalyDetector) delta(key string, total float64) (float64, bool) {
+	last, ok := d.totals[key]
+	d.totals[key] = total
+	if !ok || total < last {
+		return 0, false
+	}
+	return total - last, true
+}
+
+// quantile returns the 95th percentile of the observations of a histogram
+// since the last call
+func (d *anomalyDetector) quantile(metric string, mf *dto.MetricFamily) (float64, bool) {
+	if mf == nil {
+		return 0, false
+	}
+	current, value, ok := intervalQuantile(mf, d.buckets[metric], 0.95)
+	d.buckets[metric] = current
+	return value, ok
+}
+
+// baseline returns the baseline of a signal, creating it if needed
+func (d *anomalyDetector) baseline(metric string) *ewma {
+	b, ok := d.baselines[metric]
+	if !ok {
+		b = &ewma{}
+		d.baselines[metric] = b
+	}
+	return b
+}
+
+// update scores value against the baseline before adding it, returning its
+// deviation in standard deviations and whether it exceeds sigma. Values are
+// not anomalous until the baseline has anomalyWarmup samples.
+func (e *ewma) update(value, alpha, sigma float64) (float64, bool) {
+	if e.samples == 0 {
+		e.mean = value
+		e.samples++
+		return 0, false
+	}
+
+	// A floor keeps a perfectly flat signal from flagging tiny changes
+	diff := value - e.mean
+	stddev := max(math.Sqrt(e.variance), math.Abs(e.mean)*anomalyMinDeviation, 1e-9)
+	score := diff / stddev
+	warm := e.samples >= anomalyWarmup
+
+	e.mean += alpha * diff
+	e.variance = (1 - alpha) * (e.variance + alpha*diff*diff)
+	e.samples++
+
+	return score, warm && math.Abs(score) > sigma
+}
+
+// serverError reports whether the labels of a request series are those of a
+// 5xx response, whether the status is a code or a text
+func serverError(labels map[string]string) bool {
+	if class, ok := labels["status_class"]; ok {
+		return class == "5xx"
+	}
+	status := labels["status"]
+	if code, err := strconv.Atoi(status); err == nil {
+		return code >= 500 && code <= 599
+	}
+	for code := 500; code <= 599; code++ {
+		if text := http.StatusText(code); text != "" && text == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// backfillCounters holds the counters of IncrementCounterAt. Every series is
+// exposed with the timestamp of its latest event instead of the scrape time.
+type backfillCounters struct {
+	namespace   string
+	subsystem   string
+	constLabels prometheus.Labels
+
+	mu     sync.Mutex
+	descs  map[string]*prometheus.Desc
+	series map[string]*backfillSeries // By name and label values
+}
+
+// backfillSeries is the running total of one series
+type backfillSeries struct {
+	desc        *prometheus.Desc
+	labelValues []string
+	value       float64
+	at          time.Time
+}
+
+// IncrementCounterAt adds value to a counter for an event that happened at
+// the given time, e.g. while replaying a queue or importing historical
+// events. Such counters live in a separate namespace, ReplayNamespace, so
+// bursts of old events do not show up in rate() of the real-time counters;
+// orders_total replayed in namespace "myapp" becomes myapp_replay_orders_total.
+//
+// Series are exposed and pushed with the timestamp of their latest event, so
+// Prometheus places them at event time. Prometheus rejects samples older
+// than its out-of-order window, so backfills further back need
+// out_of_order_time_window set on the receiving end.
+func (m *Metrics) IncrementCounterAt(name string, value float64, at time.Time, labels MetricLabels) {
+	m.writeEvent(Event{Op: EventCounterAddAt, Name: name, Value: value, Labels: labels, At: at})
+	m.handleError(name, m.addCounterAt(name, value, at, labels))
+}
+
+// addCounterAt adds value to a backfill counter
+func (m *Metrics) addCounterAt(name string, value float64, at time.Time, labels MetricLabels) error {
+	if value < 0 {
+		return ErrNegativeCounter
+	}
+	labels, err := m.validateUpdate(labels, nil)
+	if err != nil {
+		return err
+	}
+	labelKeys := getLabelKeys(labels)
+
+	c, err := m.backfillCounters(name, labelKeys)
+	if err != nil {
+		return err
+	}
+
+	labelValues := make([]string, len(labelKeys))
+	for i, key := range labelKeys {
+		labelValues[i] = labels[key]
+	}
+	key := name + "\xff" + strings.Join(labelValues, "\xff")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, exists := c.series[key]
+	if !exists {
+		s = &backfillSeries{desc: c.descs[name], labelValues: labelValues}
+		c.series[key] = s
+	}
+	s.value += value
+	// Events may arrive out of order; the total is as of the latest one
+	if at.After(s.at) {
+		s.at = at
+	}
+	return nil
+}
+
+// backfillCounters returns the backfill counters of m, creating and
+// registering them on first use, after checking the label keys of name
+func (m *Metrics) backfillCounters(name string, labelKeys []string) (*backfillCounters, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.backfill == nil {
+		namespace := m.config.ReplayNamespace
+		if namespace == "" {
+			namespace = m.config.Namespace + "_replay"
+		}
+		c := &backfillCounters{
+			namespace:   namespace,
+			subsystem:   m.config.Subsystem,
+			constLabels: m.config.ConstLabels,
+			descs:       make(map[string]*prometheus.Desc),
+			series:      make(map[string]*backfillSeries),
+		}
+		if err := m.registry.Register(c); err != nil {
+			return nil, err
+		}
+		m.backfill = c
+	}
+	c := m.backfill
+
+	// Backfill names are tracked apart from the real-time metrics, which may
+	// use the same name with other labels
+	schemaKey := "replay:" + name
+	if schema, exists := m.schemas[schemaKey]; exists {
+		return c, checkSchema(schema, labelKeys)
+	}
+	metricName, err := m.metricName(name)
+	if err != nil {
+		return nil, err
+	}
+	if m.rejectFrozen(schemaKey) {
+		return nil, ErrFrozen
+	}
+	m.schemas[schemaKey] = labelKeys
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fqName := prometheus.BuildFQName(c.namespace, c.subsystem, metricName)
+	c.descs[name] = prometheus.NewDesc(fqName, name+" counter of replayed events", labelKeys, c.constLabels)
+	return c, nil
+}
+
+// Describe sends nothing, as backfill counters are created on demand
+func (c *backfillCounters) Describe(chan<- *prometheus.Desc) {}
+
+func (c *backfillCounters) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, s := range c.series {
+		metric := prometheus.MustNewConstMetric(s.desc, prometheus.CounterValue, s.value, s.labelValues...)
+		ch <- prometheus.NewMetricWithTimestamp(s.at, metric)
+	}
+}
@@ -0,0 +1,159 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// boundField is a struct field published by BindStruct
+type boundField struct {
+	name  string
+	kind  string // "gauge" or "counter"
+	value reflect.Value
+	last  float64
+}
+
+// BindStruct publishes the tagged fields of the struct pointed to by ptr
+// every interval until ctx is cancelled.
+//
+// Fields are selected with a `metric:"name,kind"` tag where kind is "gauge"
+// (the default) or "counter". Numeric, bool and time.Duration (as seconds)
+// fields are supported, as are types with a numeric Load() method such as
+// atomic.Int64. Counter fields must be monotonically increasing; a decrease
+// is treated as a reset.
+//
+// Fields are read without synchronization, so concurrently updated fields
+// should use atomic types.
+func (m *Metrics) BindStruct(ctx context.Context, ptr any, interval time.Duration) error {
+	rv := reflect.ValueOf(ptr)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("BindStruct requires a non-nil pointer to a struct, got %T", ptr)
+	}
+	if interval <= 0 {
+		interval = m.config.PushInterval
+	}
+
+	fields, err := bindFields(rv.Elem())
+	if err != nil {
+		return err
+	}
+	if len(fields) == 0 {
+		return fmt.Errorf("struct %T has no `metric` tagged fields", ptr)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// Publish immediately on start
+		m.publishFields(fields)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.publishFields(fields)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// bindFields collects the tagged fields of a struct value
+func bindFields(sv reflect.Value) ([]*boundField, error) {
+	st := sv.Type()
+	var fields []*boundField
+
+	for i := 0; i < st.NumField(); i++ {
+		sf := st.Field(i)
+		tag, ok := sf.Tag.Lookup("metric")
+		if !ok || tag == "-" {
+			continue
+		}
+		if !sf.IsExported() {
+			return nil, fmt.Errorf("field %s: tagged field must be exported", sf.Name)
+		}
+
+		name, kind, _ := strings.Cut(tag, ",")
+		if name == "" {
+			return nil, fmt.Errorf("field %s: metric name is required", sf.Name)
+		}
+		if kind == "" {
+			kind = "gauge"
+		}
+		if kind != "gauge" && kind != "counter" {
+			return nil, fmt.Errorf("field %s: unsupported metric kind %q", sf.Name, kind)
+		}
+
+		fv := sv.Field(i)
+		if _, ok := fieldValue(fv); !ok {
+			return nil, fmt.Errorf("field %s: unsupported type %s", sf.Name, sf.Type)
+		}
+
+		fields = append(fields, &boundField{name: name, kind: kind, value: fv})
+	}
+
+	return fields, nil
+}
+
+// publishFields pushes the current field values into their metrics
+func (m *Metrics) publishFields(fields []*boundField) {
+	for _, f := range fields {
+		v, _ := fieldValue(f.value)
+		switch f.kind {
+		case "gauge":
+			m.SetGauge(f.name, v, nil)
+		case "counter":
+			delta := v - f.last
+			if delta < 0 {
+				// Counter was reset, count from zero
+				delta = v
+			}
+			if delta > 0 {
+				m.IncrementCounterBy(f.name, delta, nil)
+			}
+			f.last = v
+		}
+	}
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldValue converts a supported field value to float64
+func fieldValue(v reflect.Value) (float64, bool) {
+	if v.Type() == durationType {
+		return time.Duration(v.Int()).Seconds(), true
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Bool:
+		if v.Bool() {
+			return 1, true
+		}
+		return 0, true
+	}
+
+	// Atomic types such as atomic.Int64 expose their value through Load()
+	if v.CanAddr() {
+		if load := v.Addr().MethodByName("Load"); load.IsValid() &&
+			load.Type().NumIn() == 0 && load.Type().NumOut() == 1 {
+			out := load.Call(nil)[0]
+			if out.Kind() != reflect.Ptr && out.Kind() != reflect.Interface {
+				return fieldValue(out)
+			}
+		}
+	}
+
+	return 0, false
+}
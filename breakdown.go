@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Components of the request time breakdown, see AddRequestTime
+const (
+	ComponentDB       = "db"
+	ComponentCache    = "cache"
+	ComponentExternal = "external"
+
+	// componentApp is the request time not spent in the other components
+	componentApp = "app"
+)
+
+// requestTimesKey stores the request time accumulator in the gin.Context
+const requestTimesKey = "metrics:request_times"
+
+// requestTimesContextKey stores the request time accumulator in the request
+// context, which reaches code that is not handed the gin.Context
+type requestTimesContextKey struct{}
+
+// requestTimes sums the time a request spent per component. Handlers may
+// start goroutines, so the sums are atomic.
+type requestTimes struct {
+	db, cache, external atomic.Int64 // Nanoseconds
+}
+
+// AddRequestTime adds time spent in a component, ComponentDB, ComponentCache
+// or ComponentExternal, to the request ctx belongs to. ctx is the gin.Context
+// or the request context. The GORM plugin, the RoundTripper and the Redis
+// hook call it already; call it for other clients:
+//
+//	start := time.Now()
+//	item, err := memcache.Get(key)
+//	metrics.AddRequestTime(c, metrics.ComponentCache, time.Since(start))
+//
+// It does nothing outside of requests measured with HTTPTimeBreakdown.
+func AddRequestTime(ctx context.Context, component string, d time.Duration) {
+	times := requestTimesFrom(ctx)
+	if times == nil {
+		return
+	}
+	switch component {
+	case ComponentDB:
+		times.db.Add(int64(d))
+	case ComponentCache:
+		times.cache.Add(int64(d))
+	case ComponentExternal:
+		times.external.Add(int64(d))
+	}
+}
+
+// requestTimesFrom returns the accumulator of the request of ctx, or nil
+func requestTimesFrom(ctx context.Context) *requestTimes {
+	if ctx == nil {
+		return nil
+	}
+	if c, ok := ctx.(*gin.Context); ok {
+		if v, ok := c.Get(requestTimesKey); ok {
+			return v.(*requestTimes)
+		}
+		if c.Request == nil {
+			return nil
+		}
+		ctx = c.Request.Context()
+	}
+	times, _ := ctx.Value(requestTimesContextKey{}).(*requestTimes)
+	return times
+}
+
+// newTimeBreakdown creates the histogram of the request time per component
+func (m *Metrics) newTimeBreakdown() *prometheus.HistogramVec {
+	return prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   m.config.Namespace,
+			Subsystem:   m.config.Subsystem,
+			Name:        "http_request_component_duration" + m.config.DurationUnit.suffix(),
+			Help:        "HTTP request duration spent in DB, cache and external calls, and the remaining app time",
+			Buckets:     m.config.DurationUnit.buckets(m.config.HTTPBuckets),
+			ConstLabels: m.httpConstLabels(),
+		},
+		[]string{"method", "path", "component"},
+	)
+}
+
+// trackRequestTimes attaches a new accumulator to the request, see
+// AddRequestTime
+func trackRequestTimes(c *gin.Context) *requestTimes {
+	times := &requestTimes{}
+	c.Set(requestTimesKey, times)
+	c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestTimesContextKey{}, times))
+	return times
+}
+
+// recordTimeBreakdown observes the component times of a completed request.
+// The app time is the rest of the request duration; components overlapping
+// in concurrent calls may add up to more than the duration, leaving no app
+// time.
+func (m *Metrics) recordTimeBreakdown(method, path string, times *requestTimes, duration float64) {
+	components := []struct {
+		name    string
+		seconds float64
+	}{
+		{ComponentDB, time.Duration(times.db.Load()).Seconds()},
+		{ComponentCache, time.Duration(times.cache.Load()).Seconds()},
+		{ComponentExternal, time.Duration(times.external.Load()).Seconds()},
+	}
+
+	app := duration
+	for _, component := range components {
+		m.httpMetrics.TimeBreakdown.WithLabelValues(method, path, component.name).Observe(m.config.DurationUnit.fromSeconds(component.seconds))
+		app -= component.seconds
+	}
+	m.httpMetrics.TimeBreakdown.WithLabelValues(method, path, componentApp).Observe(m.config.DurationUnit.fromSeconds(max(app, 0)))
+}
@@ -0,0 +1,65 @@
+package metrics
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// fanoutBuckets are the upper bounds of the fan-out label values
+var fanoutBuckets = []struct {
+	max   int
+	label string
+}{
+	{1, "1"},
+	{10, "2-10"},
+	{100, "11-100"},
+	{1000, "101-1000"},
+}
+
+// fanoutLabel returns the bucket of a number of recipients, e.g. "11-100"
+func fanoutLabel(recipients int) string {
+	for _, b := range fanoutBuckets {
+		if recipients <= b.max {
+			return b.label
+		}
+	}
+	return "1000+"
+}
+
+// Broadcast measures a message sent to many connections, see
+// WebSocketMetrics.BroadcastEnqueued
+type Broadcast struct {
+	ws        *WebSocketMetrics
+	start     time.Time
+	fanout    string
+	remaining atomic.Int64
+}
+
+// BroadcastEnqueued starts measuring a broadcast to recipients connections
+// when it is enqueued. Call Delivered once per recipient after its write
+// completed or failed; when the last recipient is done, the time since the
+// broadcast was enqueued is recorded in
+// websocket_broadcast_fanout_duration_seconds, labelled with the fan-out
+// size as "1", "2-10", "11-100", "101-1000" or "1000+":
+//
+//	b := ws.BroadcastEnqueued(len(room.Clients))
+//	for _, client := range room.Clients {
+//	    go func() {
+//	        client.Write(state)
+//	        b.Delivered()
+//	    }()
+//	}
+func (ws *WebSocketMetrics) BroadcastEnqueued(recipients int) *Broadcast {
+	b := &Broadcast{ws: ws, start: time.Now(), fanout: fanoutLabel(recipients)}
+	b.remaining.Store(int64(recipients))
+	return b
+}
+
+// Delivered marks the write to one recipient as completed. It is safe to
+// call from the goroutines writing to the recipients.
+func (b *Broadcast) Delivered() {
+	if b.remaining.Add(-1) != 0 {
+		return
+	}
+	b.ws.m.recordDuration("websocket_broadcast_fanout_duration", time.Since(b.start).Seconds(), MetricLabels{"fanout": b.fanout})
+}
@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ErrOverBudget is returned by CheckSeriesBudget when the estimated series
+// count exceeds the budget
+var ErrOverBudget = errors.New("series count exceeds budget")
+
+// SeriesBudget is the number of series a service may export, checked by
+// CheckSeriesBudget
+type SeriesBudget struct {
+	// MaxSeries is the budget; every histogram bucket counts as a series, as
+	// in Grafana Cloud billing
+	MaxSeries int
+
+	// LabelValues is the expected number of distinct values per label key in
+	// production, e.g. {"region": 12}. Keys not listed count with the values
+	// seen so far.
+	LabelValues map[string]int
+}
+
+// SeriesEstimate is the series count of one metric
+type SeriesEstimate struct {
+	Name      string // Exposed name
+	Observed  int    // Series exported now
+	Estimated int    // Series expected in production
+}
+
+// BudgetReport is the result of CheckSeriesBudget
+type BudgetReport struct {
+	MaxSeries int
+	Estimated int
+	Metrics   []SeriesEstimate // By estimated series count, largest first
+}
+
+// CheckSeriesBudget estimates the series count of all exported metrics and
+// returns an error wrapping ErrOverBudget if it exceeds the budget.
+//
+// Custom metrics are estimated from their label keys: the product of the
+// expected distinct values of each key, capped by their cardinality limit,
+// times the series per label combination (buckets, sum and count for
+// histograms). Other metrics count with the series exported now.
+func (m *Metrics) CheckSeriesBudget(budget SeriesBudget) (*BudgetReport, error) {
+	families, err := m.gatherer().Gather()
+	if err != nil {
+		return nil, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	estimates := make(map[string]*SeriesEstimate, len(families))
+	samples := make(map[string]int, len(families))        // Series per label combination
+	values := make(map[string]map[string]map[string]bool) // By family and label key
+	for _, mf := range families {
+		name := mf.GetName()
+		estimates[name] = &SeriesEstimate{Name: name}
+		values[name] = make(map[string]map[string]bool)
+		for _, metric := range mf.GetMetric() {
+			n := seriesPerMetric(mf.GetType(), metric)
+			samples[name] = n
+			estimates[name].Observed += n
+			for _, label := range metric.GetLabel() {
+				if values[name][label.GetName()] == nil {
+					values[name][label.GetName()] = make(map[string]bool)
+				}
+				values[name][label.GetName()][label.GetValue()] = true
+			}
+		}
+		estimates[name].Estimated = estimates[name].Observed
+	}
+
+	m.mu.RLock()
+	for name, keys := range m.schemas {
+		if strings.HasPrefix(name, "replay:") {
+			continue
+		}
+		fqName := m.QualifiedName(name)
+
+		combinations := 1
+		for _, key := range keys {
+			n := budget.LabelValues[key]
+			if n <= 0 {
+				n = max(len(values[fqName][key]), 1)
+			}
+			combinations *= n
+		}
+		if limit := m.cardinalityLimit(name); limit > 0 && len(keys) > 0 {
+			// Plus the overflow series
+			combinations = min(combinations, limit+1)
+		}
+
+		perCombination, ok := samples[fqName]
+		if !ok {
+			perCombination = 1
+			if _, histogram := m.histograms[name]; histogram {
+				perCombination = len(defaultBuckets(name)) + 3 // Plus +Inf, sum and count
+			}
+		}
+
+		e, ok := estimates[fqName]
+		if !ok {
+			e = &SeriesEstimate{Name: fqName}
+			estimates[fqName] = e
+		}
+		e.Estimated = max(combinations*perCombination, e.Observed)
+	}
+	m.mu.RUnlock()
+
+	report := &BudgetReport{MaxSeries: budget.MaxSeries}
+	for _, e := range estimates {
+		report.Estimated += e.Estimated
+		report.Metrics = append(report.Metrics, *e)
+	}
+	slices.SortFunc(report.Metrics, func(a, b SeriesEstimate) int {
+		return cmp.Or(cmp.Compare(b.Estimated, a.Estimated), cmp.Compare(a.Name, b.Name))
+	})
+
+	if report.Estimated > budget.MaxSeries {
+		return report, fmt.Errorf("%w: %d estimated series, budget is %d", ErrOverBudget, report.Estimated, budget.MaxSeries)
+	}
+	return report, nil
+}
+
+// ExitOnSeriesBudget checks the budget, prints the report to stdout and
+// exits the process: with status 1 if the budget is exceeded, 0 otherwise.
+// Call it from a CI step after registering the metrics, e.g. behind a flag:
+//
+//	if *checkBudget {
+//	    m.ExitOnSeriesBudget(metrics.SeriesBudget{MaxSeries: 5000})
+//	}
+func (m *Metrics) ExitOnSeriesBudget(budget SeriesBudget) {
+	report, err := m.CheckSeriesBudget(budget)
+	if report != nil {
+		report.Write(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// Write prints the report as a table of the metrics by estimated series
+func (r *BudgetReport) Write(w io.Writer) {
+	fmt.Fprintf(w, "%-60s %10s %10s\n", "METRIC", "OBSERVED", "ESTIMATED")
+	for _, e := range r.Metrics {
+		fmt.Fprintf(w, "%-60s %10d %10d\n", e.Name, e.Observed, e.Estimated)
+	}
+	fmt.Fprintf(w, "%-60s %10s %10d / %d\n", "TOTAL", "", r.Estimated, r.MaxSeries)
+}
+
+// seriesPerMetric returns the number of series of a gathered metric, with
+// one per histogram bucket and summary quantile plus sum and count
+func seriesPerMetric(t dto.MetricType, metric *dto.Metric) int {
+	switch t {
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		// Gathered buckets exclude +Inf
+		return len(metric.GetHistogram().GetBucket()) + 3
+	case dto.MetricType_SUMMARY:
+		return len(metric.GetSummary().GetQuantile()) + 2
+	default:
+		return 1
+	}
+}
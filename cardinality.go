@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// OverflowLabelValue replaces all label values of series beyond a metric's
+// cardinality limit
+const OverflowLabelValue = "other"
+
+// cardinalityTracker remembers the label value combinations seen per metric
+type cardinalityTracker struct {
+	series map[string]map[string]struct{}
+	mu     sync.Mutex
+}
+
+// newCardinalityTracker creates an empty tracker
+func newCardinalityTracker() *cardinalityTracker {
+	return &cardinalityTracker{
+		series: make(map[string]map[string]struct{}),
+	}
+}
+
+// cardinalityLimit returns the maximum number of series for a metric, 0 if unlimited
+func (m *Metrics) cardinalityLimit(name string) int {
+	if limit, ok := m.config.CardinalityLimits[name]; ok {
+		return limit
+	}
+	return m.config.MaxSeriesPerMetric
+}
+
+// limitCardinality returns labels unchanged while the metric is within its
+// cardinality limit. Once the limit is reached, new label combinations are
+// collapsed into a single overflow series whose values are all "other".
+func (m *Metrics) limitCardinality(name string, labels MetricLabels) MetricLabels {
+	limit := m.cardinalityLimit(name)
+	if limit <= 0 || len(labels) == 0 {
+		return labels
+	}
+
+	key := seriesKey(labels)
+
+	t := m.cardinality
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	seen, ok := t.series[name]
+	if !ok {
+		seen = make(map[string]struct{})
+		t.series[name] = seen
+	}
+
+	if _, exists := seen[key]; exists {
+		return labels
+	}
+	if len(seen) < limit {
+		seen[key] = struct{}{}
+		return labels
+	}
+
+	m.internal.CardinalityDropped.WithLabelValues(name).Inc()
+
+	overflow := make(MetricLabels, len(labels))
+	for k := range labels {
+		overflow[k] = OverflowLabelValue
+	}
+	return overflow
+}
+
+// forget removes a deleted series so it no longer counts against the limit
+func (t *cardinalityTracker) forget(name string, labels MetricLabels) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.series[name], seriesKey(labels))
+}
+
+// forgetMetric removes all series of a metric
+func (t *cardinalityTracker) forgetMetric(name string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.series, name)
+}
+
+// clear removes all series of all metrics
+func (t *cardinalityTracker) clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.series = make(map[string]map[string]struct{})
+}
+
+// seriesKey builds a stable key from label values, ordered by label name
+func seriesKey(labels MetricLabels) string {
+	keys := getLabelKeys(labels)
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(labels[k])
+		b.WriteByte(0xff)
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrInjectedFailure is returned by operations failed through a FailureInjector
+var ErrInjectedFailure = errors.New("injected failure")
+
+// FailureInjector simulates failures of the metrics pipeline on demand, so
+// alerting on metrics_push_failures_total or scrape errors can be verified.
+// It is only available when Config.EnableFailureInjection is set; all
+// methods are no-ops on a nil injector.
+type FailureInjector struct {
+	failPushes   atomic.Bool
+	failGather   atomic.Bool
+	collectDelay atomic.Int64
+}
+
+// FailureInjector returns the failure injector, or nil unless
+// Config.EnableFailureInjection is set
+func (m *Metrics) FailureInjector() *FailureInjector {
+	return m.chaos
+}
+
+// FailPushes makes every push to Grafana Cloud or OTLP fail while enabled
+func (f *FailureInjector) FailPushes(fail bool) {
+	if f != nil {
+		f.failPushes.Store(fail)
+	}
+}
+
+// FailGather makes gathering the registry, and so scrapes and pushes, fail
+// while enabled
+func (f *FailureInjector) FailGather(fail bool) {
+	if f != nil {
+		f.failGather.Store(fail)
+	}
+}
+
+// SlowCollect delays every gather of the registry by delay (0 disables)
+func (f *FailureInjector) SlowCollect(delay time.Duration) {
+	if f != nil {
+		f.collectDelay.Store(int64(delay))
+	}
+}
+
+// Reset disables all injected failures
+func (f *FailureInjector) Reset() {
+	f.FailPushes(false)
+	f.FailGather(false)
+	f.SlowCollect(0)
+}
+
+// pushError returns the injected push failure, if any
+func (f *FailureInjector) pushError() error {
+	if f != nil && f.failPushes.Load() {
+		return ErrInjectedFailure
+	}
+	return nil
+}
+
+// chaosDesc describes the invalid metric reported for injected gather errors
+var chaosDesc = prometheus.NewDesc("metrics_injected_failure", "Injected gather failure", nil, nil)
+
+// Describe implements prometheus.Collector. The injector is an unchecked
+// collector as it only ever reports errors.
+func (f *FailureInjector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector
+func (f *FailureInjector) Collect(ch chan<- prometheus.Metric) {
+	if delay := time.Duration(f.collectDelay.Load()); delay > 0 {
+		time.Sleep(delay)
+	}
+	if f.failGather.Load() {
+		ch <- prometheus.NewInvalidMetric(chaosDesc, ErrInjectedFailure)
+	}
+}
@@ -0,0 +1,167 @@
+package metrics
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pushClock hands out strictly increasing push timestamps. All series of a
+// push share its timestamp, so this keeps every series monotonic and avoids
+// "out of order sample" rejections when the system clock steps backwards.
+type pushClock struct {
+	target string
+	last   time.Time
+	mu     sync.Mutex
+}
+
+// newPushClock creates the clock of a push target
+func newPushClock(target string) *pushClock {
+	return &pushClock{target: target}
+}
+
+// now returns the current time, or 1ms after the previous timestamp if the
+// clock has not advanced past it
+func (c *pushClock) now(m *Metrics) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Strip the monotonic reading, as pushed timestamps are wall clock times
+	now := time.Now().Round(0)
+	if !c.last.IsZero() && !now.After(c.last) {
+		m.internal.ClockBackwards.WithLabelValues(c.target).Inc()
+		m.logf("metrics: clock went back %v since last push to %s, reusing the last timestamp", c.last.Sub(now), c.target)
+		now = c.last.Add(time.Millisecond)
+	}
+	c.last = now
+	return now
+}
+
+// clockSkewCheckInterval is how often the clock is compared against NTPServer
+const clockSkewCheckInterval = 10 * time.Minute
+
+// StartClockSkewCheck compares the local clock against NTPServer every 10
+// minutes until ctx is cancelled, exposing the difference as
+// metrics_clock_skew_seconds and logging a warning if it exceeds
+// MaxClockSkew. It is started automatically when NTPServer is set.
+func (m *Metrics) StartClockSkewCheck(ctx context.Context) error {
+	if m.config.NTPServer == "" {
+		return fmt.Errorf("NTPServer is not configured")
+	}
+
+	skew := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace:   m.config.Namespace,
+		Subsystem:   m.config.Subsystem,
+		Name:        "metrics_clock_skew_seconds",
+		Help:        "Offset of the local clock from the NTP server, positive when ahead",
+		ConstLabels: m.config.ConstLabels,
+	})
+	if err := m.registry.Register(skew); err != nil {
+		return fmt.Errorf("failed to register clock skew gauge: %w", err)
+	}
+
+	go func() {
+		ticker := time.NewTicker(clockSkewCheckInterval)
+		defer ticker.Stop()
+
+		for {
+			m.checkClockSkew(ctx, skew)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// checkClockSkew queries the NTP server once and updates the skew gauge
+func (m *Metrics) checkClockSkew(ctx context.Context, skew prometheus.Gauge) {
+	offset, err := queryNTP(ctx, m.config.NTPServer)
+	if err != nil {
+		m.logf("Failed to query NTP server %s: %v", m.config.NTPServer, err)
+		return
+	}
+
+	// The server is ahead by offset, so the local clock is ahead by -offset
+	skew.Set(-offset.Seconds())
+
+	limit := m.config.MaxClockSkew
+	if limit <= 0 {
+		limit = time.Second
+	}
+	if offset > limit || offset < -limit {
+		m.logf("metrics: local clock is off by %v from %s, pushed samples may be rejected", -offset, m.config.NTPServer)
+	}
+}
+
+// ntpEpochOffset is the number of seconds between 1900 and 1970
+const ntpEpochOffset = 2208988800
+
+// queryNTP returns the offset of the NTP server clock from the local clock
+// using a single SNTP request. The default port 123 is used unless server
+// includes one.
+func queryNTP(ctx context.Context, server string) (time.Duration, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", server)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// LI 0, version 3, mode 3 (client)
+	req := make([]byte, 48)
+	req[0] = 0x1B
+
+	sent := time.Now()
+	if _, err := conn.Write(req); err != nil {
+		return 0, err
+	}
+
+	resp := make([]byte, 48)
+	n, err := conn.Read(resp)
+	if err != nil {
+		return 0, err
+	}
+	received := time.Now()
+
+	if n < len(resp) {
+		return 0, fmt.Errorf("short NTP response of %d bytes", n)
+	}
+	if mode := resp[0] & 0x7; mode != 4 {
+		return 0, fmt.Errorf("unexpected NTP mode %d", mode)
+	}
+	if stratum := resp[1]; stratum == 0 {
+		return 0, fmt.Errorf("NTP server sent kiss-o'-death %q", resp[12:16])
+	}
+
+	serverReceived := ntpTime(resp[32:40])
+	serverSent := ntpTime(resp[40:48])
+	return (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2, nil
+}
+
+// ntpTime decodes a 64-bit NTP timestamp
+func ntpTime(b []byte) time.Time {
+	seconds := int64(binary.BigEndian.Uint32(b[0:4])) - ntpEpochOffset
+	fraction := int64(binary.BigEndian.Uint32(b[4:8]))
+	return time.Unix(seconds, fraction*int64(time.Second)>>32)
+}
@@ -0,0 +1,330 @@
+// Command metricsgen generates typed metric accessors from a YAML spec.
+//
+// Usage:
+//
+//	//go:generate go run github.com/OkanUysal/go-metrics/cmd/metricsgen -spec metrics.yaml -out metrics_gen.go
+//
+// Spec format:
+//
+//	package: appmetrics
+//	type: AppMetrics
+//	metrics:
+//	  - name: orders_total
+//	    type: counter
+//	    help: Total number of orders
+//	    labels: [status]
+//	    values:
+//	      status: [paid, failed]
+//
+// The generated code exposes one field per metric, e.g.
+// am.OrdersTotal.Inc(status), so call sites cannot misspell metric names or
+// label keys. RegisterAppMetrics additionally pre-registers every metric with
+// its help text so mistakes surface at startup. Labels with values get a
+// constant per value, e.g. OrdersTotalStatusPaid, and other values are
+// recorded as "other" (see Metrics.DeclareLabelValues).
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"go.yaml.in/yaml/v2"
+)
+
+// Spec is the declarative metrics specification
+type Spec struct {
+	Package string       `yaml:"package"`
+	Type    string       `yaml:"type"`
+	Metrics []MetricSpec `yaml:"metrics"`
+}
+
+// MetricSpec describes a single metric
+type MetricSpec struct {
+	Name   string   `yaml:"name"`
+	Type   string   `yaml:"type"` // counter, gauge or histogram
+	Help   string   `yaml:"help"`
+	Labels []string `yaml:"labels"`
+
+	// Values are the allowed values per label
+	Values map[string][]string `yaml:"values"`
+}
+
+var (
+	nameRe     = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+	nonIdentRe = regexp.MustCompile(`[^a-zA-Z0-9]+`)
+)
+
+func main() {
+	specPath := flag.String("spec", "metrics.yaml", "path to the YAML metrics spec")
+	outPath := flag.String("out", "metrics_gen.go", "path of the generated Go file")
+	flag.Parse()
+
+	data, err := os.ReadFile(*specPath)
+	if err != nil {
+		fatalf("failed to read spec: %v", err)
+	}
+
+	src, err := generate(data)
+	if err != nil {
+		fatalf("%s: %v", *specPath, err)
+	}
+
+	if err := os.WriteFile(*outPath, src, 0o644); err != nil {
+		fatalf("failed to write output: %v", err)
+	}
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "metricsgen: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// generate parses a spec and returns formatted Go source
+func generate(data []byte) ([]byte, error) {
+	var spec Spec
+	if err := yaml.UnmarshalStrict(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to parse spec: %w", err)
+	}
+	if err := validate(&spec); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, &spec); err != nil {
+		return nil, fmt.Errorf("failed to render template: %w", err)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return src, nil
+}
+
+// validate checks the spec and fills in defaults
+func validate(spec *Spec) error {
+	if spec.Package == "" {
+		return fmt.Errorf("package is required")
+	}
+	if spec.Type == "" {
+		spec.Type = "Metrics"
+	}
+
+	seen := make(map[string]bool)
+	for _, ms := range spec.Metrics {
+		if !nameRe.MatchString(ms.Name) {
+			return fmt.Errorf("invalid metric name %q", ms.Name)
+		}
+		if seen[ms.Name] {
+			return fmt.Errorf("duplicate metric %q", ms.Name)
+		}
+		seen[ms.Name] = true
+
+		switch ms.Type {
+		case "counter", "gauge", "histogram":
+		default:
+			return fmt.Errorf("metric %q: unsupported type %q", ms.Name, ms.Type)
+		}
+
+		for _, label := range ms.Labels {
+			if !nameRe.MatchString(label) {
+				return fmt.Errorf("metric %q: invalid label %q", ms.Name, label)
+			}
+		}
+
+		for label, values := range ms.Values {
+			if !slices.Contains(ms.Labels, label) {
+				return fmt.Errorf("metric %q: values of undeclared label %q", ms.Name, label)
+			}
+			consts := make(map[string]string)
+			for _, value := range values {
+				if value == "" {
+					return fmt.Errorf("metric %q: empty value of label %q", ms.Name, label)
+				}
+				name := constName(ms.Name, label, value)
+				if other, ok := consts[name]; ok {
+					return fmt.Errorf("metric %q: value %q of label %q clashes with %q", ms.Name, value, label, other)
+				}
+				consts[name] = value
+			}
+		}
+	}
+	return nil
+}
+
+// camel converts snake_case to CamelCase
+func camel(s string) string {
+	parts := strings.Split(s, "_")
+	for i, p := range parts {
+		if p != "" {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// lowerCamel converts snake_case to lowerCamelCase
+func lowerCamel(s string) string {
+	c := camel(s)
+	if c == "" {
+		return c
+	}
+	return strings.ToLower(c[:1]) + c[1:]
+}
+
+// constName returns the name of the constant of a label value, e.g.
+// OrdersTotalStatusPaid
+func constName(metric, label, value string) string {
+	return camel(metric) + camel(label) + camel(nonIdentRe.ReplaceAllString(value, "_"))
+}
+
+// paramName returns the Go parameter name for a label, avoiding keywords and
+// identifiers already used by the generated methods
+func paramName(label string) string {
+	name := lowerCamel(label)
+	if token.IsKeyword(name) || name == "value" || name == "x" || name == "metrics" {
+		return name + "Label"
+	}
+	return name
+}
+
+var fileTemplate = template.Must(template.New("file").Funcs(template.FuncMap{
+	"camel": camel,
+	"params": func(labels []string) string {
+		if len(labels) == 0 {
+			return ""
+		}
+		params := make([]string, len(labels))
+		for i, l := range labels {
+			params[i] = paramName(l)
+		}
+		return strings.Join(params, ", ") + " string"
+	},
+	"labelsExpr": func(labels []string) string {
+		if len(labels) == 0 {
+			return "nil"
+		}
+		pairs := make([]string, len(labels))
+		for i, l := range labels {
+			pairs[i] = fmt.Sprintf("%q: %s", l, paramName(l))
+		}
+		return "metrics.MetricLabels{" + strings.Join(pairs, ", ") + "}"
+	},
+	"helpText": func(ms MetricSpec) string {
+		if ms.Help != "" {
+			return ms.Help
+		}
+		return ms.Name
+	},
+	"labelKeys": func(labels []string) string {
+		if len(labels) == 0 {
+			return "nil"
+		}
+		quoted := make([]string, len(labels))
+		for i, l := range labels {
+			quoted[i] = strconv.Quote(l)
+		}
+		return "[]string{" + strings.Join(quoted, ", ") + "}"
+	},
+	"constName": constName,
+	"constList": func(metric, label string, values []string) string {
+		names := make([]string, len(values))
+		for i, v := range values {
+			names[i] = constName(metric, label, v)
+		}
+		return "[]string{" + strings.Join(names, ", ") + "}"
+	},
+	"comma": func(labels []string) string {
+		if len(labels) == 0 {
+			return ""
+		}
+		return ", "
+	},
+}).Parse(`// Code generated by metricsgen. DO NOT EDIT.
+
+package {{.Package}}
+
+import "github.com/OkanUysal/go-metrics"
+{{range .Metrics}}{{$name := .Name}}{{range $label, $values := .Values}}
+// Values of the {{$label}} label of {{$name}}
+const (
+{{- range $values}}
+	{{constName $name $label .}} = {{printf "%q" .}}
+{{- end}}
+)
+{{end}}{{end}}
+// {{.Type}} provides typed accessors for the declared metrics
+type {{.Type}} struct {
+{{- range .Metrics}}
+	{{camel .Name}} *{{camel .Name}}{{camel .Type}}
+{{- end}}
+}
+
+// New{{.Type}} binds the declared metrics to m, restricting labels with
+// declared values to them
+func New{{.Type}}(m *metrics.Metrics) *{{.Type}} {
+{{- range .Metrics}}{{$name := .Name}}{{range $label, $values := .Values}}
+	m.DeclareLabelValues("{{$name}}", "{{$label}}", {{constList $name $label $values}})
+{{- end}}{{end}}
+	return &{{.Type}}{
+{{- range .Metrics}}
+		{{camel .Name}}: &{{camel .Name}}{{camel .Type}}{m: m},
+{{- end}}
+	}
+}
+
+// Register{{.Type}} pre-registers the declared metrics with their help text and
+// label sets, then binds them to m
+func Register{{.Type}}(m *metrics.Metrics) (*{{.Type}}, error) {
+{{- range .Metrics}}
+	if err := m.Register{{camel .Type}}("{{.Name}}", {{printf "%q" (helpText .)}}, {{labelKeys .Labels}}{{if eq .Type "histogram"}}, nil{{end}}); err != nil {
+		return nil, err
+	}
+{{- end}}
+	return New{{.Type}}(m), nil
+}
+{{range .Metrics}}{{$labels := .Labels}}
+// {{camel .Name}}{{camel .Type}} records {{.Name}}{{if .Help}}: {{.Help}}{{end}}
+type {{camel .Name}}{{camel .Type}} struct {
+	m *metrics.Metrics
+}
+{{if eq .Type "counter"}}
+// Inc increments {{.Name}} by one
+func (x *{{camel .Name}}{{camel .Type}}) Inc({{params $labels}}) {
+	x.m.IncrementCounter("{{.Name}}", {{labelsExpr $labels}})
+}
+
+// Add increments {{.Name}} by value
+func (x *{{camel .Name}}{{camel .Type}}) Add(value float64{{comma $labels}}{{params $labels}}) {
+	x.m.IncrementCounterBy("{{.Name}}", value, {{labelsExpr $labels}})
+}
+{{else if eq .Type "gauge"}}
+// Set sets {{.Name}} to value
+func (x *{{camel .Name}}{{camel .Type}}) Set(value float64{{comma $labels}}{{params $labels}}) {
+	x.m.SetGauge("{{.Name}}", value, {{labelsExpr $labels}})
+}
+
+// Inc increments {{.Name}} by one
+func (x *{{camel .Name}}{{camel .Type}}) Inc({{params $labels}}) {
+	x.m.IncrementGauge("{{.Name}}", {{labelsExpr $labels}})
+}
+
+// Dec decrements {{.Name}} by one
+func (x *{{camel .Name}}{{camel .Type}}) Dec({{params $labels}}) {
+	x.m.DecrementGauge("{{.Name}}", {{labelsExpr $labels}})
+}
+{{else}}
+// Observe records an observation of {{.Name}}
+func (x *{{camel .Name}}{{camel .Type}}) Observe(value float64{{comma $labels}}{{params $labels}}) {
+	x.m.RecordHistogram("{{.Name}}", value, {{labelsExpr $labels}})
+}
+{{end}}{{end}}`))
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	spec := `
+package: appmetrics
+type: AppMetrics
+metrics:
+  - name: orders_total
+    type: counter
+    help: Total number of orders
+    labels: [status, payment_method]
+    values:
+      status: [paid, failed, in-review]
+  - name: queue_depth
+    type: gauge
+  - name: checkout_duration_seconds
+    type: histogram
+    labels: [step, type]
+`
+	src, err := generate([]byte(spec))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	out := string(src)
+	for _, want := range []string{
+		"package appmetrics",
+		"func NewAppMetrics(m *metrics.Metrics) *AppMetrics",
+		"func RegisterAppMetrics(m *metrics.Metrics) (*AppMetrics, error)",
+		`m.RegisterCounter("orders_total", "Total number of orders", []string{"status", "payment_method"})`,
+		`m.RegisterHistogram("checkout_duration_seconds", "checkout_duration_seconds", []string{"step", "type"}, nil)`,
+		"func (x *OrdersTotalCounter) Inc(status, paymentMethod string)",
+		`OrdersTotalStatusInReview = "in-review"`,
+		`m.DeclareLabelValues("orders_total", "status", []string{OrdersTotalStatusPaid, OrdersTotalStatusFailed, OrdersTotalStatusInReview})`,
+		`metrics.MetricLabels{"status": status, "payment_method": paymentMethod}`,
+		"func (x *QueueDepthGauge) Set(value float64)",
+		"func (x *CheckoutDurationSecondsHistogram) Observe(value float64, step, typeLabel string)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected generated code to contain %q", want)
+		}
+	}
+}
+
+func TestGenerateInvalidSpec(t *testing.T) {
+	cases := map[string]string{
+		"missing package":  "metrics: []",
+		"bad type":         "package: x\nmetrics:\n  - name: a\n    type: summary",
+		"bad label":        "package: x\nmetrics:\n  - name: a\n    type: gauge\n    labels: [bad-label]",
+		"duplicate":        "package: x\nmetrics:\n  - name: a\n    type: gauge\n  - name: a\n    type: gauge",
+		"undeclared label": "package: x\nmetrics:\n  - name: a\n    type: gauge\n    values:\n      status: [ok]",
+		"value clash":      "package: x\nmetrics:\n  - name: a\n    type: gauge\n    labels: [status]\n    values:\n      status: [in-review, in_review]",
+	}
+	for name, spec := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := generate([]byte(spec)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
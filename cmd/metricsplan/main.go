@@ -0,0 +1,297 @@
+// Command metricsplan estimates the cost of a metric schema before it ships.
+//
+// Usage:
+//
+//	go run github.com/OkanUysal/go-metrics/cmd/metricsplan -plan plan.yaml
+//
+// Plan format:
+//
+//	namespace: myapp
+//	interval: 15s
+//	metrics:
+//	  - name: orders_total
+//	    type: counter
+//	    labels:
+//	      status: 5
+//	      region: 12
+//	  - name: checkout_duration_seconds
+//	    type: histogram
+//	    buckets: 11
+//	    labels:
+//	      step: 4
+//
+// Labels map each label key to its expected number of distinct values. Every
+// metric is materialized in a Prometheus registry (up to -sample series, the
+// rest is extrapolated) to measure registry memory and scrape payload size.
+// Data points per minute (DPM), which Grafana Cloud bills, follow from the
+// number of sample series and the scrape or push interval.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/expfmt"
+	"go.yaml.in/yaml/v2"
+)
+
+// Plan describes the expected metric schema
+type Plan struct {
+	Namespace string        `yaml:"namespace"`
+	Interval  time.Duration `yaml:"interval"`
+	Metrics   []MetricPlan  `yaml:"metrics"`
+}
+
+// MetricPlan describes a single metric and its label value counts
+type MetricPlan struct {
+	Name    string         `yaml:"name"`
+	Type    string         `yaml:"type"` // counter, gauge or histogram
+	Buckets int            `yaml:"buckets"`
+	Labels  map[string]int `yaml:"labels"`
+}
+
+// Estimate is the measured or extrapolated cost of one metric
+type Estimate struct {
+	Name          string
+	Type          string
+	Series        int64 // Label combinations
+	SampleSeries  int64 // Exposed series, e.g. including histogram buckets
+	MemoryBytes   int64
+	PayloadBytes  int64
+	Extrapolated  bool
+	DataPointsMin float64
+}
+
+// defaultBuckets matches prometheus.DefBuckets
+const defaultBuckets = 11
+
+var nameRe = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+func main() {
+	planPath := flag.String("plan", "plan.yaml", "path to the YAML metrics plan")
+	sample := flag.Int64("sample", 100000, "maximum series materialized per metric")
+	flag.Parse()
+
+	data, err := os.ReadFile(*planPath)
+	if err != nil {
+		fatalf("failed to read plan: %v", err)
+	}
+
+	plan, err := parse(data)
+	if err != nil {
+		fatalf("%s: %v", *planPath, err)
+	}
+
+	estimates, err := estimate(plan, *sample)
+	if err != nil {
+		fatalf("%v", err)
+	}
+	report(os.Stdout, plan, estimates)
+}
+
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, "metricsplan: "+format+"\n", args...)
+	os.Exit(1)
+}
+
+// parse decodes and validates a plan, filling in defaults
+func parse(data []byte) (*Plan, error) {
+	var plan Plan
+	if err := yaml.UnmarshalStrict(data, &plan); err != nil {
+		return nil, fmt.Errorf("failed to parse plan: %w", err)
+	}
+	if plan.Interval <= 0 {
+		plan.Interval = 15 * time.Second
+	}
+
+	seen := make(map[string]bool)
+	for i := range plan.Metrics {
+		mp := &plan.Metrics[i]
+		if !nameRe.MatchString(mp.Name) {
+			return nil, fmt.Errorf("invalid metric name %q", mp.Name)
+		}
+		if seen[mp.Name] {
+			return nil, fmt.Errorf("duplicate metric %q", mp.Name)
+		}
+		seen[mp.Name] = true
+
+		switch mp.Type {
+		case "counter", "gauge":
+		case "histogram":
+			if mp.Buckets <= 0 {
+				mp.Buckets = defaultBuckets
+			}
+		default:
+			return nil, fmt.Errorf("metric %q: unsupported type %q", mp.Name, mp.Type)
+		}
+
+		for label, count := range mp.Labels {
+			if !nameRe.MatchString(label) {
+				return nil, fmt.Errorf("metric %q: invalid label %q", mp.Name, label)
+			}
+			if count <= 0 {
+				return nil, fmt.Errorf("metric %q: label %q needs a positive value count", mp.Name, label)
+			}
+		}
+	}
+	return &plan, nil
+}
+
+// estimate measures every metric of the plan
+func estimate(plan *Plan, sample int64) ([]Estimate, error) {
+	if sample <= 0 {
+		return nil, fmt.Errorf("sample must be positive")
+	}
+
+	estimates := make([]Estimate, 0, len(plan.Metrics))
+	for _, mp := range plan.Metrics {
+		e, err := measure(plan, mp, sample)
+		if err != nil {
+			return nil, fmt.Errorf("metric %q: %w", mp.Name, err)
+		}
+		estimates = append(estimates, e)
+	}
+	return estimates, nil
+}
+
+// measure materializes up to sample series of a metric in a fresh registry
+// and scales memory and payload to the full series count
+func measure(plan *Plan, mp MetricPlan, sample int64) (Estimate, error) {
+	keys := make([]string, 0, len(mp.Labels))
+	series := int64(1)
+	for k, count := range mp.Labels {
+		keys = append(keys, k)
+		series *= int64(count)
+	}
+	sort.Strings(keys)
+
+	perSeries := int64(1)
+	if mp.Type == "histogram" {
+		// One series per bucket plus +Inf, _sum and _count
+		perSeries = int64(mp.Buckets) + 3
+	}
+
+	materialized := min(series, sample)
+
+	registry := prometheus.NewRegistry()
+	before := heapAlloc()
+
+	collector, add := newCollector(plan.Namespace, mp, keys)
+	if err := registry.Register(collector); err != nil {
+		return Estimate{}, err
+	}
+	values := make([]string, len(keys))
+	for i := int64(0); i < materialized; i++ {
+		rest := i
+		for j, k := range keys {
+			count := int64(mp.Labels[k])
+			values[j] = "v" + strconv.FormatInt(rest%count, 10)
+			rest /= count
+		}
+		add(values)
+	}
+
+	memory := heapAlloc() - before
+
+	families, err := registry.Gather()
+	if err != nil {
+		return Estimate{}, err
+	}
+	var payload bytes.Buffer
+	enc := expfmt.NewEncoder(&payload, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return Estimate{}, err
+		}
+	}
+	runtime.KeepAlive(registry)
+
+	scale := float64(series) / float64(materialized)
+	return Estimate{
+		Name:          mp.Name,
+		Type:          mp.Type,
+		Series:        series,
+		SampleSeries:  series * perSeries,
+		MemoryBytes:   int64(float64(max(memory, 0)) * scale),
+		PayloadBytes:  int64(float64(payload.Len()) * scale),
+		Extrapolated:  materialized < series,
+		DataPointsMin: float64(series*perSeries) * time.Minute.Seconds() / plan.Interval.Seconds(),
+	}, nil
+}
+
+// newCollector creates the vector of a metric and a function creating one
+// of its series
+func newCollector(namespace string, mp MetricPlan, keys []string) (prometheus.Collector, func([]string)) {
+	switch mp.Type {
+	case "counter":
+		vec := prometheus.NewCounterVec(prometheus.CounterOpts{Namespace: namespace, Name: mp.Name, Help: mp.Name}, keys)
+		return vec, func(v []string) { vec.WithLabelValues(v...).Inc() }
+	case "gauge":
+		vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Namespace: namespace, Name: mp.Name, Help: mp.Name}, keys)
+		return vec, func(v []string) { vec.WithLabelValues(v...).Set(1) }
+	default:
+		buckets := prometheus.ExponentialBuckets(0.001, 2, mp.Buckets)
+		vec := prometheus.NewHistogramVec(prometheus.HistogramOpts{Namespace: namespace, Name: mp.Name, Help: mp.Name, Buckets: buckets}, keys)
+		return vec, func(v []string) { vec.WithLabelValues(v...).Observe(0.1) }
+	}
+}
+
+// heapAlloc returns the live heap size after a garbage collection
+func heapAlloc() int64 {
+	runtime.GC()
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return int64(ms.HeapAlloc)
+}
+
+// report prints the estimates as a table followed by totals
+func report(w io.Writer, plan *Plan, estimates []Estimate) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tTYPE\tSERIES\tSAMPLES\tMEMORY\tPAYLOAD\tDPM\t")
+
+	var total Estimate
+	for _, e := range estimates {
+		name := e.Name
+		if e.Extrapolated {
+			name += "*"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%s\t%.0f\t\n",
+			name, e.Type, e.Series, e.SampleSeries, bytesize(e.MemoryBytes), bytesize(e.PayloadBytes), e.DataPointsMin)
+
+		total.Series += e.Series
+		total.SampleSeries += e.SampleSeries
+		total.MemoryBytes += e.MemoryBytes
+		total.PayloadBytes += e.PayloadBytes
+		total.DataPointsMin += e.DataPointsMin
+	}
+	fmt.Fprintf(tw, "TOTAL\t\t%d\t%d\t%s\t%s\t%.0f\t\n",
+		total.Series, total.SampleSeries, bytesize(total.MemoryBytes), bytesize(total.PayloadBytes), total.DataPointsMin)
+	tw.Flush()
+
+	fmt.Fprintf(w, "\nDPM assumes one sample per series every %s.\n", plan.Interval)
+	fmt.Fprintln(w, "* extrapolated from a sample of the series")
+}
+
+// bytesize formats a byte count with a binary unit
+func bytesize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
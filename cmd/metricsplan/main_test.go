@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEstimate(t *testing.T) {
+	plan, err := parse([]byte(`
+namespace: myapp
+interval: 30s
+metrics:
+  - name: orders_total
+    type: counter
+    labels:
+      status: 5
+      region: 4
+  - name: checkout_duration_seconds
+    type: histogram
+    labels:
+      step: 3
+`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	estimates, err := estimate(plan, 10)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	orders, checkout := estimates[0], estimates[1]
+	if orders.Series != 20 || orders.SampleSeries != 20 {
+		t.Errorf("Expected 20 counter series, got %d/%d", orders.Series, orders.SampleSeries)
+	}
+	if !orders.Extrapolated {
+		t.Error("Expected counter beyond the sample to be extrapolated")
+	}
+	if orders.DataPointsMin != 40 {
+		t.Errorf("Expected 40 DPM at 30s, got %v", orders.DataPointsMin)
+	}
+	if orders.PayloadBytes == 0 {
+		t.Error("Expected a scrape payload estimate")
+	}
+
+	// 11 default buckets plus +Inf, _sum and _count per label combination
+	if checkout.Series != 3 || checkout.SampleSeries != 42 {
+		t.Errorf("Expected 3 histogram series with 42 samples, got %d/%d", checkout.Series, checkout.SampleSeries)
+	}
+	if checkout.Extrapolated {
+		t.Error("Expected histogram within the sample to be measured")
+	}
+
+	var out bytes.Buffer
+	report(&out, plan, estimates)
+	for _, want := range []string{"orders_total*", "TOTAL", "every 30s"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected report to contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestParseInvalidPlan(t *testing.T) {
+	cases := map[string]string{
+		"bad type":    "metrics:\n  - name: a\n    type: summary",
+		"bad label":   "metrics:\n  - name: a\n    type: gauge\n    labels: {bad-label: 2}",
+		"zero values": "metrics:\n  - name: a\n    type: gauge\n    labels: {route: 0}",
+		"duplicate":   "metrics:\n  - name: a\n    type: gauge\n  - name: a\n    type: gauge",
+	}
+	for name, plan := range cases {
+		t.Run(name, func(t *testing.T) {
+			if _, err := parse([]byte(plan)); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// RegisterCollector registers a third-party collector, prefixing its metric
+// names with the configured namespace and subsystem and adding ConstLabels,
+// so it follows the naming of the other metrics. Use Registry().Register to
+// register a collector unchanged.
+func (m *Metrics) RegisterCollector(c prometheus.Collector) error {
+	m.mu.RLock()
+	frozen := m.frozen
+	m.mu.RUnlock()
+	if frozen {
+		return fmt.Errorf("cannot register collector after Freeze")
+	}
+
+	if err := m.collectorRegisterer().Register(c); err != nil {
+		return fmt.Errorf("failed to register collector: %w", err)
+	}
+	return nil
+}
+
+// MustRegister is like RegisterCollector for several collectors but panics
+// on the first error
+func (m *Metrics) MustRegister(cs ...prometheus.Collector) {
+	for _, c := range cs {
+		if err := m.RegisterCollector(c); err != nil {
+			panic(err)
+		}
+	}
+}
+
+// collectorRegisterer wraps the registry with the namespace, subsystem and
+// ConstLabels of the config
+func (m *Metrics) collectorRegisterer() prometheus.Registerer {
+	var parts []string
+	for _, part := range []string{m.config.Namespace, m.config.Subsystem} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+
+	registerer := prometheus.WrapRegistererWith(m.config.ConstLabels, m.registry)
+	if len(parts) > 0 {
+		registerer = prometheus.WrapRegistererWithPrefix(strings.Join(parts, "_")+"_", registerer)
+	}
+	return registerer
+}
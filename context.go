@@ -0,0 +1,165 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsContextKey stores the Metrics in a context, see IntoContext
+type metricsContextKey struct{}
+
+// labelsContextKey stores the labels of a context, see WithLabels
+type labelsContextKey struct{}
+
+// IntoContext returns a copy of ctx carrying m, for code deeper in the call
+// stack that is not handed the collector:
+//
+//	ctx = metrics.IntoContext(ctx, m)
+//	...
+//	metrics.FromContext(ctx).IncrementCounter("orders_total", nil)
+func IntoContext(ctx context.Context, m *Metrics) context.Context {
+	return context.WithValue(ctx, metricsContextKey{}, m)
+}
+
+// WithLabels returns a copy of ctx carrying labels, which are attached to all
+// metrics recorded through FromContext with it or a context derived from it.
+// Labels of outer calls are kept unless overridden:
+//
+//	func tenantMiddleware(c *gin.Context) {
+//	    ctx := metrics.WithLabels(c.Request.Context(), metrics.MetricLabels{"tenant": tenantID(c)})
+//	    c.Request = c.Request.WithContext(ctx)
+//	    c.Next()
+//	}
+//
+// A metric must be recorded with the same label names everywhere, so record
+// it through FromContext with the labels set, or pass them explicitly.
+func WithLabels(ctx context.Context, labels MetricLabels) context.Context {
+	merged := make(MetricLabels, len(labels))
+	if outer, ok := contextValue(ctx, labelsContextKey{}).(MetricLabels); ok {
+		for k, v := range outer {
+			merged[k] = v
+		}
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, labelsContextKey{}, merged)
+}
+
+// ContextMetrics records metrics with the labels of a context attached. It
+// is returned by FromContext and does nothing if the context carries no
+// Metrics.
+type ContextMetrics struct {
+	m      *Metrics
+	labels MetricLabels
+}
+
+// FromContext returns the Metrics stored by IntoContext with the labels set
+// by WithLabels attached. ctx is the gin.Context or the request context.
+// Without Metrics in ctx, recording does nothing, so library code can record
+// unconditionally.
+func FromContext(ctx context.Context) *ContextMetrics {
+	m, _ := contextValue(ctx, metricsContextKey{}).(*Metrics)
+	labels, _ := contextValue(ctx, labelsContextKey{}).(MetricLabels)
+	return &ContextMetrics{m: m, labels: labels}
+}
+
+// contextValue returns the value of key in ctx, looking into the request
+// context of a gin.Context, or nil
+func contextValue(ctx context.Context, key any) any {
+	if ctx == nil {
+		return nil
+	}
+	if c, ok := ctx.(*gin.Context); ok {
+		if c.Request == nil {
+			return nil
+		}
+		ctx = c.Request.Context()
+	}
+	return ctx.Value(key)
+}
+
+// Metrics returns the collector of the context, or nil
+func (c *ContextMetrics) Metrics() *Metrics {
+	return c.m
+}
+
+// with returns labels with the context labels added; explicit labels win
+func (c *ContextMetrics) with(labels MetricLabels) MetricLabels {
+	if len(c.labels) == 0 {
+		return labels
+	}
+	merged := make(MetricLabels, len(c.labels)+len(labels))
+	for k, v := range c.labels {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
+
+// IncrementCounter increments a counter metric
+func (c *ContextMetrics) IncrementCounter(name string, labels MetricLabels) {
+	if c.m != nil {
+		c.m.IncrementCounter(name, c.with(labels))
+	}
+}
+
+// IncrementCounterBy increments a counter by a specific value
+func (c *ContextMetrics) IncrementCounterBy(name string, value float64, labels MetricLabels) {
+	if c.m != nil {
+		c.m.IncrementCounterBy(name, value, c.with(labels))
+	}
+}
+
+// SetGauge sets a gauge metric value
+func (c *ContextMetrics) SetGauge(name string, value float64, labels MetricLabels) {
+	if c.m != nil {
+		c.m.SetGauge(name, value, c.with(labels))
+	}
+}
+
+// IncrementGauge increments a gauge metric
+func (c *ContextMetrics) IncrementGauge(name string, labels MetricLabels) {
+	if c.m != nil {
+		c.m.IncrementGauge(name, c.with(labels))
+	}
+}
+
+// DecrementGauge decrements a gauge metric
+func (c *ContextMetrics) DecrementGauge(name string, labels MetricLabels) {
+	if c.m != nil {
+		c.m.DecrementGauge(name, c.with(labels))
+	}
+}
+
+// RecordHistogram records a histogram observation
+func (c *ContextMetrics) RecordHistogram(name string, value float64, labels MetricLabels) {
+	if c.m != nil {
+		c.m.RecordHistogram(name, value, c.with(labels))
+	}
+}
+
+// RecordDuration records a duration, see Metrics.RecordDuration
+func (c *ContextMetrics) RecordDuration(name string, d time.Duration, labels MetricLabels) {
+	if c.m != nil {
+		c.m.RecordDuration(name, d, c.with(labels))
+	}
+}
+
+// RecordHistogramWeighted records weight observations of value at once
+func (c *ContextMetrics) RecordHistogramWeighted(name string, value float64, weight uint64, labels MetricLabels) {
+	if c.m != nil {
+		c.m.RecordHistogramWeighted(name, value, weight, c.with(labels))
+	}
+}
+
+// RecordHistogramBatch records all values at once
+func (c *ContextMetrics) RecordHistogramBatch(name string, values []float64, labels MetricLabels) {
+	if c.m != nil {
+		c.m.RecordHistogramBatch(name, values, c.with(labels))
+	}
+}
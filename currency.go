@@ -0,0 +1,50 @@
+package metrics
+
+import "fmt"
+
+// CurrencyRateFunc returns the value of one unit of a currency in USD, e.g.
+// 1.08 for "EUR", or false if the rate is unknown. It is called for every
+// amount, so it should read cached rates rather than call a rates API.
+type CurrencyRateFunc func(currency string) (usd float64, ok bool)
+
+// DefaultAmountBuckets are the buckets of USD amount histograms
+var DefaultAmountBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// amountHistograms are the USD amount histograms by name, with their help
+var amountHistograms = []struct{ name, help string }{
+	{"payment_amount_usd", "Processed payment amounts normalized to USD"},
+	{"refund_amount_usd", "Refunded amounts normalized to USD"},
+}
+
+// registerAmountHistograms registers the USD amount histograms with
+// DefaultAmountBuckets, unless a previous helper did
+func (m *Metrics) registerAmountHistograms() {
+	for _, h := range amountHistograms {
+		m.ensureHistogram(h.name, h.help, []string{"provider"}, DefaultAmountBuckets)
+	}
+}
+
+// usd converts amount to USD with CurrencyRates. Unknown currencies are
+// reported as ErrUnknownCurrency for the metric name and return false.
+func (bm *BusinessMetrics) usd(name, currency string, amount float64) (float64, bool) {
+	rates := bm.m.config.CurrencyRates
+	if rates == nil {
+		return 0, false
+	}
+	if currency == "USD" {
+		return amount, true
+	}
+	rate, ok := rates(currency)
+	if !ok {
+		bm.m.handleError(name, fmt.Errorf("%w: %s", ErrUnknownCurrency, currency))
+		return 0, false
+	}
+	return amount * rate, true
+}
+
+// recordUSD observes amount converted to USD in a USD amount histogram
+func (bm *BusinessMetrics) recordUSD(name, provider, currency string, amount float64) {
+	if usd, ok := bm.usd(name, currency, amount); ok {
+		bm.m.RecordHistogram(name, usd, MetricLabels{"provider": provider})
+	}
+}
@@ -0,0 +1,144 @@
+package metrics
+
+import (
+	"html/template"
+	"net/http"
+	"slices"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// dashboardMetrics are the metrics plotted by the dashboard, which are
+// added to HistoryMetrics when EnableDashboard is set
+func (m *Metrics) dashboardMetrics() []string {
+	return []string{
+		"http_requests_total",
+		"http_request_duration" + m.config.DurationUnit.suffix(),
+		"http_requests_in_flight",
+		"websocket_connections_active",
+	}
+}
+
+// enableDashboardHistory adds the dashboard metrics to HistoryMetrics
+func (m *Metrics) enableDashboardHistory() {
+	names := slices.Clone(m.config.HistoryMetrics)
+	for _, name := range m.dashboardMetrics() {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	m.config.HistoryMetrics = names
+}
+
+// DashboardEndpoint returns a Gin handler serving a self-contained HTML page
+// with sparklines of request rate, error rate, p95 latency and connections.
+// The page reads /metrics/history, so it is served at /metrics/ui by Setup
+// when EnableDashboard is set.
+func (m *Metrics) DashboardEndpoint() gin.HandlerFunc {
+	fq := func(name string) string {
+		return prometheus.BuildFQName(m.config.Namespace, m.config.Subsystem, name)
+	}
+	names := m.dashboardMetrics()
+	data := map[string]any{
+		"Service": m.config.ServiceName,
+		"Names": map[string]string{
+			"requests": fq(names[0]),
+			"p95":      fq(names[1]) + "_p95",
+			"inFlight": fq(names[2]),
+			"sockets":  fq(names[3]),
+		},
+		"Unit": m.config.DurationUnit.suffix()[1:],
+	}
+
+	return func(c *gin.Context) {
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := dashboardTemplate.Execute(c.Writer, data); err != nil {
+			m.logf("Failed to render metrics dashboard: %v", err)
+		}
+	}
+}
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Service}} metrics</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2rem; background: #fafafa; color: #222; }
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(280px, 1fr)); gap: 1rem; }
+.card { background: #fff; border: 1px solid #ddd; border-radius: 6px; padding: 1rem; }
+.title { font-size: .85rem; color: #666; }
+.value { font-size: 1.6rem; margin: .25rem 0; }
+svg { width: 100%; height: 48px; }
+polyline { fill: none; stroke: #3366cc; stroke-width: 1.5; }
+</style>
+</head>
+<body>
+<h1>{{.Service}}</h1>
+<div class="grid" id="panels"></div>
+<p class="title" id="updated"></p>
+<script>
+const cfg = {names: {{.Names}}, unit: {{.Unit}}};
+
+// Sum all series per sample timestamp
+function totals(series, name, filter) {
+  const sums = new Map();
+  for (const s of series) {
+    if (s.name !== name || (filter && !filter(s.labels || {}))) continue;
+    for (const p of s.samples) sums.set(p.t, (sums.get(p.t) || 0) + p.v);
+  }
+  return [...sums.entries()].map(([t, v]) => ({t: new Date(t), v})).sort((a, b) => a.t - b.t);
+}
+
+// Per-second increase between consecutive samples of a counter
+function rate(points) {
+  const out = [];
+  for (let i = 1; i < points.length; i++) {
+    const dt = (points[i].t - points[i - 1].t) / 1000;
+    out.push({t: points[i].t, v: Math.max(points[i].v - points[i - 1].v, 0) / dt});
+  }
+  return out;
+}
+
+function sparkline(points) {
+  if (points.length < 2) return "";
+  const max = Math.max(...points.map(p => p.v)) || 1;
+  const step = 100 / (points.length - 1);
+  const coords = points.map((p, i) => (i * step).toFixed(1) + "," + (48 - p.v / max * 44 - 2).toFixed(1));
+  return '<svg viewBox="0 0 100 48" preserveAspectRatio="none"><polyline points="' + coords.join(" ") + '"/></svg>';
+}
+
+function panel(title, points, unit) {
+  const last = points.length ? points[points.length - 1].v : NaN;
+  const value = isNaN(last) ? "–" : last.toFixed(last < 10 ? 3 : 1) + " " + unit;
+  return '<div class="card"><div class="title">' + title + '</div><div class="value">' + value + '</div>' + sparkline(points) + '</div>';
+}
+
+async function refresh() {
+  try {
+    const res = await fetch("history");
+    const {series} = await res.json();
+    const n = cfg.names;
+    const inFlight = totals(series, n.inFlight);
+    const sockets = totals(series, n.sockets);
+    const connections = inFlight.map(p => ({t: p.t, v: p.v + (sockets.find(s => +s.t === +p.t) || {v: 0}).v}));
+    document.getElementById("panels").innerHTML = [
+      panel("Requests", rate(totals(series, n.requests)), "req/s"),
+      panel("Errors (5xx)", rate(totals(series, n.requests, l => /^5/.test(l.status || ""))), "req/s"),
+      panel("p95 latency", totals(series, n.p95), cfg.unit),
+      panel("Connections (in-flight + websocket)", connections.length ? connections : sockets, ""),
+    ].join("");
+    document.getElementById("updated").textContent = "Updated " + new Date().toLocaleTimeString();
+  } catch (err) {
+    document.getElementById("updated").textContent = "Failed to load history: " + err;
+  }
+}
+
+refresh();
+setInterval(refresh, 15000);
+</script>
+</body>
+</html>
+`))
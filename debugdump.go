@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// DumpOnSignal writes a debug dump (see WriteDebugDump) to path, or to stderr
+// if path is empty, whenever the process receives SIGUSR1 or SIGQUIT, until
+// ctx is cancelled. It is meant for pods that are dying before Prometheus
+// scraped them again:
+//
+//	kill -USR1 $(pidof orders)
+//
+// The file is overwritten by every dump. After dumping on SIGQUIT, the
+// signal is passed on, so the Go runtime still prints the goroutine stacks
+// and exits. It is started automatically when DebugDumpOnSignal is set.
+// Platforms without these signals, such as Windows, return an error.
+func (m *Metrics) DumpOnSignal(ctx context.Context, path string) error {
+	if len(debugDumpSignals) == 0 {
+		return fmt.Errorf("debug dumps on signals are not supported on this platform")
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, debugDumpSignals...)
+
+	go func() {
+		defer signal.Stop(signals)
+		m.handleDumpSignals(ctx, path, signals)
+	}()
+
+	return nil
+}
+
+// handleDumpSignals writes a dump for every received signal until ctx is
+// cancelled or a terminating signal was passed on
+func (m *Metrics) handleDumpSignals(ctx context.Context, path string, signals chan os.Signal) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case sig := <-signals:
+			if err := m.dumpTo(path, sig); err != nil {
+				m.logf("Failed to write debug dump: %v", err)
+			}
+			if terminatingSignal(sig) {
+				signal.Stop(signals)
+				reraise(sig)
+				return
+			}
+		}
+	}
+}
+
+// dumpTo writes a debug dump to path, or to stderr if path is empty
+func (m *Metrics) dumpTo(path string, sig os.Signal) error {
+	if path == "" {
+		fmt.Fprintf(os.Stderr, "# Received %v\n", sig)
+		return m.WriteDebugDump(os.Stderr)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(f, "# Received %v\n", sig)
+	if err := m.WriteDebugDump(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	m.logf("Wrote debug dump to %s", path)
+	return nil
+}
+
+// WriteDebugDump writes all metrics in the Prometheus text format, preceded
+// by an introspection of the registry as comments: the service, every metric
+// family with its type, series count, label names and owning team, and the
+// cardinality limits in effect. The dump stays parseable as text format, so
+// it can be loaded with promtool or the text parser of expfmt.
+func (m *Metrics) WriteDebugDump(w io.Writer) error {
+	families, gatherErr := m.gatherer().Gather()
+
+	series := 0
+	for _, mf := range families {
+		series += len(mf.GetMetric())
+	}
+
+	fmt.Fprintf(w, "# Debug dump of %s at %s\n", m.config.ServiceName, time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(w, "# namespace=%q subsystem=%q frozen=%t families=%d series=%d\n",
+		m.config.Namespace, m.config.Subsystem, m.Frozen(), len(families), series)
+	if gatherErr != nil {
+		fmt.Fprintf(w, "# gather error: %s\n", strings.ReplaceAll(gatherErr.Error(), "\n", "; "))
+	}
+	if m.config.MaxSeriesPerMetric > 0 || len(m.config.CardinalityLimits) > 0 {
+		fmt.Fprintf(w, "# cardinality limit=%d overrides=%v\n", m.config.MaxSeriesPerMetric, m.config.CardinalityLimits)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "# NAME\tTYPE\tSERIES\tLABELS\tOWNER")
+	for _, mf := range families {
+		owner := m.owners.get(mf.GetName())
+		if owner == "" {
+			owner = "-"
+		}
+		fmt.Fprintf(tw, "# %s\t%s\t%d\t%s\t%s\n", mf.GetName(), strings.ToLower(mf.GetType().String()),
+			len(mf.GetMetric()), strings.Join(familyLabelNames(mf), ","), owner)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+
+	enc := expfmt.NewEncoder(w, expfmt.NewFormat(expfmt.TypeTextPlain))
+	for _, mf := range families {
+		if err := enc.Encode(mf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// familyLabelNames returns the sorted label names used by any series of a
+// family, or "-" if there are none
+func familyLabelNames(mf *dto.MetricFamily) []string {
+	var names []string
+	for _, pm := range mf.GetMetric() {
+		for _, lp := range pm.GetLabel() {
+			if !slices.Contains(names, lp.GetName()) {
+				names = append(names, lp.GetName())
+			}
+		}
+	}
+	if len(names) == 0 {
+		return []string{"-"}
+	}
+	slices.Sort(names)
+	return names
+}
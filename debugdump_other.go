@@ -0,0 +1,16 @@
+//go:build !unix
+
+package metrics
+
+import "os"
+
+// debugDumpSignals is empty where SIGUSR1 and SIGQUIT do not exist
+var debugDumpSignals []os.Signal
+
+// terminatingSignal reports whether sig must be passed on after dumping
+func terminatingSignal(os.Signal) bool {
+	return false
+}
+
+// reraise does nothing without signals to pass on
+func reraise(os.Signal) {}
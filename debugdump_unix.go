@@ -0,0 +1,24 @@
+//go:build unix
+
+package metrics
+
+import (
+	"os"
+	"syscall"
+)
+
+// debugDumpSignals are the signals DumpOnSignal listens to
+var debugDumpSignals = []os.Signal{syscall.SIGUSR1, syscall.SIGQUIT}
+
+// terminatingSignal reports whether sig must be passed on after dumping
+func terminatingSignal(sig os.Signal) bool {
+	return sig == syscall.SIGQUIT
+}
+
+// reraise sends sig to the process again once it is no longer handled, so
+// the default action of the runtime applies
+func reraise(sig os.Signal) {
+	if s, ok := sig.(syscall.Signal); ok {
+		_ = syscall.Kill(os.Getpid(), s)
+	}
+}
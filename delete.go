@@ -0,0 +1,130 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DeleteCounter removes the counter series with exactly the given labels,
+// e.g. of a closed room, and reports whether it existed
+func (m *Metrics) DeleteCounter(name string, labels MetricLabels) bool {
+	m.mu.RLock()
+	counter, exists := m.counters[name]
+	m.mu.RUnlock()
+
+	if !exists {
+		return false
+	}
+
+	m.logEvent(EventSeriesDelete, name, 0, labels)
+	m.cardinality.forget(name, labels)
+	return counter.Delete(prometheus.Labels(labels))
+}
+
+// DeleteSeries removes the series of a counter, gauge or histogram with
+// exactly the given labels and reports whether it existed
+func (m *Metrics) DeleteSeries(name string, labels MetricLabels) bool {
+	m.logEvent(EventSeriesDelete, name, 0, labels)
+	return m.deleteSeries(name, labels)
+}
+
+// ResetMetric removes all series of a counter, gauge or histogram while
+// keeping it registered, and reports whether the metric exists
+func (m *Metrics) ResetMetric(name string) bool {
+	m.logEvent(EventMetricReset, name, 0, nil)
+	return m.resetMetric(name)
+}
+
+// Reset unregisters all custom metrics, including pre-registered ones, and
+// removes all series of the HTTP request metrics. In-flight gauges are kept
+// as requests may still be running. Mainly useful to isolate tests.
+//
+// Recreated metrics must keep their label keys, as the registry remembers
+// the label names of every metric name it has seen. Updates of a metric
+// recreated with other label keys fail.
+func (m *Metrics) Reset() {
+	m.logEvent(EventReset, "", 0, nil)
+	m.reset()
+}
+
+// deleteSeries removes a single series of any custom metric
+func (m *Metrics) deleteSeries(name string, labels MetricLabels) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	m.cardinality.forget(name, labels)
+
+	if counter, ok := m.counters[name]; ok {
+		return counter.Delete(prometheus.Labels(labels))
+	}
+	if gauge, ok := m.gauges[name]; ok {
+		return gauge.Delete(prometheus.Labels(labels))
+	}
+	if histogram, ok := m.histograms[name]; ok {
+		return histogram.Delete(prometheus.Labels(labels))
+	}
+	return false
+}
+
+// resetMetric removes all series of any custom metric
+func (m *Metrics) resetMetric(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	m.cardinality.forgetMetric(name)
+
+	if counter, ok := m.counters[name]; ok {
+		counter.Reset()
+		return true
+	}
+	if gauge, ok := m.gauges[name]; ok {
+		gauge.Reset()
+		return true
+	}
+	if histogram, ok := m.histograms[name]; ok {
+		histogram.Reset()
+		return true
+	}
+	return false
+}
+
+// reset unregisters all custom metrics and clears the HTTP metrics
+func (m *Metrics) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, counter := range m.counters {
+		m.registry.Unregister(counter)
+	}
+	for _, gauge := range m.gauges {
+		m.registry.Unregister(gauge)
+	}
+	for _, histogram := range m.histograms {
+		m.registry.Unregister(histogram)
+	}
+	if m.backfill != nil {
+		m.registry.Unregister(m.backfill)
+		m.backfill = nil
+	}
+
+	m.counters = make(map[string]*prometheus.CounterVec)
+	m.gauges = make(map[string]*prometheus.GaugeVec)
+	m.histograms = make(map[string]*prometheus.HistogramVec)
+	m.labelKeys = make(map[string]bool)
+	m.schemas = make(map[string][]string)
+	m.lookup.clear()
+	m.errLog.clear()
+	m.cardinality.clear()
+
+	if h := m.httpMetrics; h != nil {
+		h.RequestsTotal.Reset()
+		h.RequestDuration.Reset()
+		h.RequestSize.Reset()
+		h.ResponseSize.Reset()
+		h.HandlerPanics.Reset()
+		if h.OTel != nil {
+			h.OTel.RequestDuration.Reset()
+			h.OTel.RequestBodySize.Reset()
+			h.OTel.ResponseBodySize.Reset()
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// gaugeSampler accumulates the range of every gauge series between pushes,
+// so a long PushInterval does not hide spikes between two pushes
+type gaugeSampler struct {
+	mu     sync.Mutex
+	ranges map[string]*gaugeRange // By family name and labels
+}
+
+// gaugeRange is the minimum and maximum value of a gauge series sampled
+// since the last push
+type gaugeRange struct {
+	min, max float64
+}
+
+// newGaugeSampler creates an empty gauge sampler
+func newGaugeSampler() *gaugeSampler {
+	return &gaugeSampler{ranges: make(map[string]*gaugeRange)}
+}
+
+// startGaugeSampler samples the gauges every PushSampleInterval until ctx
+// is cancelled
+func (m *Metrics) startGaugeSampler(ctx context.Context) {
+	m.gaugeSampler = newGaugeSampler()
+
+	go func() {
+		ticker := time.NewTicker(m.config.PushSampleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				families, err := m.gatherer().Gather()
+				if err != nil {
+					m.logf("Failed to sample gauges: %v", err)
+				}
+				m.gaugeSampler.sample(families)
+			}
+		}
+	}()
+}
+
+// sample extends the ranges by the current values of the gauges
+func (s *gaugeSampler) sample(families []*dto.MetricFamily) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, mf := range families {
+		if mf.GetType() != dto.MetricType_GAUGE {
+			continue
+		}
+		for _, metric := range mf.GetMetric() {
+			value := metric.GetGauge().GetValue()
+			key := gaugeSeriesKey(mf.GetName(), metric.GetLabel())
+			r, ok := s.ranges[key]
+			if !ok {
+				s.ranges[key] = &gaugeRange{min: value, max: value}
+				continue
+			}
+			r.min = min(r.min, value)
+			r.max = max(r.max, value)
+		}
+	}
+}
+
+// downsample returns families with a <name>_min and <name>_max family added
+// for every gauge, holding the range sampled since the last push; the gauge
+// itself is the last value. The ranges are reset for the next push.
+func (s *gaugeSampler) downsample(families []*dto.MetricFamily) []*dto.MetricFamily {
+	// The value at push time is part of the range
+	s.sample(families)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := families
+	for _, mf := range families {
+		if mf.GetType() != dto.MetricType_GAUGE {
+			continue
+		}
+		minFamily := &dto.MetricFamily{
+			Name: proto.String(mf.GetName() + "_min"),
+			Help: proto.String(mf.GetHelp() + " (minimum since the last push)"),
+			Type: dto.MetricType_GAUGE.Enum(),
+		}
+		maxFamily := &dto.MetricFamily{
+			Name: proto.String(mf.GetName() + "_max"),
+			Help: proto.String(mf.GetHelp() + " (maximum since the last push)"),
+			Type: dto.MetricType_GAUGE.Enum(),
+		}
+		for _, metric := range mf.GetMetric() {
+			r, ok := s.ranges[gaugeSeriesKey(mf.GetName(), metric.GetLabel())]
+			if !ok {
+				continue
+			}
+			minFamily.Metric = append(minFamily.Metric, &dto.Metric{Label: metric.GetLabel(), Gauge: &dto.Gauge{Value: proto.Float64(r.min)}})
+			maxFamily.Metric = append(maxFamily.Metric, &dto.Metric{Label: metric.GetLabel(), Gauge: &dto.Gauge{Value: proto.Float64(r.max)}})
+		}
+		result = append(result, minFamily, maxFamily)
+	}
+
+	// Series that disappeared since are dropped along with the others
+	clear(s.ranges)
+	return result
+}
+
+// gaugeSeriesKey identifies a gathered series by family name and labels,
+// which are sorted by name
+func gaugeSeriesKey(name string, labels []*dto.LabelPair) string {
+	var b strings.Builder
+	b.WriteString(name)
+	for _, label := range labels {
+		b.WriteByte(0xff)
+		b.WriteString(label.GetName())
+		b.WriteByte(0xff)
+		b.WriteString(label.GetValue())
+	}
+	return b.String()
+}
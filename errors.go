@@ -0,0 +1,187 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+var (
+	// ErrLabelMismatch is returned when the label keys of an update differ
+	// from those the metric was created with
+	ErrLabelMismatch = errors.New("label keys do not match the metric")
+
+	// ErrMetricConflict is returned when the registry rejects a new metric,
+	// e.g. because its name is taken by a metric of another type
+	ErrMetricConflict = errors.New("metric conflicts with a registered metric")
+
+	// ErrFrozen is returned when a new metric is created after Freeze
+	ErrFrozen = errors.New("metric creation rejected after Freeze")
+
+	// ErrNegativeCounter is returned when a counter would decrease
+	ErrNegativeCounter = errors.New("counter cannot decrease")
+
+	// ErrInvalidLabels is returned for label values that are not valid
+	// UTF-8 and for invalid exemplars
+	ErrInvalidLabels = errors.New("invalid labels")
+
+	// ErrInvalidName is returned for metric names and label keys that do not
+	// match the Prometheus naming rules when StrictNames is set
+	ErrInvalidName = errors.New("invalid name")
+
+	// ErrInvalidEvent is returned when a business event does not match its
+	// definition, see DefineEvent
+	ErrInvalidEvent = errors.New("invalid event")
+
+	// ErrUnknownCurrency is reported when CurrencyRates has no rate for the
+	// currency of an amount
+	ErrUnknownCurrency = errors.New("unknown currency")
+)
+
+// TryIncrementCounter is like IncrementCounter but returns an error instead
+// of dropping the update
+func (m *Metrics) TryIncrementCounter(name string, labels MetricLabels) error {
+	return m.TryIncrementCounterBy(name, 1, labels)
+}
+
+// TryIncrementCounterBy is like IncrementCounterBy but returns an error
+// instead of dropping the update
+func (m *Metrics) TryIncrementCounterBy(name string, value float64, labels MetricLabels) error {
+	m.logEvent(EventCounterAdd, name, value, labels)
+	return m.countError(name, m.addCounter(name, value, labels, nil))
+}
+
+// TrySetGauge is like SetGauge but returns an error instead of dropping the
+// update
+func (m *Metrics) TrySetGauge(name string, value float64, labels MetricLabels) error {
+	m.logEvent(EventGaugeSet, name, value, labels)
+	return m.countError(name, m.setGauge(name, value, labels))
+}
+
+// TryIncrementGauge is like IncrementGauge but returns an error instead of
+// dropping the update
+func (m *Metrics) TryIncrementGauge(name string, labels MetricLabels) error {
+	m.logEvent(EventGaugeAdd, name, 1, labels)
+	return m.countError(name, m.addGauge(name, 1, labels))
+}
+
+// TryDecrementGauge is like DecrementGauge but returns an error instead of
+// dropping the update
+func (m *Metrics) TryDecrementGauge(name string, labels MetricLabels) error {
+	m.logEvent(EventGaugeAdd, name, -1, labels)
+	return m.countError(name, m.addGauge(name, -1, labels))
+}
+
+// TryRecordHistogram is like RecordHistogram but returns an error instead of
+// dropping the update
+func (m *Metrics) TryRecordHistogram(name string, value float64, labels MetricLabels) error {
+	m.logEvent(EventHistogramObserve, name, value, labels)
+	return m.countError(name, m.observeHistogram(name, value, labels, nil))
+}
+
+// validateUpdate returns labels with invalid keys sanitized, or an error for
+// labels or an exemplar that would make the Prometheus client panic
+func (m *Metrics) validateUpdate(labels, exemplar MetricLabels) (MetricLabels, error) {
+	for key, value := range labels {
+		if !utf8.ValidString(value) {
+			return nil, fmt.Errorf("%w: value of label %q is not valid UTF-8", ErrInvalidLabels, key)
+		}
+	}
+	labels, err := m.sanitizeLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	runes := 0
+	for key, value := range exemplar {
+		if !model.LabelName(key).IsValid() || !utf8.ValidString(value) {
+			return nil, fmt.Errorf("%w: invalid exemplar label %q", ErrInvalidLabels, key)
+		}
+		runes += utf8.RuneCountInString(key) + utf8.RuneCountInString(value)
+	}
+	if runes > prometheus.ExemplarMaxRunes {
+		return nil, fmt.Errorf("%w: exemplar exceeds %d runes", ErrInvalidLabels, prometheus.ExemplarMaxRunes)
+	}
+	return labels, nil
+}
+
+// errorReason returns the metrics_errors_total reason of an update error
+func errorReason(err error) string {
+	switch {
+	case errors.Is(err, ErrLabelMismatch):
+		return "label_mismatch"
+	case errors.Is(err, ErrMetricConflict):
+		return "conflict"
+	case errors.Is(err, ErrNegativeCounter):
+		return "negative_value"
+	case errors.Is(err, ErrInvalidLabels):
+		return "invalid_labels"
+	case errors.Is(err, ErrInvalidName):
+		return "invalid_name"
+	case errors.Is(err, ErrInvalidEvent):
+		return "invalid_event"
+	case errors.Is(err, ErrUnknownCurrency):
+		return "unknown_currency"
+	default:
+		return "other"
+	}
+}
+
+// countError counts a failed update in metrics_errors_total and returns err.
+// Rejections after Freeze are counted separately by rejectFrozen.
+func (m *Metrics) countError(name string, err error) error {
+	if err != nil && !errors.Is(err, ErrFrozen) {
+		m.internal.Errors.WithLabelValues(name, errorReason(err)).Inc()
+	}
+	return err
+}
+
+// handleError counts and logs a failed update, or panics with it if
+// PanicOnMetricError is set. Each distinct error is logged once to avoid
+// flooding the logs.
+func (m *Metrics) handleError(name string, err error) {
+	if m.countError(name, err) == nil || errors.Is(err, ErrFrozen) {
+		return
+	}
+	if m.config.PanicOnMetricError {
+		panic(err)
+	}
+	if m.errLog.first(name, err) {
+		m.logf("metrics: dropped update of %q: %v", name, err)
+	}
+}
+
+// errorLog remembers which update errors were already logged
+type errorLog struct {
+	logged map[string]bool // By metric name and error message
+	mu     sync.Mutex
+}
+
+// newErrorLog creates an empty error log
+func newErrorLog() *errorLog {
+	return &errorLog{logged: make(map[string]bool)}
+}
+
+// first reports whether err is logged for the first time for the metric
+func (l *errorLog) first(name string, err error) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := name + "\xff" + err.Error()
+	if l.logged[key] {
+		return false
+	}
+	l.logged[key] = true
+	return true
+}
+
+// clear forgets all logged errors
+func (l *errorLog) clear() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logged = make(map[string]bool)
+}
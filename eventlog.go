@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// EventOp identifies a recorded metric operation
+type EventOp string
+
+const (
+	EventCounterAdd       EventOp = "counter_add"
+	EventCounterAddAt     EventOp = "counter_add_at"
+	EventGaugeSet         EventOp = "gauge_set"
+	EventGaugeAdd         EventOp = "gauge_add"
+	EventHistogramObserve EventOp = "histogram_observe"
+	EventSeriesDelete     EventOp = "series_delete"
+	EventMetricReset      EventOp = "metric_reset"
+	EventReset            EventOp = "reset"
+)
+
+// Event is a single entry of the metrics event log
+type Event struct {
+	Timestamp time.Time    `json:"ts"`
+	Op        EventOp      `json:"op"`
+	Name      string       `json:"name"`
+	Value     float64      `json:"value"`
+	Labels    MetricLabels `json:"labels,omitempty"`
+	Weight    uint64       `json:"weight,omitempty"` // Observations of a histogram_observe event, 0 means 1
+	At        time.Time    `json:"at,omitzero"`      // Time of the backfilled event of a counter_add_at event
+}
+
+// logEvent appends an event to the event log if one is configured
+func (m *Metrics) logEvent(op EventOp, name string, value float64, labels MetricLabels) {
+	m.writeEvent(Event{Op: op, Name: name, Value: value, Labels: labels})
+}
+
+// writeEvent appends an event stamped with the current time to the event log
+// if one is configured
+func (m *Metrics) writeEvent(e Event) {
+	if m.eventLog == nil {
+		return
+	}
+
+	e.Timestamp = time.Now()
+	data, err := json.Marshal(e)
+	if err != nil {
+		m.logf("Failed to encode metrics event: %v", err)
+		return
+	}
+	data = append(data, '\n')
+
+	m.eventLogMu.Lock()
+	defer m.eventLogMu.Unlock()
+
+	if _, err := m.eventLog.Write(data); err != nil {
+		m.logf("Failed to write metrics event: %v", err)
+	}
+}
+
+// ReadEvents decodes an event log written via Config.EventLog and calls fn
+// for every event in order. Iteration stops at the first error returned by fn.
+func ReadEvents(r io.Reader, fn func(Event) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("failed to decode event on line %d: %w", line, err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read event log: %w", err)
+	}
+	return nil
+}
+
+// Replay applies every event of an event log to m in order, reconstructing
+// the recorded metric values. Replayed events are not written to m's own
+// event log.
+func (m *Metrics) Replay(r io.Reader) error {
+	return ReadEvents(r, m.applyEvent)
+}
+
+// applyEvent applies a single event without logging it
+func (m *Metrics) applyEvent(e Event) error {
+	switch e.Op {
+	case EventCounterAdd:
+		m.handleError(e.Name, m.addCounter(e.Name, e.Value, e.Labels, nil))
+	case EventCounterAddAt:
+		m.handleError(e.Name, m.addCounterAt(e.Name, e.Value, e.At, e.Labels))
+	case EventGaugeSet:
+		m.handleError(e.Name, m.setGauge(e.Name, e.Value, e.Labels))
+	case EventGaugeAdd:
+		m.handleError(e.Name, m.addGauge(e.Name, e.Value, e.Labels))
+	case EventHistogramObserve:
+		if e.Weight > 0 {
+			m.handleError(e.Name, m.observeHistogramWeighted(e.Name, e.Value, e.Weight, e.Labels))
+		} else {
+			m.handleError(e.Name, m.observeHistogram(e.Name, e.Value, e.Labels, nil))
+		}
+	case EventSeriesDelete:
+		m.deleteSeries(e.Name, e.Labels)
+	case EventMetricReset:
+		m.resetMetric(e.Name)
+	case EventReset:
+		m.reset()
+	default:
+		return fmt.Errorf("unknown event op %q", e.Op)
+	}
+	return nil
+}
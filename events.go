@@ -0,0 +1,193 @@
+package metrics
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+
+	"github.com/prometheus/common/model"
+)
+
+// EventFieldKind is how a field of a business event is recorded
+type EventFieldKind int
+
+const (
+	// EventLabelField is a string recorded as a label of all metrics of the
+	// event
+	EventLabelField EventFieldKind = iota
+	// EventSumField is a number added to the counter <event>_<field>_total
+	EventSumField
+	// EventHistogramField is a number observed in the histogram
+	// <event>_<field>
+	EventHistogramField
+)
+
+// EventField is a field of a business event, see DefineEvent
+type EventField struct {
+	Name string
+	Kind EventFieldKind
+}
+
+// EventLabel declares a string field recorded as a label
+func EventLabel(name string) EventField {
+	return EventField{Name: name, Kind: EventLabelField}
+}
+
+// EventSum declares a numeric field summed up in a counter, e.g. revenue
+func EventSum(name string) EventField {
+	return EventField{Name: name, Kind: EventSumField}
+}
+
+// EventHistogram declares a numeric field observed in a histogram, e.g. the
+// number of items per order
+func EventHistogram(name string) EventField {
+	return EventField{Name: name, Kind: EventHistogramField}
+}
+
+// eventSchemas holds the business events defined with DefineEvent
+type eventSchemas struct {
+	mu     sync.RWMutex
+	events map[string][]EventField
+}
+
+// newEventSchemas creates an empty set of event definitions
+func newEventSchemas() *eventSchemas {
+	return &eventSchemas{events: make(map[string][]EventField)}
+}
+
+// DefineEvent declares a business event and its fields. RecordEvent then
+// validates the recorded values against them, so the metrics of an event
+// keep the same names, labels and types across call sites and services:
+//
+//	m.DefineEvent("purchase",
+//	    metrics.EventLabel("plan"),
+//	    metrics.EventLabel("currency"),
+//	    metrics.EventSum("amount"),
+//	    metrics.EventHistogram("items"),
+//	)
+//
+// Every event counts <event>_total with the label fields as labels. Defining
+// an event again with other fields is an error.
+func (m *Metrics) DefineEvent(name string, fields ...EventField) error {
+	if !model.IsValidLegacyMetricName(name) {
+		return fmt.Errorf("%w: event name %q must match %s", ErrInvalidName, name, metricNamePattern)
+	}
+	seen := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		if !model.LabelName(field.Name).IsValidLegacy() {
+			return fmt.Errorf("%w: field %q of event %s must match %s", ErrInvalidName, field.Name, name, labelNamePattern)
+		}
+		if seen[field.Name] {
+			return fmt.Errorf("%w: field %q of event %s is declared twice", ErrInvalidEvent, field.Name, name)
+		}
+		seen[field.Name] = true
+	}
+
+	e := m.events
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if defined, ok := e.events[name]; ok {
+		if !slices.Equal(defined, fields) {
+			return fmt.Errorf("%w: event %s is already defined with fields %v", ErrInvalidEvent, name, defined)
+		}
+		return nil
+	}
+	e.events[name] = slices.Clone(fields)
+	return nil
+}
+
+// RecordEvent records a business event defined with DefineEvent. values
+// must hold every field: strings for label fields, numbers for the others.
+// Unknown events, unknown or missing fields and values of the wrong type are
+// rejected with ErrInvalidEvent and nothing is recorded:
+//
+//	err := m.RecordEvent("purchase", map[string]any{
+//	    "plan": "pro", "currency": "EUR", "amount": 49.0, "items": 3,
+//	})
+func (m *Metrics) RecordEvent(name string, values map[string]any) error {
+	e := m.events
+	e.mu.RLock()
+	fields, ok := e.events[name]
+	e.mu.RUnlock()
+	if !ok {
+		return m.countError(name, fmt.Errorf("%w: event %s is not defined", ErrInvalidEvent, name))
+	}
+
+	labels, numbers, err := eventValues(name, fields, values)
+	if err != nil {
+		return m.countError(name, err)
+	}
+
+	if err := m.TryIncrementCounter(name+"_total", labels); err != nil {
+		return err
+	}
+	for _, field := range fields {
+		var err error
+		switch field.Kind {
+		case EventSumField:
+			err = m.TryIncrementCounterBy(name+"_"+field.Name+"_total", numbers[field.Name], labels)
+		case EventHistogramField:
+			err = m.TryRecordHistogram(name+"_"+field.Name, numbers[field.Name], labels)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eventValues validates values against the fields of an event and splits
+// them into labels and numbers
+func eventValues(name string, fields []EventField, values map[string]any) (MetricLabels, map[string]float64, error) {
+	if len(values) > len(fields) {
+		for key := range values {
+			if !slices.ContainsFunc(fields, func(f EventField) bool { return f.Name == key }) {
+				return nil, nil, fmt.Errorf("%w: event %s has no field %q", ErrInvalidEvent, name, key)
+			}
+		}
+	}
+
+	labels := make(MetricLabels)
+	numbers := make(map[string]float64)
+	for _, field := range fields {
+		value, ok := values[field.Name]
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: field %q of event %s is missing", ErrInvalidEvent, field.Name, name)
+		}
+
+		if field.Kind == EventLabelField {
+			s, ok := value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("%w: field %q of event %s must be a string, got %T", ErrInvalidEvent, field.Name, name, value)
+			}
+			labels[field.Name] = s
+			continue
+		}
+
+		number, ok := eventNumber(value)
+		if !ok {
+			return nil, nil, fmt.Errorf("%w: field %q of event %s must be a number, got %T", ErrInvalidEvent, field.Name, name, value)
+		}
+		if field.Kind == EventSumField && number < 0 {
+			return nil, nil, fmt.Errorf("%w: field %q of event %s is summed up and cannot be negative", ErrInvalidEvent, field.Name, name)
+		}
+		numbers[field.Name] = number
+	}
+	return labels, numbers, nil
+}
+
+// eventNumber converts an integer or float of any size to float64
+func eventNumber(value any) (float64, bool) {
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// IncrementCounterWithExemplar increments a counter by value and attaches an
+// exemplar such as {"trace_id": "..."}. Exemplars are exposed when scraping
+// with OpenMetrics, which Handler enables.
+func (m *Metrics) IncrementCounterWithExemplar(name string, value float64, labels, exemplar MetricLabels) {
+	m.logEvent(EventCounterAdd, name, value, labels)
+	m.handleError(name, m.addCounter(name, value, labels, exemplar))
+}
+
+// RecordHistogramWithExemplar records a histogram observation and attaches an
+// exemplar such as {"trace_id": "..."}
+func (m *Metrics) RecordHistogramWithExemplar(name string, value float64, labels, exemplar MetricLabels) {
+	m.logEvent(EventHistogramObserve, name, value, labels)
+	m.handleError(name, m.observeHistogram(name, value, labels, exemplar))
+}
+
+// TraceExemplar returns a trace_id exemplar for the OpenTelemetry span in
+// ctx, or nil if ctx carries no sampled span
+func TraceExemplar(ctx context.Context) MetricLabels {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() || !sc.IsSampled() {
+		return nil
+	}
+	return MetricLabels{"trace_id": sc.TraceID().String()}
+}
+
+// requestExemplar returns a trace_id exemplar for a request, taken from the
+// OpenTelemetry span in its context or else from a W3C traceparent header
+func requestExemplar(r *http.Request) MetricLabels {
+	if exemplar := TraceExemplar(r.Context()); exemplar != nil {
+		return exemplar
+	}
+
+	// traceparent: version-traceid-parentid-flags, bit 0 of flags is sampled
+	parts := strings.Split(r.Header.Get("traceparent"), "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return nil
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || flags&1 == 0 {
+		return nil
+	}
+	traceID, err := trace.TraceIDFromHex(parts[1])
+	if err != nil {
+		return nil
+	}
+	return MetricLabels{"trace_id": traceID.String()}
+}
+
+// observe records an observation, attaching the exemplar if not nil
+func observe(o prometheus.Observer, value float64, exemplar MetricLabels) {
+	if eo, ok := o.(prometheus.ExemplarObserver); ok && len(exemplar) > 0 {
+		eo.ObserveWithExemplar(value, prometheus.Labels(exemplar))
+		return
+	}
+	o.Observe(value)
+}
+
+// add increments a counter by value, attaching the exemplar if not nil
+func add(c prometheus.Counter, value float64, exemplar MetricLabels) {
+	if adder, ok := c.(prometheus.ExemplarAdder); ok && len(exemplar) > 0 {
+		adder.AddWithExemplar(value, prometheus.Labels(exemplar))
+		return
+	}
+	c.Add(value)
+}
@@ -0,0 +1,150 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// pushFailover switches Grafana Cloud pushes to FallbackRemoteWrite after
+// FailoverAfter consecutive failures, and back once a probe of the primary
+// endpoint succeeds
+type pushFailover struct {
+	fallback  RemoteWriteTarget
+	threshold int
+	interval  time.Duration
+
+	mu        sync.Mutex
+	failures  int  // Consecutive failures of the primary endpoint
+	active    bool // Pushing to the fallback
+	lastProbe time.Time
+
+	Active    prometheus.Gauge
+	Failovers prometheus.Counter
+}
+
+// initFailover enables failover if FallbackRemoteWrite is set
+func (m *Metrics) initFailover() {
+	threshold := m.config.FailoverAfter
+	if threshold <= 0 {
+		threshold = 3
+	}
+	interval := m.config.FailbackInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	m.failover = &pushFailover{
+		fallback:  m.config.FallbackRemoteWrite,
+		threshold: threshold,
+		interval:  interval,
+		Active: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace:   m.config.Namespace,
+			Subsystem:   m.config.Subsystem,
+			Name:        "metrics_push_failover_active",
+			Help:        "1 while pushes go to the fallback remote write endpoint",
+			ConstLabels: m.config.ConstLabels,
+		}),
+		Failovers: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace:   m.config.Namespace,
+			Subsystem:   m.config.Subsystem,
+			Name:        "metrics_push_failovers_total",
+			Help:        "Switches from the primary to the fallback remote write endpoint",
+			ConstLabels: m.config.ConstLabels,
+		}),
+	}
+	m.registry.MustRegister(m.failover.Active, m.failover.Failovers)
+}
+
+// sendRemoteWrite sends a compressed remote write request to target. Requests
+// to GrafanaCloudURL fail over to FallbackRemoteWrite, if set: after
+// FailoverAfter consecutive failures pushes go to the fallback, and every
+// FailbackInterval one push is tried on the primary first, switching back if
+// it succeeds.
+func (m *Metrics) sendRemoteWrite(ctx context.Context, target RemoteWriteTarget, body remoteWriteBody) error {
+	f := m.failover
+	if f == nil || target.URL != m.config.GrafanaCloudURL {
+		return m.postRemoteWrite(ctx, target, body)
+	}
+
+	if f.probe() {
+		err := m.postRemoteWrite(ctx, target, body)
+		if err == nil {
+			if f.succeeded() {
+				m.logf("Primary remote write endpoint recovered, failing back to %s", m.redact(target.URL))
+			}
+			return nil
+		}
+		if f.failed(err) {
+			m.logf("Primary remote write endpoint failed %d times, failing over to %s: %v",
+				f.threshold, m.redact(f.fallback.URL), m.redactError(err))
+		} else if !f.isActive() {
+			return err
+		}
+	}
+	return m.postRemoteWrite(ctx, f.fallback, body)
+}
+
+// probe reports whether the next push should try the primary endpoint:
+// always before failover, and once per interval after
+func (f *pushFailover) probe() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if !f.active {
+		return true
+	}
+	if time.Since(f.lastProbe) < f.interval {
+		return false
+	}
+	f.lastProbe = time.Now()
+	return true
+}
+
+// succeeded records a successful push to the primary endpoint, returning
+// true if it ends a failover
+func (f *pushFailover) succeeded() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures = 0
+	if !f.active {
+		return false
+	}
+	f.active = false
+	f.Active.Set(0)
+	return true
+}
+
+// failed records a failed push to the primary endpoint, returning true if it
+// starts a failover. Permanent rejections such as invalid samples are not
+// counted, as the fallback would reject them as well.
+func (f *pushFailover) failed(err error) bool {
+	var rejected *remoteWriteError
+	if errors.As(err, &rejected) && rejected.permanent() {
+		return false
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.failures++
+	if f.active || f.failures < f.threshold {
+		return false
+	}
+	f.active = true
+	f.lastProbe = time.Now()
+	f.Active.Set(1)
+	f.Failovers.Inc()
+	return true
+}
+
+// isActive reports whether pushes go to the fallback
+func (f *pushFailover) isActive() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.active
+}
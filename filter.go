@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HTTPMetricsFilter excludes requests from HTTP metrics. Filters from
+// Config.HTTPMetricsFilter apply to every middleware and are combined with
+// the filter of MiddlewareOptions.
+type HTTPMetricsFilter struct {
+	SkipPaths        []string // Exact request paths, e.g. "/health"
+	SkipPathPrefixes []string // Request path prefixes, e.g. "/static/"
+	SkipMethods      []string // Request methods, e.g. "OPTIONS"
+	SkipStatuses     []int    // Response status codes, e.g. 404
+
+	// RouteSampleRates overrides HTTPSampleRate per route template, e.g.
+	// {"/ping": 0.01} to downsample a high-traffic route. Like
+	// HTTPSampleRate it only affects histograms; counts stay exact.
+	RouteSampleRates map[string]float64
+}
+
+// MiddlewareOption configures Middleware
+type MiddlewareOption func(*MiddlewareOptions)
+
+// WithSkipPaths excludes exact request paths
+func WithSkipPaths(paths ...string) MiddlewareOption {
+	return func(opts *MiddlewareOptions) {
+		opts.Filter.SkipPaths = append(opts.Filter.SkipPaths, paths...)
+	}
+}
+
+// WithSkipPathPrefixes excludes request paths starting with any prefix
+func WithSkipPathPrefixes(prefixes ...string) MiddlewareOption {
+	return func(opts *MiddlewareOptions) {
+		opts.Filter.SkipPathPrefixes = append(opts.Filter.SkipPathPrefixes, prefixes...)
+	}
+}
+
+// WithSkipMethods excludes request methods
+func WithSkipMethods(methods ...string) MiddlewareOption {
+	return func(opts *MiddlewareOptions) {
+		opts.Filter.SkipMethods = append(opts.Filter.SkipMethods, methods...)
+	}
+}
+
+// WithSkipStatuses excludes responses with any of the status codes
+func WithSkipStatuses(codes ...int) MiddlewareOption {
+	return func(opts *MiddlewareOptions) {
+		opts.Filter.SkipStatuses = append(opts.Filter.SkipStatuses, codes...)
+	}
+}
+
+// WithRouteSampleRate observes only a fraction of the requests to a route
+// template in HTTP histograms
+func WithRouteSampleRate(route string, rate float64) MiddlewareOption {
+	return func(opts *MiddlewareOptions) {
+		if opts.Filter.RouteSampleRates == nil {
+			opts.Filter.RouteSampleRates = make(map[string]float64)
+		}
+		opts.Filter.RouteSampleRates[route] = rate
+	}
+}
+
+// httpFilter is the combination of HTTPMetricsFilters used by a middleware
+type httpFilter struct {
+	paths    map[string]bool
+	prefixes []string
+	methods  map[string]bool
+	statuses map[int]bool
+	rates    map[string]float64
+}
+
+// newHTTPFilter combines filters, later sample rates taking precedence
+func newHTTPFilter(filters ...HTTPMetricsFilter) *httpFilter {
+	f := &httpFilter{
+		paths:    make(map[string]bool),
+		methods:  make(map[string]bool),
+		statuses: make(map[int]bool),
+		rates:    make(map[string]float64),
+	}
+	for _, filter := range filters {
+		for _, path := range filter.SkipPaths {
+			f.paths[path] = true
+		}
+		f.prefixes = append(f.prefixes, filter.SkipPathPrefixes...)
+		for _, method := range filter.SkipMethods {
+			f.methods[strings.ToUpper(method)] = true
+		}
+		for _, code := range filter.SkipStatuses {
+			f.statuses[code] = true
+		}
+		for route, rate := range filter.RouteSampleRates {
+			f.rates[route] = rate
+		}
+	}
+	return f
+}
+
+// skip reports whether requests with method and path are excluded
+func (f *httpFilter) skip(method, path string) bool {
+	if f.paths[path] || f.methods[method] {
+		return true
+	}
+	for _, prefix := range f.prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipRequest reports whether a request is excluded before it is handled
+func (f *httpFilter) skipRequest(c *gin.Context) bool {
+	return f.skip(c.Request.Method, c.Request.URL.Path)
+}
+
+// skipStatus reports whether a response with the status code is excluded
+func (f *httpFilter) skipStatus(code int) bool {
+	return f.statuses[code]
+}
+
+// sampleRate returns the histogram sample rate of a route template
+func (f *httpFilter) sampleRate(route string, fallback float64) float64 {
+	if rate, ok := f.rates[route]; ok {
+		return rate
+	}
+	return fallback
+}
+
+// WithPanicHandling sets how handler panics are handled, see PanicHandling
+func WithPanicHandling(panics PanicHandling) MiddlewareOption {
+	return func(opts *MiddlewareOptions) {
+		opts.Panics = panics
+	}
+}
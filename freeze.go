@@ -0,0 +1,38 @@
+package metrics
+
+// Freeze fixes the set of custom metrics. Afterwards, attempts to create a
+// metric with a new name are rejected, logged and counted in
+// metrics_frozen_rejections_total. New label values of existing metrics are
+// still accepted.
+func (m *Metrics) Freeze() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.frozen = true
+	m.frozenRejected = make(map[string]bool)
+}
+
+// Frozen reports whether Freeze has been called
+func (m *Metrics) Frozen() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.frozen
+}
+
+// rejectFrozen reports whether creating the named metric must be rejected.
+// Must be called with m.mu held.
+func (m *Metrics) rejectFrozen(name string) bool {
+	if !m.frozen {
+		return false
+	}
+
+	m.internal.FrozenRejections.WithLabelValues(name).Inc()
+
+	// Log only the first rejection per name to avoid flooding the logs
+	if !m.frozenRejected[name] {
+		m.frozenRejected[name] = true
+		m.logf("metrics: rejected creation of metric %q after Freeze", name)
+	}
+	return true
+}
@@ -0,0 +1,158 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// gaugeHistograms holds the gauge histograms of a Metrics and its scopes.
+// The registry can only expose histograms, so they are gathered separately
+// and merged with the registry by gatherer.
+type gaugeHistograms struct {
+	byName map[string]*gaugeHistogram // By fully-qualified name
+	mu     sync.RWMutex
+}
+
+// gaugeHistogram is a distribution of current states computed on gather
+type gaugeHistogram struct {
+	name        string
+	help        string
+	buckets     []float64
+	constLabels prometheus.Labels
+	snapshot    func() []float64
+}
+
+// newGaugeHistograms creates an empty set of gauge histograms
+func newGaugeHistograms() *gaugeHistograms {
+	return &gaugeHistograms{byName: make(map[string]*gaugeHistogram)}
+}
+
+// RegisterGaugeHistogram declares a gauge histogram, the OpenMetrics type
+// for distributions of current states such as the sizes of all rooms right
+// now. snapshot returns the current values and is called on every gather;
+// buckets nil uses the defaults. Gauge histograms are exposed by Handler
+// and pushed with remote write, but not exported over OTLP, which has no
+// equivalent type, nor returned by Registry().Gather.
+func (m *Metrics) RegisterGaugeHistogram(name, help string, buckets []float64, snapshot func() []float64) error {
+	if snapshot == nil {
+		return fmt.Errorf("metric %q: snapshot function is required", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRegistration(name, help, nil); err != nil {
+		return err
+	}
+	if buckets == nil {
+		buckets = defaultBuckets(name)
+	}
+
+	fqName := prometheus.BuildFQName(m.config.Namespace, m.config.Subsystem, name)
+
+	// Reserve the name in the registry, so it cannot be taken by another
+	// metric on the same registry
+	desc := prometheus.NewDesc(fqName, help, nil, m.config.ConstLabels)
+	if err := m.registry.Register(descCollector{desc}); err != nil {
+		return fmt.Errorf("failed to register gauge histogram %q: %w", name, err)
+	}
+
+	m.own(name)
+
+	g := m.gaugeHistograms
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.byName[fqName] = &gaugeHistogram{
+		name:        fqName,
+		help:        help,
+		buckets:     slices.Sorted(slices.Values(buckets)),
+		constLabels: m.config.ConstLabels,
+		snapshot:    snapshot,
+	}
+	return nil
+}
+
+// Gather computes all gauge histograms from their snapshots
+func (g *gaugeHistograms) Gather() ([]*dto.MetricFamily, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	families := make([]*dto.MetricFamily, 0, len(g.byName))
+	for _, h := range g.byName {
+		families = append(families, h.family())
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].GetName() < families[j].GetName() })
+	return families, nil
+}
+
+// family computes the metric family from the current snapshot
+func (h *gaugeHistogram) family() *dto.MetricFamily {
+	values := h.snapshot()
+
+	counts := make([]uint64, len(h.buckets))
+	var sum float64
+	for _, v := range values {
+		sum += v
+		// Buckets are cumulative, so a value counts in every bucket it fits
+		for i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets); i++ {
+			counts[i]++
+		}
+	}
+
+	buckets := make([]*dto.Bucket, 0, len(h.buckets))
+	for i, bound := range h.buckets {
+		if math.IsInf(bound, 1) {
+			continue
+		}
+		buckets = append(buckets, &dto.Bucket{
+			UpperBound:      proto.Float64(bound),
+			CumulativeCount: proto.Uint64(counts[i]),
+		})
+	}
+
+	labels := make([]*dto.LabelPair, 0, len(h.constLabels))
+	for name, value := range h.constLabels {
+		labels = append(labels, &dto.LabelPair{Name: proto.String(name), Value: proto.String(value)})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+
+	return &dto.MetricFamily{
+		Name: proto.String(h.name),
+		Help: proto.String(h.help),
+		Type: dto.MetricType_GAUGE_HISTOGRAM.Enum(),
+		Metric: []*dto.Metric{{
+			Label: labels,
+			Histogram: &dto.Histogram{
+				SampleCount: proto.Uint64(uint64(len(values))),
+				SampleSum:   proto.Float64(sum),
+				Bucket:      buckets,
+			},
+		}},
+	}
+}
+
+// descCollector describes a metric without collecting it
+type descCollector struct {
+	desc *prometheus.Desc
+}
+
+func (c descCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+func (c descCollector) Collect(chan<- prometheus.Metric)    {}
+
+// gatherer returns the registry merged with the gauge histograms, and the
+// old names of renamed metrics during their transition
+func (m *Metrics) gatherer() prometheus.Gatherer {
+	g := prometheus.Gatherer(prometheus.Gatherers{m.registry, m.gaugeHistograms})
+	if len(m.config.MetricRenames) > 0 {
+		g = m.renameGatherer(g)
+	}
+	return g
+}
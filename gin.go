@@ -1,9 +1,7 @@
 package metrics
 
 import (
-	"net/http"
 	"sync"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -14,73 +12,40 @@ var (
 )
 
 // Setup registers metrics and health endpoints on the Gin router
-// Call this before adding your routes
+// Call this before adding your routes. With HTTPRouteWatchInterval set,
+// routes added later are picked up while the application runs.
 func (m *Metrics) Setup(router *gin.Engine) {
+	m.mu.Lock()
+	m.router = router
+	m.mu.Unlock()
+
+	if m.config.HTTPRouteWatchInterval > 0 {
+		m.WatchRoutes(router, m.config.HTTPRouteWatchInterval)
+	}
+
 	setupOnce.Do(func() {
 		if m.config.EnableMetricsEndpoint {
 			router.GET("/metrics", m.MetricsEndpoint())
+			if len(m.config.HistoryMetrics) > 0 {
+				router.GET("/metrics/history", m.HistoryEndpoint())
+			}
+			if m.config.EnableDashboard {
+				router.GET("/metrics/ui", m.DashboardEndpoint())
+			}
 		}
 		if m.config.EnableHealthEndpoint {
 			router.GET("/health", m.HealthEndpoint())
+			router.GET("/ready", m.ReadyEndpoint())
 		}
 		setupDone = true
 	})
 }
 
-// GinMiddleware returns a Gin middleware for automatic metrics collection
-func (m *Metrics) GinMiddleware() gin.HandlerFunc {
-	if !m.config.EnableHTTPMetrics {
-		// Return a no-op middleware if HTTP metrics are disabled
-		return func(c *gin.Context) {
-			c.Next()
-		}
-	}
-
-	return func(c *gin.Context) {
-		// Skip metrics endpoint itself
-		if c.Request.URL.Path == "/metrics" || c.Request.URL.Path == "/health" {
-			c.Next()
-			return
-		}
-
-		start := time.Now()
-
-		// Increment in-flight requests
-		m.httpMetrics.RequestsInFlight.Inc()
-		defer m.httpMetrics.RequestsInFlight.Dec()
-
-		// Record request size
-		if c.Request.ContentLength > 0 {
-			m.httpMetrics.RequestSize.WithLabelValues(
-				c.Request.Method,
-				c.FullPath(),
-			).Observe(float64(c.Request.ContentLength))
-		}
-
-		// Process request
-		c.Next()
-
-		// Calculate duration
-		duration := time.Since(start).Seconds()
-
-		// Get status code
-		status := c.Writer.Status()
-
-		// Record metrics
-		labels := []string{c.Request.Method, c.FullPath(), http.StatusText(status)}
-
-		m.httpMetrics.RequestsTotal.WithLabelValues(labels...).Inc()
-		m.httpMetrics.RequestDuration.WithLabelValues(labels...).Observe(duration)
-
-		// Record response size
-		responseSize := c.Writer.Size()
-		if responseSize > 0 {
-			m.httpMetrics.ResponseSize.WithLabelValues(
-				c.Request.Method,
-				c.FullPath(),
-			).Observe(float64(responseSize))
-		}
-	}
+// setupRouter returns the router passed to Setup, if any
+func (m *Metrics) setupRouter() *gin.Engine {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.router
 }
 
 // MetricsEndpoint returns a Gin handler for the /metrics endpoint
@@ -94,9 +59,16 @@ func (m *Metrics) MetricsEndpoint() gin.HandlerFunc {
 // HealthEndpoint returns a Gin handler for the /health endpoint
 func (m *Metrics) HealthEndpoint() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "ok",
-			"service": m.config.ServiceName,
-		})
+		report := m.health.Check(c.Request.Context())
+		c.JSON(report.HTTPStatus(), report)
+	}
+}
+
+// ReadyEndpoint returns a Gin handler for the /ready endpoint, which fails
+// while a critical health check keeps failing
+func (m *Metrics) ReadyEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := m.health.Check(c.Request.Context())
+		c.JSON(report.ReadyStatus(), report)
 	}
 }
@@ -0,0 +1,90 @@
+// Package gormmetrics wires metrics.DatabaseMetrics into GORM through a
+// plugin recording every statement.
+//
+//	db.Use(gormmetrics.NewPlugin(m.NewDatabaseMetrics()))
+package gormmetrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/OkanUysal/go-metrics"
+	"gorm.io/gorm"
+)
+
+const startKey = "metrics:start"
+
+// Plugin is a GORM plugin that records every Create, Query, Update, Delete,
+// Row and Raw statement through DatabaseMetrics.TableQueryExecuted
+type Plugin struct {
+	dm *metrics.DatabaseMetrics
+}
+
+// NewPlugin creates a GORM plugin, register it with db.Use
+func NewPlugin(dm *metrics.DatabaseMetrics) *Plugin {
+	return &Plugin{dm: dm}
+}
+
+// Name implements gorm.Plugin
+func (p *Plugin) Name() string {
+	return "metrics"
+}
+
+// registerer registers a callback relative to a GORM processor step
+type registerer interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// Initialize implements gorm.Plugin
+func (p *Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	hooks := []struct {
+		operation     string
+		before, after registerer
+	}{
+		{"create", cb.Create().Before("gorm:create"), cb.Create().After("gorm:create")},
+		{"query", cb.Query().Before("gorm:query"), cb.Query().After("gorm:query")},
+		{"update", cb.Update().Before("gorm:update"), cb.Update().After("gorm:update")},
+		{"delete", cb.Delete().Before("gorm:delete"), cb.Delete().After("gorm:delete")},
+		{"row", cb.Row().Before("gorm:row"), cb.Row().After("gorm:row")},
+		{"raw", cb.Raw().Before("gorm:raw"), cb.Raw().After("gorm:raw")},
+	}
+
+	for _, h := range hooks {
+		if err := h.before.Register("metrics:before_"+h.operation, p.before); err != nil {
+			return err
+		}
+		if err := h.after.Register("metrics:after_"+h.operation, p.after(h.operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// before remembers the statement start time
+func (p *Plugin) before(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+// after records the statement once it was executed
+func (p *Plugin) after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		v, ok := db.InstanceGet(startKey)
+		if !ok {
+			return
+		}
+		start, ok := v.(time.Time)
+		if !ok {
+			return
+		}
+
+		// A missing record is a regular query result, not a failure
+		success := db.Error == nil || errors.Is(db.Error, gorm.ErrRecordNotFound)
+
+		elapsed := time.Since(start)
+		p.dm.TableQueryExecuted(operation, db.Statement.Table, elapsed.Seconds(), db.RowsAffected, success)
+		metrics.AddRequestTime(db.Statement.Context, metrics.ComponentDB, elapsed)
+	}
+}
+
+var _ gorm.Plugin = (*Plugin)(nil)
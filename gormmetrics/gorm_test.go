@@ -0,0 +1,70 @@
+package gormmetrics
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/OkanUysal/go-metrics"
+	"github.com/OkanUysal/go-metrics/metricstest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// testDialector is a minimal GORM dialector for dry-run statements
+type testDialector struct{}
+
+func (testDialector) Name() string { return "test" }
+func (testDialector) Initialize(db *gorm.DB) error {
+	callbacks.RegisterDefaultCallbacks(db, &callbacks.Config{})
+	return nil
+}
+func (testDialector) Migrator(db *gorm.DB) gorm.Migrator                  { return nil }
+func (testDialector) DataTypeOf(*schema.Field) string                     { return "" }
+func (testDialector) DefaultValueOf(*schema.Field) clause.Expression      { return clause.Expr{} }
+func (testDialector) BindVarTo(w clause.Writer, _ *gorm.Statement, _ any) { w.WriteByte('?') }
+func (testDialector) QuoteTo(w clause.Writer, s string)                   { w.WriteString(s) }
+func (testDialector) Explain(sql string, _ ...any) string                 { return sql }
+
+func TestPlugin(t *testing.T) {
+	m := metrics.NewMetrics(&metrics.Config{
+		ServiceName: "test",
+		Namespace:   "test",
+	})
+
+	db, err := gorm.Open(testDialector{}, &gorm.Config{DryRun: true})
+	if err != nil {
+		t.Fatalf("Failed to open GORM: %v", err)
+	}
+	if err := db.Use(NewPlugin(m.NewDatabaseMetrics())); err != nil {
+		t.Fatalf("Failed to register plugin: %v", err)
+	}
+
+	type User struct {
+		ID   int
+		Name string
+	}
+
+	db.Create(&User{Name: "okan"})
+	db.Find(&[]User{})
+
+	db.Callback().Create().Before("gorm:create").Register("test:fail", func(db *gorm.DB) {
+		db.AddError(errors.New("constraint violation"))
+	})
+	db.Create(&User{Name: "okan"})
+
+	tests := []struct {
+		operation, status string
+		want              float64
+	}{
+		{"create", "success", 1},
+		{"create", "error", 1},
+		{"query", "success", 1},
+	}
+	for _, tt := range tests {
+		labels := metrics.MetricLabels{"operation": tt.operation, "table": "users", "status": tt.status}
+		metricstest.AssertCounterValue(t, m, "database_table_queries_total", labels, tt.want)
+		metricstest.AssertHistogramCount(t, m, "database_table_query_duration_seconds", labels, uint64(tt.want))
+	}
+}
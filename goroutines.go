@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// goroutineLeakSamples is how many samples a site must grow over without
+	// decreasing to be suspected of leaking
+	goroutineLeakSamples = 10
+
+	// maxGoroutineSites caps the sites exported; the rest are summed as "other"
+	maxGoroutineSites = 50
+)
+
+// StartGoroutineLeakDetector samples the goroutines every
+// GoroutineSampleInterval until ctx is cancelled and groups them by the
+// function that started them. goroutines_by_site{site} holds the counts and
+// goroutine_leak_suspected{site} is 1 while the goroutines of a site grew
+// without ever decreasing over the last 10 samples, the pattern of e.g. one
+// goroutine per WebSocket that never exits.
+//
+// Every sample dumps all goroutine stacks, which briefly stops the world, so
+// keep the interval in minutes for processes with many goroutines.
+func (m *Metrics) StartGoroutineLeakDetector(ctx context.Context) error {
+	interval := m.config.GoroutineSampleInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	if err := m.RegisterGauge("goroutines_by_site", "Goroutines by the function that started them", []string{"site"}); err != nil {
+		return err
+	}
+	help := fmt.Sprintf("1 while the goroutines started by site grew without decreasing over the last %d samples", goroutineLeakSamples)
+	if err := m.RegisterGauge("goroutine_leak_suspected", help, []string{"site"}); err != nil {
+		return err
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		d := &leakDetector{history: make(map[string][]int)}
+		for {
+			if sites, err := goroutineSites(); err != nil {
+				m.logf("Failed to sample goroutines: %v", err)
+			} else {
+				m.recordGoroutineSites(d, sites)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// recordGoroutineSites feeds a sample to d and updates the gauges
+func (m *Metrics) recordGoroutineSites(d *leakDetector, sites map[string]int) {
+	sites = capSites(sites, maxGoroutineSites)
+	for _, site := range d.sample(sites) {
+		labels := MetricLabels{"site": site}
+		m.DeleteSeries("goroutines_by_site", labels)
+		m.DeleteSeries("goroutine_leak_suspected", labels)
+	}
+
+	for site, count := range sites {
+		labels := MetricLabels{"site": site}
+		m.SetGauge("goroutines_by_site", float64(count), labels)
+
+		suspected := 0.0
+		if d.suspected(site) {
+			suspected = 1
+		}
+		m.SetGauge("goroutine_leak_suspected", suspected, labels)
+	}
+}
+
+// leakDetector keeps the recent goroutine counts of every site
+type leakDetector struct {
+	history map[string][]int
+}
+
+// sample appends the counts of a sample, returning the known sites missing
+// from it, whose series are stale. Sites are forgotten once they have had no
+// goroutines for the whole window.
+func (d *leakDetector) sample(sites map[string]int) []string {
+	for site := range sites {
+		if _, ok := d.history[site]; !ok {
+			d.history[site] = nil
+		}
+	}
+
+	var missing []string
+	for site, counts := range d.history {
+		if _, ok := sites[site]; !ok {
+			missing = append(missing, site)
+		}
+
+		counts = append(counts, sites[site])
+		if len(counts) > goroutineLeakSamples {
+			counts = counts[1:]
+		}
+		if slices.Max(counts) == 0 {
+			delete(d.history, site)
+			continue
+		}
+		d.history[site] = counts
+	}
+	return missing
+}
+
+// suspected reports whether the goroutines of site grew over a full window
+// without decreasing in between
+func (d *leakDetector) suspected(site string) bool {
+	counts := d.history[site]
+	if len(counts) < goroutineLeakSamples {
+		return false
+	}
+	for i := 1; i < len(counts); i++ {
+		if counts[i] < counts[i-1] {
+			return false
+		}
+	}
+	return counts[len(counts)-1] > counts[0]
+}
+
+// goroutineSites counts the running goroutines by creation site
+func goroutineSites() (map[string]int, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 2); err != nil {
+		return nil, err
+	}
+	return parseGoroutineSites(buf.String()), nil
+}
+
+// parseGoroutineSites counts the goroutines of a full goroutine dump by the
+// function named in their "created by" line. Goroutines without one, such
+// as the main goroutine, are counted as "main".
+func parseGoroutineSites(dump string) map[string]int {
+	sites := make(map[string]int)
+	for block := range strings.SplitSeq(dump, "\n\n") {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+
+		site := "main"
+		for line := range strings.Lines(block) {
+			if creator, ok := strings.CutPrefix(line, "created by "); ok {
+				// Go 1.21+ appends the parent, e.g. "created by main.serve in goroutine 7"
+				creator, _, _ = strings.Cut(creator, " in goroutine ")
+				site = strings.TrimSpace(creator)
+			}
+		}
+		sites[site]++
+	}
+	return sites
+}
+
+// capSites keeps the limit-1 sites with the most goroutines and sums the
+// rest as "other"
+func capSites(sites map[string]int, limit int) map[string]int {
+	if len(sites) <= limit {
+		return sites
+	}
+
+	names := slices.SortedFunc(maps.Keys(sites), func(a, b string) int {
+		return cmp.Or(cmp.Compare(sites[b], sites[a]), cmp.Compare(a, b))
+	})
+	capped := make(map[string]int, limit)
+	for i, name := range names {
+		if i < limit-1 {
+			capped[name] = sites[name]
+		} else {
+			capped["other"] += sites[name]
+		}
+	}
+	return capped
+}
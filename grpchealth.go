@@ -0,0 +1,115 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCReadinessService is the gRPC health service name reporting readiness
+// like /ready. The empty service name reports health like /health.
+const GRPCReadinessService = "readiness"
+
+// grpcHealthWatchInterval is how often Watch runs the checks
+const grpcHealthWatchInterval = 5 * time.Second
+
+// grpcHealthServer implements the gRPC health service with the checks of a
+// HealthChecker
+type grpcHealthServer struct {
+	healthpb.UnimplementedHealthServer
+	h *HealthChecker
+}
+
+// GRPCServer returns a gRPC health service backed by the registered checks,
+// so gRPC and HTTP probes share one source of truth. Besides the empty
+// service name (overall health, like /health) and GRPCReadinessService
+// (like /ready), every check can be queried by its name. Results are
+// exported as gauges as with /health.
+//
+//	healthpb.RegisterHealthServer(server, m.Health().GRPCServer())
+func (h *HealthChecker) GRPCServer() healthpb.HealthServer {
+	return &grpcHealthServer{h: h}
+}
+
+// RegisterGRPC registers the gRPC health service on a gRPC server
+func (h *HealthChecker) RegisterGRPC(s grpc.ServiceRegistrar) {
+	healthpb.RegisterHealthServer(s, h.GRPCServer())
+}
+
+// Check runs the checks and reports the status of the requested service
+func (s *grpcHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, ok := grpcServiceStatus(s.h.Check(ctx), req.GetService())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "unknown service %q", req.GetService())
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+// List runs the checks and reports the status of all services
+func (s *grpcHealthServer) List(ctx context.Context, _ *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	report := s.h.Check(ctx)
+
+	statuses := make(map[string]*healthpb.HealthCheckResponse, len(report.Checks)+2)
+	for _, service := range append([]string{"", GRPCReadinessService}, s.h.Names()...) {
+		if st, ok := grpcServiceStatus(report, service); ok {
+			statuses[service] = &healthpb.HealthCheckResponse{Status: st}
+		}
+	}
+	return &healthpb.HealthListResponse{Statuses: statuses}, nil
+}
+
+// Watch runs the checks every grpcHealthWatchInterval and streams the status
+// of the requested service whenever it changes. Unknown services are
+// reported as SERVICE_UNKNOWN, as they may be registered later.
+func (s *grpcHealthServer) Watch(req *healthpb.HealthCheckRequest, stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse]) error {
+	ctx := stream.Context()
+	ticker := time.NewTicker(grpcHealthWatchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+	for {
+		st, ok := grpcServiceStatus(s.h.Check(ctx), req.GetService())
+		if !ok {
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// grpcServiceStatus returns the status of a service in report, or false if
+// the service is unknown
+func grpcServiceStatus(report HealthReport, service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	serving := func(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+		if ok {
+			return healthpb.HealthCheckResponse_SERVING
+		}
+		return healthpb.HealthCheckResponse_NOT_SERVING
+	}
+
+	switch service {
+	case "":
+		return serving(report.Status == HealthStatusOK), true
+	case GRPCReadinessService:
+		return serving(report.Ready), true
+	}
+	result, ok := report.Checks[service]
+	if !ok {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	return serving(result.Status == HealthStatusOK), true
+}
@@ -0,0 +1,329 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HealthCheckFunc reports the health of a dependency, returning nil when healthy
+type HealthCheckFunc func(ctx context.Context) error
+
+// HealthStatus values reported by the health endpoint
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// CheckResult is the outcome of a single health check
+type CheckResult struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+// HealthReport is the aggregate result of all registered checks
+type HealthReport struct {
+	Status  string                 `json:"status"`
+	Service string                 `json:"service"`
+	Ready   bool                   `json:"ready"`
+	Checks  map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// HealthChecker runs named health checks and exports their status
+type HealthChecker struct {
+	m *Metrics
+
+	checks     map[string]*healthCheck
+	generation int // Incremented when checks are added or removed
+	mu         sync.RWMutex
+
+	// Report of the last run, shared by all probes within
+	// HealthCheckInterval so failures are counted once per run
+	runMu          sync.Mutex // Serializes runs, so concurrent probes share one
+	last           HealthReport
+	lastRun        time.Time
+	lastGeneration int
+}
+
+// healthCheck is a registered check and the history of its results
+type healthCheck struct {
+	fn       HealthCheckFunc
+	critical bool
+	failures int         // Consecutive failures
+	healthy  bool        // Result of the previous run
+	checked  bool        // Set after the first run
+	changes  []time.Time // State changes within healthFlapWindow
+}
+
+// A check is flapping if its state changed healthFlapChanges times within
+// healthFlapWindow
+const (
+	healthFlapWindow  = 10 * time.Minute
+	healthFlapChanges = 4
+)
+
+// Health returns the health checker backing the /health endpoint
+func (m *Metrics) Health() *HealthChecker {
+	return m.health
+}
+
+// newHealthChecker creates an empty health checker
+func newHealthChecker(m *Metrics) *HealthChecker {
+	return &HealthChecker{
+		m:      m,
+		checks: make(map[string]*healthCheck),
+	}
+}
+
+// Register adds or replaces a named health check
+func (h *HealthChecker) Register(name string, check HealthCheckFunc) {
+	h.register(name, check, false)
+}
+
+// RegisterCritical adds or replaces a named health check the service cannot
+// work without. Once it fails HealthFailureThreshold times in a row, the
+// service is reported not ready on /ready and service_ready is set to 0
+// until the check passes again.
+func (h *HealthChecker) RegisterCritical(name string, check HealthCheckFunc) {
+	h.register(name, check, true)
+}
+
+// register adds or replaces a named health check
+func (h *HealthChecker) register(name string, check HealthCheckFunc, critical bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.checks[name] = &healthCheck{fn: check, critical: critical}
+	h.generation++
+}
+
+// Unregister removes a named health check and the series of its gauges,
+// so a removed check is no longer reported as failing
+func (h *HealthChecker) Unregister(name string) {
+	h.mu.Lock()
+	delete(h.checks, name)
+	h.generation++
+	h.mu.Unlock()
+
+	labels := MetricLabels{"check": name}
+	for _, gauge := range []string{"health_check_status", "health_check_consecutive_failures", "health_check_flapping"} {
+		h.m.DeleteSeries(gauge, labels)
+	}
+}
+
+// Names returns the sorted names of registered checks
+func (h *HealthChecker) Names() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Check runs all registered checks concurrently and returns the aggregate
+// report. Each check is bounded by Config.HealthCheckTimeout, even if it
+// ignores ctx, and a panicking check fails instead of crashing the process.
+// Results are exported as the health_check_status gauge (1 healthy, 0
+// failing). The readiness derived from critical checks is exported as
+// service_ready.
+//
+// Checks run at most once per Config.HealthCheckInterval: /health, /ready
+// and gRPC probes within the interval, or waiting for a run in progress,
+// get the report of the last run. Consecutive failures and state changes
+// therefore count runs, not probes.
+func (h *HealthChecker) Check(ctx context.Context) HealthReport {
+	h.runMu.Lock()
+	defer h.runMu.Unlock()
+
+	interval := h.m.config.HealthCheckInterval
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+
+	h.mu.RLock()
+	generation := h.generation
+	checks := make(map[string]HealthCheckFunc, len(h.checks))
+	for name, check := range h.checks {
+		checks[name] = check.fn
+	}
+	h.mu.RUnlock()
+
+	if !h.lastRun.IsZero() && generation == h.lastGeneration && time.Since(h.lastRun) < interval {
+		return h.last
+	}
+	// The report is shared, so a probe that disconnects must not fail it
+	report := h.runChecks(context.WithoutCancel(ctx), checks)
+	h.last, h.lastRun, h.lastGeneration = report, time.Now(), generation
+	return report
+}
+
+// runChecks runs checks and exports their results
+func (h *HealthChecker) runChecks(ctx context.Context, checks map[string]HealthCheckFunc) HealthReport {
+	report := HealthReport{
+		Status:  HealthStatusOK,
+		Service: h.m.config.ServiceName,
+		Ready:   true,
+	}
+	if len(checks) == 0 {
+		h.m.SetGauge("service_ready", 1, nil)
+		return report
+	}
+
+	timeout := h.m.config.HealthCheckTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		results = make(map[string]CheckResult, len(checks))
+	)
+
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check HealthCheckFunc) {
+			defer wg.Done()
+
+			result := h.run(ctx, check, timeout)
+
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	for name, result := range results {
+		labels := MetricLabels{"check": name}
+		status := 1.0
+		if result.Status != HealthStatusOK {
+			status = 0
+			report.Status = HealthStatusFail
+		}
+		h.m.SetGauge("health_check_status", status, labels)
+		h.m.recordDuration("health_check_duration", result.LatencyMs/1000, labels)
+	}
+	report.Checks = results
+
+	report.Ready = h.record(results, time.Now())
+	ready := 0.0
+	if report.Ready {
+		ready = 1
+	}
+	h.m.SetGauge("service_ready", ready, nil)
+
+	return report
+}
+
+// record updates the failure and state change history of the checks and
+// reports whether no critical check has reached HealthFailureThreshold
+func (h *HealthChecker) record(results map[string]CheckResult, now time.Time) bool {
+	threshold := h.m.config.HealthFailureThreshold
+	if threshold <= 0 {
+		threshold = 3
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ready := true
+	for name, check := range h.checks {
+		result, ok := results[name]
+		if !ok {
+			continue
+		}
+		labels := MetricLabels{"check": name}
+
+		healthy := result.Status == HealthStatusOK
+		if healthy {
+			check.failures = 0
+		} else {
+			check.failures++
+		}
+		h.m.SetGauge("health_check_consecutive_failures", float64(check.failures), labels)
+
+		if check.checked && healthy != check.healthy {
+			h.m.IncrementCounter("health_check_state_changes_total", labels)
+			check.changes = append(check.changes, now)
+		}
+		check.healthy = healthy
+		check.checked = true
+
+		// Forget state changes that left the window
+		for len(check.changes) > 0 && now.Sub(check.changes[0]) > healthFlapWindow {
+			check.changes = check.changes[1:]
+		}
+		flapping := 0.0
+		if len(check.changes) >= healthFlapChanges {
+			flapping = 1
+		}
+		h.m.SetGauge("health_check_flapping", flapping, labels)
+
+		if check.critical && check.failures >= threshold {
+			ready = false
+		}
+	}
+	return ready
+}
+
+// run executes a single check with a timeout. The check runs in its own
+// goroutine, so a check ignoring ctx fails at the timeout instead of
+// blocking, and a panic fails the check instead of crashing the process.
+func (h *HealthChecker) run(ctx context.Context, check HealthCheckFunc, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan error, 1) // Buffered, so an abandoned check can finish
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- fmt.Errorf("health check panicked: %v", r)
+			}
+		}()
+		done <- check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	latency := time.Since(start)
+
+	result := CheckResult{
+		Status:    HealthStatusOK,
+		LatencyMs: float64(latency) / float64(time.Millisecond),
+	}
+	if err != nil {
+		result.Status = HealthStatusFail
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// ReadyStatus returns the HTTP status code of the readiness endpoint
+func (r HealthReport) ReadyStatus() int {
+	if !r.Ready {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
+
+// HTTPStatus returns the HTTP status code matching the report
+func (r HealthReport) HTTPStatus() int {
+	if r.Status != HealthStatusOK {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusOK
+}
@@ -0,0 +1,329 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// HistorySample is a single recorded value
+type HistorySample struct {
+	Timestamp time.Time `json:"t"`
+	Value     float64   `json:"v"`
+}
+
+// HistorySeries holds the recent samples of one series. Histograms and
+// summaries are recorded as their _sum and _count series. Histograms
+// additionally get a _p95 series without labels holding the 95th percentile
+// of each sampling interval over all their series.
+type HistorySeries struct {
+	Name    string            `json:"name"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Samples []HistorySample   `json:"samples"`
+}
+
+// history keeps the samples of the selected metrics in fixed size rings
+type history struct {
+	names  map[string]bool // Fully-qualified names of selected metrics
+	size   int
+	window time.Duration
+	series map[string]*historyRing
+
+	// Cumulative bucket counts of histograms summed over all series, by
+	// family, to derive the p95 of each sampling interval
+	buckets map[string]map[float64]uint64
+
+	mu sync.RWMutex
+}
+
+// historyRing is the ring buffer of a single series
+type historyRing struct {
+	name    string
+	labels  map[string]string
+	samples []HistorySample
+	next    int
+}
+
+// StartHistory starts sampling the metrics listed in HistoryMetrics every
+// HistoryInterval, keeping the last HistoryWindow of samples in memory until
+// ctx is cancelled. It is started automatically when HistoryMetrics is set.
+func (m *Metrics) StartHistory(ctx context.Context) error {
+	if len(m.config.HistoryMetrics) == 0 {
+		return fmt.Errorf("HistoryMetrics is not configured")
+	}
+
+	interval := m.config.HistoryInterval
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+	h := m.newHistory(interval)
+
+	m.mu.Lock()
+	m.history = h
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			m.sampleHistory(h, time.Now())
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// newHistory creates an empty history for the configured metrics
+func (m *Metrics) newHistory(interval time.Duration) *history {
+	window := m.config.HistoryWindow
+	if window <= 0 {
+		window = 10 * time.Minute
+	}
+
+	h := &history{
+		names:   make(map[string]bool, len(m.config.HistoryMetrics)),
+		size:    max(int(window/interval), 1),
+		window:  window,
+		series:  make(map[string]*historyRing),
+		buckets: make(map[string]map[float64]uint64),
+	}
+	for _, name := range m.config.HistoryMetrics {
+		h.names[prometheus.BuildFQName(m.config.Namespace, m.config.Subsystem, name)] = true
+		h.names[name] = true
+	}
+	return h
+}
+
+// sampleHistory gathers the registry and appends the selected series
+func (m *Metrics) sampleHistory(h *history, now time.Time) {
+	families, err := m.gatherer().Gather()
+	if err != nil {
+		m.logf("Failed to gather metrics for history: %v", err)
+		// Gather returns everything it could collect despite errors
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, mf := range families {
+		if !h.names[mf.GetName()] {
+			continue
+		}
+		name := mf.GetName()
+		for _, pm := range mf.GetMetric() {
+			labels := labelMap(pm)
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				h.add(name, labels, pm.GetCounter().GetValue(), now)
+			case dto.MetricType_GAUGE:
+				h.add(name, labels, pm.GetGauge().GetValue(), now)
+			case dto.MetricType_UNTYPED:
+				h.add(name, labels, pm.GetUntyped().GetValue(), now)
+			case dto.MetricType_HISTOGRAM:
+				h.add(name+"_sum", labels, pm.GetHistogram().GetSampleSum(), now)
+				h.add(name+"_count", labels, float64(pm.GetHistogram().GetSampleCount()), now)
+			case dto.MetricType_SUMMARY:
+				h.add(name+"_sum", labels, pm.GetSummary().GetSampleSum(), now)
+				h.add(name+"_count", labels, float64(pm.GetSummary().GetSampleCount()), now)
+			}
+		}
+		if mf.GetType() == dto.MetricType_HISTOGRAM {
+			h.addQuantile(mf, 0.95, "_p95", now)
+		}
+	}
+
+	// Drop series that were deleted or not exposed for a whole window
+	for key, r := range h.series {
+		if now.Sub(r.last().Timestamp) > h.window {
+			delete(h.series, key)
+		}
+	}
+}
+
+// addQuantile appends the q-quantile of the observations made since the
+// previous sample, over all series of a histogram family
+func (h *history) addQuantile(mf *dto.MetricFamily, q float64, suffix string, now time.Time) {
+	current, value, ok := intervalQuantile(mf, h.buckets[mf.GetName()], q)
+	h.buckets[mf.GetName()] = current
+	if ok {
+		h.add(mf.GetName()+suffix, nil, value, now)
+	}
+}
+
+// intervalQuantile estimates the q-quantile of the observations of a
+// histogram family since its cumulative bucket counts were last, over all
+// its series. It returns the current counts to pass next time, and false if
+// last is nil or nothing was observed since.
+func intervalQuantile(mf *dto.MetricFamily, last map[float64]uint64, q float64) (map[float64]uint64, float64, bool) {
+	current := make(map[float64]uint64)
+	for _, pm := range mf.GetMetric() {
+		for _, b := range pm.GetHistogram().GetBucket() {
+			current[b.GetUpperBound()] += b.GetCumulativeCount()
+		}
+		current[math.Inf(1)] += pm.GetHistogram().GetSampleCount()
+	}
+	if last == nil {
+		return current, 0, false
+	}
+	value, ok := deltaQuantile(current, last, q)
+	return current, value, ok
+}
+
+// deltaQuantile estimates the q-quantile of the observations between two
+// cumulative bucket counts by upper bound, including +Inf
+func deltaQuantile(current, last map[float64]uint64, q float64) (float64, bool) {
+	bounds := make([]float64, 0, len(current))
+	for bound := range current {
+		bounds = append(bounds, bound)
+	}
+	sort.Float64s(bounds)
+
+	counts := make([]float64, len(bounds))
+	for i, bound := range bounds {
+		// Deleted series can make the delta negative
+		counts[i] = max(float64(current[bound])-float64(last[bound]), 0)
+	}
+	return bucketQuantile(q, bounds, counts)
+}
+
+// bucketQuantile estimates the q-quantile from cumulative bucket counts
+// ending with the +Inf bucket, interpolating linearly within a bucket like
+// PromQL's histogram_quantile. It reports false if there are no observations.
+func bucketQuantile(q float64, bounds, counts []float64) (float64, bool) {
+	total := counts[len(counts)-1]
+	if total <= 0 {
+		return 0, false
+	}
+
+	rank := q * total
+	i := sort.SearchFloat64s(counts, rank)
+	if i == len(bounds)-1 {
+		// Above the highest finite bucket
+		if i == 0 {
+			return 0, false
+		}
+		return bounds[i-1], true
+	}
+
+	lower, below := 0.0, 0.0
+	if i > 0 {
+		lower, below = bounds[i-1], counts[i-1]
+	}
+	if counts[i] == below {
+		return bounds[i], true
+	}
+	return lower + (bounds[i]-lower)*(rank-below)/(counts[i]-below), true
+}
+
+// labelMap returns the labels of a gathered metric
+func labelMap(pm *dto.Metric) map[string]string {
+	labels := make(map[string]string, len(pm.GetLabel()))
+	for _, lp := range pm.GetLabel() {
+		labels[lp.GetName()] = lp.GetValue()
+	}
+	return labels
+}
+
+// add appends a sample to the ring of a series
+func (h *history) add(name string, labels map[string]string, value float64, now time.Time) {
+	key := name + "\xff" + seriesKey(labels)
+
+	r, ok := h.series[key]
+	if !ok {
+		r = &historyRing{name: name, labels: labels, samples: make([]HistorySample, 0, h.size)}
+		h.series[key] = r
+	}
+
+	sample := HistorySample{Timestamp: now, Value: value}
+	if len(r.samples) < h.size {
+		r.samples = append(r.samples, sample)
+		return
+	}
+	r.samples[r.next] = sample
+	r.next = (r.next + 1) % h.size
+}
+
+// last returns the most recent sample
+func (r *historyRing) last() HistorySample {
+	if r.next == 0 {
+		return r.samples[len(r.samples)-1]
+	}
+	return r.samples[r.next-1]
+}
+
+// ordered returns the samples from oldest to newest
+func (r *historyRing) ordered() []HistorySample {
+	samples := make([]HistorySample, 0, len(r.samples))
+	samples = append(samples, r.samples[r.next:]...)
+	return append(samples, r.samples[:r.next]...)
+}
+
+// History returns the recorded samples of a metric, or of all recorded
+// metrics if name is empty. Names may be given with or without namespace.
+func (m *Metrics) History(name string) []HistorySeries {
+	m.mu.RLock()
+	h := m.history
+	m.mu.RUnlock()
+
+	if h == nil {
+		return nil
+	}
+
+	fqName := prometheus.BuildFQName(m.config.Namespace, m.config.Subsystem, name)
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	var result []HistorySeries
+	for _, r := range h.series {
+		if name != "" && !historyMatch(r.name, name) && !historyMatch(r.name, fqName) {
+			continue
+		}
+		result = append(result, HistorySeries{
+			Name:    r.name,
+			Labels:  r.labels,
+			Samples: r.ordered(),
+		})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return seriesKey(result[i].Labels) < seriesKey(result[j].Labels)
+	})
+	return result
+}
+
+// historyMatch reports whether a recorded series belongs to metric name,
+// including the _sum, _count and _p95 series of histograms
+func historyMatch(series, name string) bool {
+	return series == name || series == name+"_sum" || series == name+"_count" || series == name+"_p95"
+}
+
+// HistoryEndpoint returns a Gin handler serving History as JSON, filtered
+// by the optional metric query parameter
+func (m *Metrics) HistoryEndpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		series := m.History(c.Query("metric"))
+		if series == nil {
+			series = []HistorySeries{}
+		}
+		c.JSON(http.StatusOK, gin.H{"series": series})
+	}
+}
@@ -1,13 +1,28 @@
 package metrics
 
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
 // WebSocketMetrics provides WebSocket-specific metrics helpers
 type WebSocketMetrics struct {
 	m *Metrics
+
+	// Open connections by attributes, see ForConnection
+	mu    sync.Mutex
+	conns map[string]int
 }
 
 // NewWebSocketMetrics creates WebSocket metrics helper
 func (m *Metrics) NewWebSocketMetrics() *WebSocketMetrics {
-	return &WebSocketMetrics{m: m}
+	return &WebSocketMetrics{m: m, conns: make(map[string]int)}
 }
 
 // ConnectionOpened increments active WebSocket connections
@@ -85,7 +100,15 @@ func (cm *CacheMetrics) Miss(cacheType string) {
 	})
 }
 
-// SetHitRatio sets the cache hit ratio gauge
+// TrackHitRatio exposes cache_hit_ratio computed from the hit and miss
+// counters at scrape time, so SetHitRatio need not be called
+func (cm *CacheMetrics) TrackHitRatio() error {
+	return cm.m.RegisterRatio("cache_hit_ratio", "Cache hits divided by cache lookups", []string{"type"},
+		"cache_hits_total", "cache_hits_total", "cache_misses_total")
+}
+
+// SetHitRatio sets the cache hit ratio gauge. Use TrackHitRatio to have it
+// computed automatically instead.
 func (cm *CacheMetrics) SetHitRatio(cacheType string, ratio float64) {
 	cm.m.SetGauge("cache_hit_ratio", ratio, MetricLabels{
 		"type": cacheType,
@@ -116,14 +139,14 @@ func (m *Metrics) NewDatabaseMetrics() *DatabaseMetrics {
 	return &DatabaseMetrics{m: m}
 }
 
-// QueryExecuted records a database query execution
+// QueryExecuted records a database query execution, duration is in seconds
 func (dm *DatabaseMetrics) QueryExecuted(operation string, duration float64, success bool) {
 	status := "success"
 	if !success {
 		status = "error"
 	}
 
-	dm.m.RecordHistogram("database_query_duration_seconds", duration, MetricLabels{
+	dm.m.recordDuration("database_query_duration", duration, MetricLabels{
 		"operation": operation,
 		"status":    status,
 	})
@@ -134,6 +157,12 @@ func (dm *DatabaseMetrics) QueryExecuted(operation string, duration float64, suc
 	})
 }
 
+// QueryExecutedDuration records a database query execution, like
+// QueryExecuted with a time.Duration
+func (dm *DatabaseMetrics) QueryExecutedDuration(operation string, duration time.Duration, success bool) {
+	dm.QueryExecuted(operation, duration.Seconds(), success)
+}
+
 // ConnectionOpened increments active database connections
 func (dm *DatabaseMetrics) ConnectionOpened() {
 	dm.m.IncrementGauge("database_connections_active", nil)
@@ -149,6 +178,566 @@ func (dm *DatabaseMetrics) SetConnectionPoolSize(size float64) {
 	dm.m.SetGauge("database_connection_pool_size", size, nil)
 }
 
+// TableQueryExecuted records a database query on a table with the number of
+// rows it affected, duration is in seconds
+func (dm *DatabaseMetrics) TableQueryExecuted(operation, table string, duration float64, rowsAffected int64, success bool) {
+	status := "success"
+	if !success {
+		status = "error"
+	}
+
+	labels := MetricLabels{
+		"operation": operation,
+		"table":     table,
+		"status":    status,
+	}
+	dm.m.recordDuration("database_table_query_duration", duration, labels)
+	dm.m.IncrementCounter("database_table_queries_total", labels)
+
+	if rowsAffected > 0 {
+		dm.m.IncrementCounterBy("database_rows_affected_total", float64(rowsAffected), MetricLabels{
+			"operation": operation,
+			"table":     table,
+		})
+	}
+}
+
+// TableQueryExecutedDuration records a database query on a table with the
+// number of rows it affected, like TableQueryExecuted with a time.Duration
+func (dm *DatabaseMetrics) TableQueryExecutedDuration(operation, table string, duration time.Duration, rowsAffected int64, success bool) {
+	dm.TableQueryExecuted(operation, table, duration.Seconds(), rowsAffected, success)
+}
+
+// CollectDBStats periodically exports the connection pool statistics of db
+// until ctx is cancelled. Cumulative values such as WaitCount are exported
+// as counters, point-in-time values as gauges.
+func (dm *DatabaseMetrics) CollectDBStats(ctx context.Context, db *sql.DB, dbName string, interval time.Duration) {
+	if interval <= 0 {
+		interval = dm.m.config.PushInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var last sql.DBStats
+		for {
+			last = dm.recordDBStats(db.Stats(), last, dbName)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// recordDBStats exports current pool statistics and returns them so the
+// next call can compute counter deltas
+func (dm *DatabaseMetrics) recordDBStats(stats, last sql.DBStats, dbName string) sql.DBStats {
+	labels := MetricLabels{"db": dbName}
+
+	dm.m.SetGauge("database_max_open_connections", float64(stats.MaxOpenConnections), labels)
+	dm.m.SetGauge("database_connections_open", float64(stats.OpenConnections), labels)
+	dm.m.SetGauge("database_connections_in_use", float64(stats.InUse), labels)
+	dm.m.SetGauge("database_connections_idle", float64(stats.Idle), labels)
+
+	dm.m.IncrementCounterBy("database_wait_count_total", float64(stats.WaitCount-last.WaitCount), labels)
+	dm.m.IncrementCounterBy("database_wait_duration_seconds_total", (stats.WaitDuration - last.WaitDuration).Seconds(), labels)
+	dm.m.IncrementCounterBy("database_max_idle_closed_total", float64(stats.MaxIdleClosed-last.MaxIdleClosed), labels)
+	dm.m.IncrementCounterBy("database_max_idle_time_closed_total", float64(stats.MaxIdleTimeClosed-last.MaxIdleTimeClosed), labels)
+	dm.m.IncrementCounterBy("database_max_lifetime_closed_total", float64(stats.MaxLifetimeClosed-last.MaxLifetimeClosed), labels)
+
+	return stats
+}
+
+// KafkaMetrics provides Kafka producer/consumer metrics helpers
+type KafkaMetrics struct {
+	m *Metrics
+}
+
+// NewKafkaMetrics creates Kafka metrics helper
+func (m *Metrics) NewKafkaMetrics() *KafkaMetrics {
+	return &KafkaMetrics{m: m}
+}
+
+// MessageProduced increments produced messages and bytes counters
+func (km *KafkaMetrics) MessageProduced(topic string, bytes int) {
+	labels := MetricLabels{"topic": topic}
+	km.m.IncrementCounter("kafka_messages_produced_total", labels)
+	km.m.IncrementCounterBy("kafka_produced_bytes_total", float64(bytes), labels)
+}
+
+// ProduceFailed increments the produce errors counter
+func (km *KafkaMetrics) ProduceFailed(topic string) {
+	km.m.IncrementCounter("kafka_produce_errors_total", MetricLabels{
+		"topic": topic,
+	})
+}
+
+// ProduceLatency records the time until a produced message was acknowledged, in seconds
+func (km *KafkaMetrics) ProduceLatency(topic string, duration float64) {
+	km.m.recordDuration("kafka_produce_latency", duration, MetricLabels{
+		"topic": topic,
+	})
+}
+
+// ProduceLatencyDuration records the time until a produced message was
+// acknowledged, like ProduceLatency with a time.Duration
+func (km *KafkaMetrics) ProduceLatencyDuration(topic string, duration time.Duration) {
+	km.ProduceLatency(topic, duration.Seconds())
+}
+
+// MessageConsumed increments consumed messages and bytes counters
+func (km *KafkaMetrics) MessageConsumed(topic string, bytes int) {
+	labels := MetricLabels{"topic": topic}
+	km.m.IncrementCounter("kafka_messages_consumed_total", labels)
+	km.m.IncrementCounterBy("kafka_consumed_bytes_total", float64(bytes), labels)
+}
+
+// ConsumeLatency records the time between a message being produced and
+// consumed, in seconds
+func (km *KafkaMetrics) ConsumeLatency(topic string, duration float64) {
+	km.m.recordDuration("kafka_consume_latency", duration, MetricLabels{
+		"topic": topic,
+	})
+}
+
+// ConsumeLatencyDuration records the time between a message being produced
+// and consumed, like ConsumeLatency with a time.Duration
+func (km *KafkaMetrics) ConsumeLatencyDuration(topic string, duration time.Duration) {
+	km.ConsumeLatency(topic, duration.Seconds())
+}
+
+// SetConsumerLag sets the consumer lag in messages for a topic partition
+func (km *KafkaMetrics) SetConsumerLag(topic string, partition int32, lag float64) {
+	km.m.SetGauge("kafka_consumer_lag", lag, MetricLabels{
+		"topic":     topic,
+		"partition": strconv.Itoa(int(partition)),
+	})
+}
+
+// Rebalance increments the consumer group rebalance counter
+func (km *KafkaMetrics) Rebalance(group string) {
+	km.m.IncrementCounter("kafka_consumer_rebalances_total", MetricLabels{
+		"group": group,
+	})
+}
+
+// MessagingMetrics provides metrics helpers for message brokers such as NATS
+type MessagingMetrics struct {
+	m *Metrics
+}
+
+// NewMessagingMetrics creates messaging metrics helper
+func (m *Metrics) NewMessagingMetrics() *MessagingMetrics {
+	return &MessagingMetrics{m: m}
+}
+
+// MessagePublished records a published message and the publish latency,
+// duration is in seconds
+func (mm *MessagingMetrics) MessagePublished(subject string, duration float64) {
+	labels := MetricLabels{"subject": subject}
+	mm.m.IncrementCounter("messaging_messages_published_total", labels)
+	mm.m.recordDuration("messaging_publish_duration", duration, labels)
+}
+
+// MessagePublishedDuration records a published message and the publish
+// latency, like MessagePublished with a time.Duration
+func (mm *MessagingMetrics) MessagePublishedDuration(subject string, duration time.Duration) {
+	mm.MessagePublished(subject, duration.Seconds())
+}
+
+// PublishFailed increments the publish errors counter
+func (mm *MessagingMetrics) PublishFailed(subject string) {
+	mm.m.IncrementCounter("messaging_publish_errors_total", MetricLabels{
+		"subject": subject,
+	})
+}
+
+// MessageReceived increments the received messages counter
+func (mm *MessagingMetrics) MessageReceived(subject string) {
+	mm.m.IncrementCounter("messaging_messages_received_total", MetricLabels{
+		"subject": subject,
+	})
+}
+
+// SetPending sets the messages and bytes buffered for a subscription but
+// not yet handled
+func (mm *MessagingMetrics) SetPending(subject string, messages, bytes float64) {
+	labels := MetricLabels{"subject": subject}
+	mm.m.SetGauge("messaging_subscription_pending_messages", messages, labels)
+	mm.m.SetGauge("messaging_subscription_pending_bytes", bytes, labels)
+}
+
+// Disconnected increments the disconnects counter
+func (mm *MessagingMetrics) Disconnected() {
+	mm.m.IncrementCounter("messaging_disconnects_total", nil)
+}
+
+// Reconnected increments the reconnects counter
+func (mm *MessagingMetrics) Reconnected() {
+	mm.m.IncrementCounter("messaging_reconnects_total", nil)
+}
+
+// AsyncError increments the counter of errors reported asynchronously by
+// the broker connection, e.g. slow consumers
+func (mm *MessagingMetrics) AsyncError() {
+	mm.m.IncrementCounter("messaging_async_errors_total", nil)
+}
+
+// AMQPMetrics provides RabbitMQ / AMQP metrics helpers
+type AMQPMetrics struct {
+	m *Metrics
+}
+
+// NewAMQPMetrics creates AMQP metrics helper
+func (m *Metrics) NewAMQPMetrics() *AMQPMetrics {
+	return &AMQPMetrics{m: m}
+}
+
+// MessagePublished increments the published messages counter
+func (am *AMQPMetrics) MessagePublished(exchange string) {
+	am.m.IncrementCounter("amqp_messages_published_total", MetricLabels{
+		"exchange": exchange,
+	})
+}
+
+// PublishConfirmed records the time until the broker confirmed a publish,
+// duration is in seconds. Publishes rejected by the broker are counted in
+// amqp_publish_nacks_total.
+func (am *AMQPMetrics) PublishConfirmed(exchange string, duration float64, acked bool) {
+	labels := MetricLabels{"exchange": exchange}
+	am.m.recordDuration("amqp_publish_confirm_duration", duration, labels)
+	if !acked {
+		am.m.IncrementCounter("amqp_publish_nacks_total", labels)
+	}
+}
+
+// PublishConfirmedDuration records the time until the broker confirmed a
+// publish, like PublishConfirmed with a time.Duration
+func (am *AMQPMetrics) PublishConfirmedDuration(exchange string, duration time.Duration, acked bool) {
+	am.PublishConfirmed(exchange, duration.Seconds(), acked)
+}
+
+// MessageConsumed increments the consumed messages counter
+func (am *AMQPMetrics) MessageConsumed(queue string) {
+	am.m.IncrementCounter("amqp_messages_consumed_total", MetricLabels{
+		"queue": queue,
+	})
+}
+
+// MessageAcked increments the acknowledged messages counter
+func (am *AMQPMetrics) MessageAcked(queue string) {
+	am.m.IncrementCounter("amqp_messages_acked_total", MetricLabels{
+		"queue": queue,
+	})
+}
+
+// MessageNacked increments the negatively acknowledged messages counter,
+// including rejects
+func (am *AMQPMetrics) MessageNacked(queue string, requeue bool) {
+	am.m.IncrementCounter("amqp_messages_nacked_total", MetricLabels{
+		"queue":   queue,
+		"requeue": strconv.FormatBool(requeue),
+	})
+}
+
+// SetQueueStats sets the consumers and ready messages of a queue
+func (am *AMQPMetrics) SetQueueStats(queue string, consumers, messages int) {
+	labels := MetricLabels{"queue": queue}
+	am.m.SetGauge("amqp_queue_consumers", float64(consumers), labels)
+	am.m.SetGauge("amqp_queue_messages", float64(messages), labels)
+}
+
+// ConnectionOpened increments the opened connections counter
+func (am *AMQPMetrics) ConnectionOpened() {
+	am.m.IncrementCounter("amqp_connections_opened_total", nil)
+}
+
+// ConnectionClosed increments the closed connections counter
+func (am *AMQPMetrics) ConnectionClosed() {
+	am.m.IncrementCounter("amqp_connections_closed_total", nil)
+}
+
+// ChannelOpened increments the opened channels counter
+func (am *AMQPMetrics) ChannelOpened() {
+	am.m.IncrementCounter("amqp_channels_opened_total", nil)
+}
+
+// ChannelClosed increments the closed channels counter
+func (am *AMQPMetrics) ChannelClosed() {
+	am.m.IncrementCounter("amqp_channels_closed_total", nil)
+}
+
+// RedisMetrics provides Redis client metrics helpers
+type RedisMetrics struct {
+	m *Metrics
+}
+
+// RedisPoolStats is a snapshot of a Redis client connection pool
+type RedisPoolStats struct {
+	Hits       uint32 // Free connection found in the pool
+	Misses     uint32 // Free connection not found in the pool
+	Timeouts   uint32 // Wait timeouts
+	TotalConns uint32 // Total connections in the pool
+	IdleConns  uint32 // Idle connections in the pool
+	StaleConns uint32 // Stale connections removed from the pool
+}
+
+// RedisKeyStats is a snapshot of application keys and server INFO fields
+type RedisKeyStats struct {
+	Lengths map[string]int64     // Length of keys such as queue lists, by key
+	Pending []RedisStreamPending // Pending entries of stream consumer groups
+	Info    map[string]float64   // INFO fields such as used_memory
+}
+
+// RedisStreamPending is the number of entries delivered to a consumer group
+// but not yet acknowledged
+type RedisStreamPending struct {
+	Stream  string
+	Group   string
+	Pending int64
+}
+
+// NewRedisMetrics creates Redis metrics helper
+func (m *Metrics) NewRedisMetrics() *RedisMetrics {
+	// Pipeline sizes are counts, not durations. An error means an earlier
+	// helper already registered the histogram.
+	_ = m.RegisterHistogram("redis_pipeline_size", "Number of commands per Redis pipeline",
+		nil, prometheus.ExponentialBuckets(1, 2, 10))
+
+	return &RedisMetrics{m: m}
+}
+
+// CommandExecuted records the latency of a Redis command, duration is in seconds
+func (rm *RedisMetrics) CommandExecuted(command string, duration float64) {
+	rm.m.recordDuration("redis_command_duration", duration, MetricLabels{
+		"command": command,
+	})
+}
+
+// CommandExecutedDuration records the latency of a Redis command, like
+// CommandExecuted with a time.Duration
+func (rm *RedisMetrics) CommandExecutedDuration(command string, duration time.Duration) {
+	rm.CommandExecuted(command, duration.Seconds())
+}
+
+// CommandFailed increments the command errors counter
+func (rm *RedisMetrics) CommandFailed(command string) {
+	rm.m.IncrementCounter("redis_command_errors_total", MetricLabels{
+		"command": command,
+	})
+}
+
+// PipelineExecuted records the size and latency of a Redis pipeline,
+// duration is in seconds
+func (rm *RedisMetrics) PipelineExecuted(size int, duration float64) {
+	rm.m.RecordHistogram("redis_pipeline_size", float64(size), nil)
+	rm.m.recordDuration("redis_pipeline_duration", duration, nil)
+}
+
+// PipelineExecutedDuration records the size and latency of a Redis pipeline,
+// like PipelineExecuted with a time.Duration
+func (rm *RedisMetrics) PipelineExecutedDuration(size int, duration time.Duration) {
+	rm.PipelineExecuted(size, duration.Seconds())
+}
+
+// CollectPoolStats periodically exports the connection pool statistics
+// returned by stats until ctx is cancelled. Cumulative values are exported as
+// counters, point-in-time values as gauges.
+func (rm *RedisMetrics) CollectPoolStats(ctx context.Context, pool string, stats func() RedisPoolStats, interval time.Duration) {
+	if interval <= 0 {
+		interval = rm.m.config.PushInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		var last RedisPoolStats
+		for {
+			last = rm.recordPoolStats(stats(), last, pool)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// recordPoolStats exports current pool statistics and returns them so the
+// next call can compute counter deltas
+func (rm *RedisMetrics) recordPoolStats(stats, last RedisPoolStats, pool string) RedisPoolStats {
+	labels := MetricLabels{"pool": pool}
+
+	rm.m.SetGauge("redis_pool_connections_total", float64(stats.TotalConns), labels)
+	rm.m.SetGauge("redis_pool_connections_idle", float64(stats.IdleConns), labels)
+
+	rm.m.IncrementCounterBy("redis_pool_hits_total", poolDelta(stats.Hits, last.Hits), labels)
+	rm.m.IncrementCounterBy("redis_pool_misses_total", poolDelta(stats.Misses, last.Misses), labels)
+	rm.m.IncrementCounterBy("redis_pool_timeouts_total", poolDelta(stats.Timeouts, last.Timeouts), labels)
+	rm.m.IncrementCounterBy("redis_pool_stale_connections_total", poolDelta(stats.StaleConns, last.StaleConns), labels)
+
+	return stats
+}
+
+// poolDelta returns the increase of a cumulative pool counter, treating a
+// decrease as a reset of the pool
+func poolDelta(current, last uint32) float64 {
+	if current < last {
+		return float64(current)
+	}
+	return float64(current - last)
+}
+
+// CollectKeyStats periodically exports the key statistics returned by stats
+// until ctx is cancelled, as redis_key_length{key},
+// redis_stream_pending{stream,group} and one redis_server_<field> gauge per
+// INFO field. Statistics returned along with an error are still exported;
+// errors are counted in redis_key_stats_errors_total.
+func (rm *RedisMetrics) CollectKeyStats(ctx context.Context, stats func(context.Context) (RedisKeyStats, error), interval time.Duration) {
+	if interval <= 0 {
+		interval = rm.m.config.PushInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s, err := stats(ctx)
+			if err != nil && ctx.Err() == nil {
+				rm.m.IncrementCounter("redis_key_stats_errors_total", nil)
+				rm.m.logf("Failed to read Redis key stats: %v", err)
+			}
+			rm.recordKeyStats(s)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// recordKeyStats exports key statistics
+func (rm *RedisMetrics) recordKeyStats(stats RedisKeyStats) {
+	for key, length := range stats.Lengths {
+		rm.m.SetGauge("redis_key_length", float64(length), MetricLabels{"key": key})
+	}
+	for _, p := range stats.Pending {
+		rm.m.SetGauge("redis_stream_pending", float64(p.Pending), MetricLabels{
+			"stream": p.Stream,
+			"group":  p.Group,
+		})
+	}
+	for field, value := range stats.Info {
+		rm.m.SetGauge("redis_server_"+field, value, nil)
+	}
+}
+
+// QueueMetrics provides background job queue metrics helpers
+type QueueMetrics struct {
+	m *Metrics
+}
+
+// NewQueueMetrics creates queue metrics helper
+func (m *Metrics) NewQueueMetrics() *QueueMetrics {
+	return &QueueMetrics{m: m}
+}
+
+// JobEnqueued increments the enqueued jobs counter
+func (qm *QueueMetrics) JobEnqueued(queue string) {
+	qm.m.IncrementCounter("queue_jobs_enqueued_total", MetricLabels{
+		"queue": queue,
+	})
+}
+
+// JobDequeued increments the dequeued jobs counter
+func (qm *QueueMetrics) JobDequeued(queue string) {
+	qm.m.IncrementCounter("queue_jobs_dequeued_total", MetricLabels{
+		"queue": queue,
+	})
+}
+
+// JobCompleted records a successfully processed job, duration is in seconds
+func (qm *QueueMetrics) JobCompleted(queue string, duration float64) {
+	qm.jobFinished(queue, duration, "success")
+	qm.m.IncrementCounter("queue_jobs_completed_total", MetricLabels{
+		"queue": queue,
+	})
+}
+
+// JobCompletedDuration records a successfully processed job, like
+// JobCompleted with a time.Duration
+func (qm *QueueMetrics) JobCompletedDuration(queue string, duration time.Duration) {
+	qm.JobCompleted(queue, duration.Seconds())
+}
+
+// JobFailed records a failed job, duration is in seconds
+func (qm *QueueMetrics) JobFailed(queue string, duration float64) {
+	qm.jobFinished(queue, duration, "error")
+	qm.m.IncrementCounter("queue_jobs_failed_total", MetricLabels{
+		"queue": queue,
+	})
+}
+
+// JobFailedDuration records a failed job, like JobFailed with a time.Duration
+func (qm *QueueMetrics) JobFailedDuration(queue string, duration time.Duration) {
+	qm.JobFailed(queue, duration.Seconds())
+}
+
+// JobRetried increments the job retries counter
+func (qm *QueueMetrics) JobRetried(queue string) {
+	qm.m.IncrementCounter("queue_job_retries_total", MetricLabels{
+		"queue": queue,
+	})
+}
+
+// SetQueueDepth sets the number of jobs waiting in a queue
+func (qm *QueueMetrics) SetQueueDepth(queue string, depth float64) {
+	qm.m.SetGauge("queue_depth", depth, MetricLabels{
+		"queue": queue,
+	})
+}
+
+// Instrument runs fn as a job of queue, recording it as completed or failed
+// depending on the returned error. A panicking job is recorded as failed
+// before the panic continues.
+func (qm *QueueMetrics) Instrument(queue string, fn func() error) error {
+	start := time.Now()
+	finished := false
+
+	defer func() {
+		if !finished {
+			qm.JobFailed(queue, time.Since(start).Seconds())
+		}
+	}()
+
+	err := fn()
+	finished = true
+
+	if err != nil {
+		qm.JobFailed(queue, time.Since(start).Seconds())
+	} else {
+		qm.JobCompleted(queue, time.Since(start).Seconds())
+	}
+	return err
+}
+
+// jobFinished records the processing duration of a job
+func (qm *QueueMetrics) jobFinished(queue string, duration float64, status string) {
+	qm.m.recordDuration("queue_job_duration", duration, MetricLabels{
+		"queue":  queue,
+		"status": status,
+	})
+}
+
 // BusinessMetrics provides business-specific metrics helpers
 type BusinessMetrics struct {
 	m *Metrics
@@ -156,6 +745,9 @@ type BusinessMetrics struct {
 
 // NewBusinessMetrics creates business metrics helper
 func (m *Metrics) NewBusinessMetrics() *BusinessMetrics {
+	if m.config.CurrencyRates != nil {
+		m.registerAmountHistograms()
+	}
 	return &BusinessMetrics{m: m}
 }
 
@@ -181,17 +773,23 @@ func (bm *BusinessMetrics) MatchStarted(matchType string) {
 	})
 }
 
-// MatchCompleted records match completion with duration
+// MatchCompleted records match completion with duration in seconds
 func (bm *BusinessMetrics) MatchCompleted(matchType string, duration float64) {
 	bm.m.IncrementCounter("matches_completed_total", MetricLabels{
 		"type": matchType,
 	})
 
-	bm.m.RecordHistogram("match_duration_seconds", duration, MetricLabels{
+	bm.m.recordDuration("match_duration", duration, MetricLabels{
 		"type": matchType,
 	})
 }
 
+// MatchCompletedDuration records match completion, like MatchCompleted with a
+// time.Duration
+func (bm *BusinessMetrics) MatchCompletedDuration(matchType string, duration time.Duration) {
+	bm.MatchCompleted(matchType, duration.Seconds())
+}
+
 // SetActiveMatches sets the active matches gauge
 func (bm *BusinessMetrics) SetActiveMatches(count float64) {
 	bm.m.SetGauge("matches_active", count, nil)
@@ -201,3 +799,255 @@ func (bm *BusinessMetrics) SetActiveMatches(count float64) {
 func (bm *BusinessMetrics) LeaderboardUpdated() {
 	bm.m.IncrementCounter("leaderboard_updates_total", nil)
 }
+
+// PaymentProcessed counts a successful payment and adds its amount to the
+// revenue of its currency, an ISO 4217 code such as "EUR"
+func (bm *BusinessMetrics) PaymentProcessed(provider, currency string, amount float64) {
+	currency = strings.ToUpper(currency)
+	bm.m.IncrementCounter("payments_processed_total", MetricLabels{
+		"provider": provider,
+		"currency": currency,
+	})
+	bm.AddRevenue(currency, amount)
+	bm.recordUSD("payment_amount_usd", provider, currency, amount)
+}
+
+// PaymentFailed counts a failed payment, e.g. with reason "card_declined".
+// Reasons should come from a fixed set such as the provider's error codes.
+func (bm *BusinessMetrics) PaymentFailed(provider, reason string) {
+	bm.m.IncrementCounter("payments_failed_total", MetricLabels{
+		"provider": provider,
+		"reason":   reason,
+	})
+}
+
+// RefundIssued counts a refund and adds its amount to the refunded amount of
+// its currency. Revenue is not reduced, as counters cannot decrease; net
+// revenue is revenue_total minus refunds_amount_total.
+func (bm *BusinessMetrics) RefundIssued(provider, currency string, amount float64) {
+	currency = strings.ToUpper(currency)
+	bm.m.IncrementCounter("refunds_total", MetricLabels{
+		"provider": provider,
+		"currency": currency,
+	})
+	bm.m.IncrementCounterBy("refunds_amount_total", amount, MetricLabels{
+		"currency": currency,
+	})
+	bm.recordUSD("refund_amount_usd", provider, currency, amount)
+}
+
+// AddRevenue adds revenue that is not a payment, e.g. from invoices settled
+// outside of the service. PaymentProcessed adds revenue already. With
+// CurrencyRates set, revenue_usd_total sums the revenue of all currencies.
+func (bm *BusinessMetrics) AddRevenue(currency string, amount float64) {
+	currency = strings.ToUpper(currency)
+	bm.m.IncrementCounterBy("revenue_total", amount, MetricLabels{
+		"currency": currency,
+	})
+	if usd, ok := bm.usd("revenue_usd_total", currency, amount); ok {
+		bm.m.IncrementCounterBy("revenue_usd_total", usd, nil)
+	}
+}
+
+// sessionBuckets are the buckets of session durations in seconds, from a
+// minute to a week
+var sessionBuckets = []float64{60, 300, 900, 1800, 3600, 4 * 3600, 12 * 3600, 24 * 3600, 3 * 24 * 3600, 7 * 24 * 3600}
+
+// AuthMetrics provides authentication and session metrics helpers
+type AuthMetrics struct {
+	m *Metrics
+}
+
+// NewAuthMetrics creates authentication metrics helper
+func (m *Metrics) NewAuthMetrics() *AuthMetrics {
+	m.ensureHistogram("auth_session_duration"+m.config.DurationUnit.suffix(), "Duration of ended sessions",
+		nil, m.config.DurationUnit.buckets(sessionBuckets))
+	return &AuthMetrics{m: m}
+}
+
+// LoginSucceeded counts a successful login by method, e.g. "password",
+// "oauth" or "sso"
+func (am *AuthMetrics) LoginSucceeded(method string) {
+	am.m.IncrementCounter("auth_logins_total", MetricLabels{
+		"method": method,
+		"result": "success",
+	})
+}
+
+// LoginFailed counts a failed login by method and reason, e.g.
+// "invalid_credentials" or "mfa_failed"
+func (am *AuthMetrics) LoginFailed(method, reason string) {
+	am.m.IncrementCounter("auth_logins_total", MetricLabels{
+		"method": method,
+		"result": "failure",
+	})
+	am.m.IncrementCounter("auth_login_failures_total", MetricLabels{
+		"method": method,
+		"reason": reason,
+	})
+}
+
+// TokenIssued counts an issued token by type, e.g. "access" or "refresh"
+func (am *AuthMetrics) TokenIssued(tokenType string) {
+	am.m.IncrementCounter("auth_tokens_issued_total", MetricLabels{
+		"type": tokenType,
+	})
+}
+
+// TokenRefreshed counts a token refresh by type
+func (am *AuthMetrics) TokenRefreshed(tokenType string) {
+	am.m.IncrementCounter("auth_tokens_refreshed_total", MetricLabels{
+		"type": tokenType,
+	})
+}
+
+// TokenRevoked counts a revoked token by type
+func (am *AuthMetrics) TokenRevoked(tokenType string) {
+	am.m.IncrementCounter("auth_tokens_revoked_total", MetricLabels{
+		"type": tokenType,
+	})
+}
+
+// SessionStarted increments the active sessions
+func (am *AuthMetrics) SessionStarted() {
+	am.m.IncrementGauge("auth_sessions_active", nil)
+	am.m.IncrementCounter("auth_sessions_started_total", nil)
+}
+
+// SessionEnded decrements the active sessions and records the session
+// duration in seconds
+func (am *AuthMetrics) SessionEnded(duration float64) {
+	am.m.DecrementGauge("auth_sessions_active", nil)
+	am.m.recordDuration("auth_session_duration", duration, nil)
+}
+
+// SessionEndedDuration decrements the active sessions and records the
+// session duration, like SessionEnded with a time.Duration
+func (am *AuthMetrics) SessionEndedDuration(duration time.Duration) {
+	am.SessionEnded(duration.Seconds())
+}
+
+// SetActiveSessions sets the active sessions, e.g. from the session store
+// after a restart
+func (am *AuthMetrics) SetActiveSessions(count float64) {
+	am.m.SetGauge("auth_sessions_active", count, nil)
+}
+
+// RateLimited counts an authentication attempt rejected by rate limiting,
+// by endpoint, e.g. "/login"
+func (am *AuthMetrics) RateLimited(endpoint string) {
+	am.m.IncrementCounter("auth_rate_limited_total", MetricLabels{
+		"endpoint": endpoint,
+	})
+}
+
+// AccountLockedOut counts an account locked after repeated failed logins
+func (am *AuthMetrics) AccountLockedOut() {
+	am.m.IncrementCounter("auth_lockouts_total", nil)
+}
+
+// schedulerBuckets are the buckets of scheduled job durations in seconds,
+// from 100ms to an hour
+var schedulerBuckets = []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600}
+
+// SchedulerMetrics provides scheduled task and cron job metrics helpers
+type SchedulerMetrics struct {
+	m *Metrics
+}
+
+// NewSchedulerMetrics creates scheduled job metrics helper
+func (m *Metrics) NewSchedulerMetrics() *SchedulerMetrics {
+	m.ensureHistogram("scheduler_job_duration"+m.config.DurationUnit.suffix(), "Duration of scheduled job runs",
+		[]string{"job"}, m.config.DurationUnit.buckets(schedulerBuckets))
+	return &SchedulerMetrics{m: m}
+}
+
+// JobSucceeded records a successful run of a job, duration is in seconds
+func (sm *SchedulerMetrics) JobSucceeded(job string, duration float64) {
+	sm.jobFinished(job, duration, "success")
+	sm.m.SetGauge("scheduler_job_last_success_timestamp_seconds", float64(time.Now().Unix()), MetricLabels{
+		"job": job,
+	})
+}
+
+// JobSucceededDuration records a successful run of a job, like JobSucceeded
+// with a time.Duration
+func (sm *SchedulerMetrics) JobSucceededDuration(job string, duration time.Duration) {
+	sm.JobSucceeded(job, duration.Seconds())
+}
+
+// JobFailed records a failed run of a job, duration is in seconds
+func (sm *SchedulerMetrics) JobFailed(job string, duration float64) {
+	sm.jobFinished(job, duration, "failure")
+}
+
+// JobFailedDuration records a failed run of a job, like JobFailed with a
+// time.Duration
+func (sm *SchedulerMetrics) JobFailedDuration(job string, duration time.Duration) {
+	sm.JobFailed(job, duration.Seconds())
+}
+
+// JobMissed counts a run that was skipped, e.g. because the previous run
+// was still going or the process was down at the scheduled time
+func (sm *SchedulerMetrics) JobMissed(job string) {
+	sm.m.IncrementCounter("scheduler_job_missed_total", MetricLabels{
+		"job": job,
+	})
+}
+
+// SetNextRun sets the time of the next scheduled run of a job, so alerts can
+// fire when a job is overdue
+func (sm *SchedulerMetrics) SetNextRun(job string, next time.Time) {
+	sm.m.SetGauge("scheduler_job_next_run_timestamp_seconds", float64(next.Unix()), MetricLabels{
+		"job": job,
+	})
+}
+
+// Wrap returns fn instrumented as a run of job, recording it as succeeded or
+// failed depending on the returned error. A panicking run is recorded as
+// failed before the panic continues.
+func (sm *SchedulerMetrics) Wrap(job string, fn func() error) func() error {
+	return func() error {
+		start := time.Now()
+		finished := false
+
+		defer func() {
+			if !finished {
+				sm.JobFailed(job, time.Since(start).Seconds())
+			}
+		}()
+
+		err := fn()
+		finished = true
+
+		if err != nil {
+			sm.JobFailed(job, time.Since(start).Seconds())
+		} else {
+			sm.JobSucceeded(job, time.Since(start).Seconds())
+		}
+		return err
+	}
+}
+
+// WrapFunc is like Wrap for jobs without an error, such as those of
+// robfig/cron:
+//
+//	c.AddFunc("@hourly", scheduler.WrapFunc("cleanup", cleanup))
+func (sm *SchedulerMetrics) WrapFunc(job string, fn func()) func() {
+	wrapped := sm.Wrap(job, func() error {
+		fn()
+		return nil
+	})
+	return func() { _ = wrapped() }
+}
+
+// jobFinished records the result, duration and time of a run
+func (sm *SchedulerMetrics) jobFinished(job string, duration float64, result string) {
+	labels := MetricLabels{"job": job}
+	sm.m.IncrementCounter("scheduler_job_runs_total", MetricLabels{
+		"job":    job,
+		"result": result,
+	})
+	sm.m.recordDuration("scheduler_job_duration", duration, labels)
+	sm.m.SetGauge("scheduler_job_last_run_timestamp_seconds", float64(time.Now().Unix()), labels)
+}
@@ -0,0 +1,138 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// internalMetrics are self-observability metrics of the collector itself
+type internalMetrics struct {
+	FrozenRejections      *prometheus.CounterVec
+	CardinalityDropped    *prometheus.CounterVec
+	UnexpectedLabelValues *prometheus.CounterVec
+	RenamedUsage          *prometheus.CounterVec
+	PushFailures          *prometheus.CounterVec
+	Errors                *prometheus.CounterVec
+	ClockBackwards        *prometheus.CounterVec
+	RemoteWritePushes     *prometheus.CounterVec
+
+	// Overhead is only set when self-profiling is enabled
+	Overhead *prometheus.HistogramVec
+}
+
+// initInternalMetrics initializes and registers the internal metrics
+func (m *Metrics) initInternalMetrics() {
+	m.internal = &internalMetrics{
+		FrozenRejections: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_frozen_rejections_total",
+				Help:        "Attempts to create new metrics after Freeze",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"metric"},
+		),
+		CardinalityDropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_cardinality_dropped_total",
+				Help:        "Observations collapsed into the overflow series by the cardinality limit",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"metric"},
+		),
+		UnexpectedLabelValues: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_label_values_unexpected_total",
+				Help:        "Observations whose label value was not declared and was recorded as \"other\"",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"metric", "label"},
+		),
+		RenamedUsage: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_renamed_usage_total",
+				Help:        "Calls recording a custom metric by its old name, see MetricRenames",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"metric"},
+		),
+		PushFailures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_push_failures_total",
+				Help:        "Failed pushes to a remote metrics backend",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"target"},
+		),
+		Errors: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_errors_total",
+				Help:        "Failed updates of custom metrics, by reason",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"metric", "reason"},
+		),
+		ClockBackwards: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_clock_backwards_total",
+				Help:        "Pushes whose timestamp was adjusted because the clock went back",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"target"},
+		),
+		RemoteWritePushes: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_remote_write_pushes_total",
+				Help:        "Remote write requests by target and result",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"target", "result"},
+		),
+	}
+
+	m.registry.MustRegister(
+		m.internal.FrozenRejections,
+		m.internal.CardinalityDropped,
+		m.internal.UnexpectedLabelValues,
+		m.internal.RenamedUsage,
+		m.internal.PushFailures,
+		m.internal.Errors,
+		m.internal.ClockBackwards,
+		m.internal.RemoteWritePushes,
+	)
+
+	if m.config.EnableSelfProfiling {
+		m.internal.Overhead = prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "metrics_overhead_seconds",
+				Help:        "Time spent inside the metrics library per request",
+				Buckets:     prometheus.ExponentialBuckets(1e-6, 4, 10),
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"source"},
+		)
+		m.registry.MustRegister(m.internal.Overhead)
+	}
+}
+
+// pushFailed counts and logs a failed push to target
+func (m *Metrics) pushFailed(target string, err error) {
+	m.internal.PushFailures.WithLabelValues(target).Inc()
+	m.logf("Failed to push metrics to %s: %v", target, m.redactError(err))
+}
@@ -0,0 +1,107 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// jsonFamily is the JSON form of a MetricSnapshot. Non-finite values, which
+// JSON numbers cannot hold, are encoded as the strings "NaN", "+Inf" and
+// "-Inf".
+type jsonFamily struct {
+	Name    string       `json:"name"`
+	Type    string       `json:"type"`
+	Help    string       `json:"help"`
+	Samples []jsonSample `json:"samples"`
+}
+
+type jsonSample struct {
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     any               `json:"value,omitempty"`
+	Count     *uint64           `json:"count,omitempty"`
+	Sum       any               `json:"sum,omitempty"`
+	Buckets   []jsonBucket      `json:"buckets,omitempty"`
+	Quantiles []jsonQuantile    `json:"quantiles,omitempty"`
+}
+
+type jsonBucket struct {
+	UpperBound any    `json:"le"`
+	Count      uint64 `json:"count"`
+}
+
+type jsonQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    any     `json:"value"`
+}
+
+// JSONHandler returns a net/http handler serving all metrics as a JSON array
+// of families sorted by name, each with its type, help text and samples, for
+// internal admin UIs and consumers that cannot parse the text format
+func (m *Metrics) JSONHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := m.Snapshot()
+		if err != nil {
+			m.logf("Failed to gather metrics for JSON: %v", err)
+			// Serve everything that could be collected
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(jsonFamilies(snapshot))
+	})
+}
+
+// JSONEndpoint returns a Gin handler serving all metrics as JSON, see
+// JSONHandler
+func (m *Metrics) JSONEndpoint() gin.HandlerFunc {
+	handler := m.JSONHandler()
+	return func(c *gin.Context) {
+		handler.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+// jsonFamilies converts a snapshot to its JSON form
+func jsonFamilies(snapshot map[string]MetricSnapshot) []jsonFamily {
+	families := make([]jsonFamily, 0, len(snapshot))
+	for _, mf := range snapshot {
+		family := jsonFamily{
+			Name:    mf.Name,
+			Type:    mf.Type,
+			Help:    mf.Help,
+			Samples: make([]jsonSample, 0, len(mf.Samples)),
+		}
+		for _, s := range mf.Samples {
+			sample := jsonSample{Labels: s.Labels}
+			switch mf.Type {
+			case "histogram", "gaugehistogram", "summary":
+				sample.Count = &s.Count
+				sample.Sum = jsonNumber(s.Sum)
+				for _, b := range s.Buckets {
+					sample.Buckets = append(sample.Buckets, jsonBucket{UpperBound: jsonNumber(b.UpperBound), Count: b.Count})
+				}
+				for _, q := range s.Quantiles {
+					sample.Quantiles = append(sample.Quantiles, jsonQuantile{Quantile: q.Quantile, Value: jsonNumber(q.Value)})
+				}
+			default:
+				sample.Value = jsonNumber(s.Value)
+			}
+			family.Samples = append(family.Samples, sample)
+		}
+		families = append(families, family)
+	}
+	slices.SortFunc(families, func(a, b jsonFamily) int { return strings.Compare(a.Name, b.Name) })
+	return families
+}
+
+// jsonNumber returns f, or its string form if it is not finite
+func jsonNumber(f float64) any {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return f
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// KPI is a business metric computed periodically, e.g. by querying the
+// database for the orders of the last hour
+type KPI struct {
+	Name     string        // Gauge holding the result, e.g. "orders_last_hour"
+	Help     string        // Help text of the gauge
+	Interval time.Duration // Time between evaluations (defaults to 1m)
+	Timeout  time.Duration // Per-evaluation timeout (defaults to Interval)
+
+	// Evaluate computes the current value. On error the gauge keeps its
+	// previous value and kpi_evaluation_errors_total is incremented.
+	Evaluate func(ctx context.Context) (float64, error)
+}
+
+// StartKPI evaluates a KPI until ctx is cancelled and publishes the result as
+// a gauge. The KPI is evaluated on start and then at multiples of Interval
+// on the wall clock, like a cron schedule, so an hourly KPI runs on the hour.
+// Evaluation time is recorded as kpi_evaluation_duration_seconds.
+func (m *Metrics) StartKPI(ctx context.Context, kpi KPI) error {
+	if kpi.Evaluate == nil {
+		return fmt.Errorf("KPI %q: Evaluate is required", kpi.Name)
+	}
+	if kpi.Interval <= 0 {
+		kpi.Interval = time.Minute
+	}
+	if kpi.Timeout <= 0 {
+		kpi.Timeout = kpi.Interval
+	}
+	if err := m.RegisterGauge(kpi.Name, kpi.Help, nil); err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			m.evaluateKPI(ctx, kpi)
+
+			timer := time.NewTimer(untilNext(time.Now(), kpi.Interval))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// evaluateKPI evaluates a KPI once and publishes the result
+func (m *Metrics) evaluateKPI(ctx context.Context, kpi KPI) {
+	ctx, cancel := context.WithTimeout(ctx, kpi.Timeout)
+	defer cancel()
+
+	labels := MetricLabels{"kpi": kpi.Name}
+
+	start := time.Now()
+	value, err := kpi.Evaluate(ctx)
+	m.recordDuration("kpi_evaluation_duration", time.Since(start).Seconds(), labels)
+
+	if err != nil {
+		m.IncrementCounter("kpi_evaluation_errors_total", labels)
+		m.logf("Failed to evaluate KPI %s: %v", kpi.Name, err)
+		return
+	}
+	m.SetGauge(kpi.Name, value, nil)
+}
+
+// untilNext returns the time from now to the next multiple of interval since
+// the Unix epoch
+func untilNext(now time.Time, interval time.Duration) time.Duration {
+	return interval - time.Duration(now.UnixNano()%int64(interval))
+}
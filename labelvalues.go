@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"sync"
+)
+
+// labelEnums holds the values declared for labels of custom metrics
+type labelEnums struct {
+	mu      sync.RWMutex
+	allowed map[string]map[string]map[string]bool // By metric and label
+	logged  map[string]bool                       // Metric and label pairs an unexpected value was logged for
+}
+
+// newLabelEnums creates an empty set of declarations
+func newLabelEnums() *labelEnums {
+	return &labelEnums{
+		allowed: make(map[string]map[string]map[string]bool),
+		logged:  make(map[string]bool),
+	}
+}
+
+// DeclareLabelValues restricts a label of a metric to the allowed values.
+// Other values are recorded as OverflowLabelValue ("other") and counted in
+// metrics_label_values_unexpected_total, so a typo'd status string does not
+// fragment the series. Declaring a label again replaces its values.
+//
+//	m.DeclareLabelValues("orders_total", "status", []string{StatusPaid, StatusFailed})
+func (m *Metrics) DeclareLabelValues(metric, label string, allowed []string) {
+	e := m.labelEnums
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	labels, ok := e.allowed[metric]
+	if !ok {
+		labels = make(map[string]map[string]bool)
+		e.allowed[metric] = labels
+	}
+	values := make(map[string]bool, len(allowed))
+	for _, value := range allowed {
+		values[value] = true
+	}
+	labels[sanitizeLabelName(label)] = values
+}
+
+// enforceLabelValues returns labels with values that were not declared for
+// the metric replaced by OverflowLabelValue. labels is copied if changed.
+func (m *Metrics) enforceLabelValues(name string, labels MetricLabels) MetricLabels {
+	e := m.labelEnums
+	e.mu.RLock()
+	declared, ok := e.allowed[name]
+	if !ok {
+		e.mu.RUnlock()
+		return labels
+	}
+
+	var unexpected []string
+	for label, values := range declared {
+		if value, ok := labels[label]; ok && !values[value] {
+			unexpected = append(unexpected, label)
+		}
+	}
+	e.mu.RUnlock()
+	if len(unexpected) == 0 {
+		return labels
+	}
+
+	enforced := make(MetricLabels, len(labels))
+	for k, v := range labels {
+		enforced[k] = v
+	}
+	for _, label := range unexpected {
+		m.internal.UnexpectedLabelValues.WithLabelValues(name, label).Inc()
+		if e.logOnce(name + "\xff" + label) {
+			m.logf("Unexpected value %q of label %q of metric %s recorded as %q", labels[label], label, name, OverflowLabelValue)
+		}
+		enforced[label] = OverflowLabelValue
+	}
+	return enforced
+}
+
+// logOnce reports whether key is seen for the first time
+func (e *labelEnums) logOnce(key string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.logged[key] {
+		return false
+	}
+	e.logged[key] = true
+	return true
+}
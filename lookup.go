@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/sync/singleflight"
+)
+
+// metricLookup caches the custom metrics for lock-free lookups on the update
+// path. Entries are stored with m.mu held, next to the metric maps.
+type metricLookup struct {
+	counters   sync.Map // Name to *lookupEntry[*prometheus.CounterVec]
+	gauges     sync.Map // Name to *lookupEntry[*prometheus.GaugeVec]
+	histograms sync.Map // Name to *lookupEntry[*prometheus.HistogramVec]
+
+	// Creations in progress, so the first updates of a new metric create it
+	// once without blocking updates of other metrics
+	creating singleflight.Group
+}
+
+// lookupEntry is a cached metric with its sorted label keys
+type lookupEntry[V any] struct {
+	vec    V
+	schema []string
+}
+
+// clear drops all entries. Must be called with m.mu held.
+func (l *metricLookup) clear() {
+	l.counters.Clear()
+	l.gauges.Clear()
+	l.histograms.Clear()
+}
+
+// getOrCreate returns the metric cached under name, creating it if needed,
+// and checks its label keys. Concurrent first updates share one creation,
+// and the registry is only called outside m.mu.
+func getOrCreate[V prometheus.Collector](m *Metrics, cache *sync.Map, kind, name string, labelKeys []string, create func(name string) V, store func(V)) (V, error) {
+	if entry, ok := cache.Load(name); ok {
+		e := entry.(*lookupEntry[V])
+		return e.vec, checkSchema(e.schema, labelKeys)
+	}
+
+	var zero V
+	_, err, _ := m.lookup.creating.Do(kind+":"+name, func() (any, error) {
+		return nil, createMetric(m, cache, name, labelKeys, create, store)
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	entry, ok := cache.Load(name)
+	if !ok {
+		return zero, fmt.Errorf("%w: %s %q was reset while being created", ErrMetricConflict, kind, name)
+	}
+	e := entry.(*lookupEntry[V])
+	return e.vec, checkSchema(e.schema, labelKeys)
+}
+
+// createMetric creates and registers a custom metric unless it exists,
+// returning an error if creation is rejected or the registry refuses it,
+// e.g. because the name is taken by a metric of another type. create
+// receives the sanitized name.
+func createMetric[V prometheus.Collector](m *Metrics, cache *sync.Map, name string, labelKeys []string, create func(name string) V, store func(V)) error {
+	m.mu.Lock()
+	// Another goroutine may have created or registered it in the meantime
+	_, exists := cache.Load(name)
+	if !exists {
+		if m.rejectFrozen(name) {
+			m.mu.Unlock()
+			return ErrFrozen
+		}
+		m.checkSimilarNames(name, labelKeys)
+	}
+	m.mu.Unlock()
+	if exists {
+		return nil
+	}
+
+	metricName, err := m.metricName(name)
+	if err != nil {
+		return err
+	}
+	vec, err := registerShared(m, create(metricName))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrMetricConflict, err)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	store(vec)
+	m.schemas[name] = labelKeys
+	cache.Store(name, &lookupEntry[V]{vec: vec, schema: labelKeys})
+	m.own(name)
+	return nil
+}
+
+// registerShared registers vec, or returns the metric registered before by
+// a scope with the same subsystem and labels, e.g. by two
+// WithSubsystem("payments") of one parent
+func registerShared[V prometheus.Collector](m *Metrics, vec V) (V, error) {
+	err := m.registry.Register(vec)
+	if err == nil {
+		return vec, nil
+	}
+
+	// Counters and gauges with equal names and labels have equal
+	// descriptors, so the type must be checked
+	var registered prometheus.AlreadyRegisteredError
+	if errors.As(err, &registered) {
+		if existing, ok := registered.ExistingCollector.(V); ok {
+			return existing, nil
+		}
+	}
+	return vec, err
+}
@@ -2,11 +2,16 @@ package metrics
 
 import (
 	"context"
+	"io"
+	"maps"
 	"net/http"
 	"os"
+	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
@@ -19,58 +24,201 @@ type Metrics struct {
 	// HTTP metrics
 	httpMetrics *HTTPMetrics
 
+	// Health checks backing the /health endpoint
+	health *HealthChecker
+
 	// Custom metrics storage
 	counters   map[string]*prometheus.CounterVec
 	gauges     map[string]*prometheus.GaugeVec
 	histograms map[string]*prometheus.HistogramVec
 
+	// Custom metrics cached for lock-free lookups
+	lookup *metricLookup
+
+	// Team owning the metrics created through this scope, see WithTeam
+	team   string
+	owners *owners
+
+	// Self-observability metrics
+	internal *internalMetrics
+
+	// Reject new metric names once frozen
+	frozen         bool
+	frozenRejected map[string]bool
+
+	// Label value combinations per metric for cardinality limits
+	cardinality *cardinalityTracker
+
+	// Declared label values, see DeclareLabelValues
+	labelEnums *labelEnums
+
+	// Old metric names used since the rename, see MetricRenames
+	renames *renameLog
+
+	// Simulated pipeline failures, nil unless enabled
+	chaos *FailureInjector
+
+	// Recent samples of selected metrics, nil until StartHistory
+	history *history
+
+	// Anomaly detection, nil unless started
+	anomalies *anomalyDetector
+
+	// Recent per-route HTTP stats, nil unless started
+	routeStats *routeStatsTracker
+
+	// Declared SLOs fed by the HTTP middleware
+	slo *sloTracker
+
+	// Last updates of series with a TTL
+	ttl *ttlTracker
+
+	// Label keys seen so far, used for typo detection in dev mode
+	labelKeys map[string]bool
+
+	// Sorted label keys of every custom metric, by name
+	schemas map[string][]string
+
+	// Failed updates already logged
+	errLog *errorLog
+
+	// Timestamps of Grafana Cloud pushes
+	grafanaClock *pushClock
+
+	// Push credentials read from files
+	secrets *secretFiles
+
+	// Remote write requests queued on disk, nil unless PushWALDir is set
+	wal *pushWAL
+
+	// Failover to FallbackRemoteWrite, nil unless configured
+	failover *pushFailover
+
+	// Gauge ranges between pushes, nil unless PushSampleInterval is set
+	gaugeSampler *gaugeSampler
+
+	// Backoff of failing remote write targets
+	remoteWriteStates *remoteWriteStates
+
+	// Gauge histograms, shared with scopes
+	gaugeHistograms *gaugeHistograms
+
+	// Router passed to Setup, used to pre-create HTTP series
+	router *gin.Engine
+
+	// Routes of watched routers, shared with scopes
+	routes *routeWatch
+
+	// Business events defined with DefineEvent
+	events *eventSchemas
+
+	// Running push loops, flushed by Shutdown
+	pushers *pushLoops
+
+	// Counters of IncrementCounterAt, nil until first used
+	backfill *backfillCounters
+
+	// Working windows of custom histograms, nil unless StartHistogramWindows
+	windows atomic.Pointer[histogramWindows]
+
+	// Optional append-only event log
+	eventLog   io.Writer
+	eventLogMu sync.Mutex
+
 	mu sync.RWMutex
 }
 
-// NewMetrics creates a new metrics collector
+// NewMetrics creates a new metrics collector. If ServiceName is set, HTTP
+// metrics and the metrics and health endpoints are enabled even if their
+// Enable fields are false; use New to disable them.
 func NewMetrics(config *Config) *Metrics {
 	if config == nil {
 		config = DefaultConfig()
-	} else {
-		// Apply defaults for unset fields
-		if config.Namespace == "" {
-			config.Namespace = "app"
-		}
-		if config.HTTPBuckets == nil {
-			config.HTTPBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
-		}
-		if config.PushInterval == 0 {
-			config.PushInterval = 15 * time.Second
-		}
-		// Enable by default if not explicitly set
-		if !config.EnableHTTPMetrics && config.ServiceName != "" {
-			config.EnableHTTPMetrics = true
-		}
-		if !config.EnableMetricsEndpoint && config.ServiceName != "" {
-			config.EnableMetricsEndpoint = true
-		}
-		if !config.EnableHealthEndpoint && config.ServiceName != "" {
-			config.EnableHealthEndpoint = true
-		}
-		
-		// Auto-configure Grafana Cloud from environment variables
-		if config.GrafanaCloudURL == "" {
-			if url := os.Getenv("GRAFANA_CLOUD_URL"); url != "" {
-				config.GrafanaCloudURL = url
-				config.GrafanaCloudUser = os.Getenv("GRAFANA_CLOUD_USER")
-				config.GrafanaCloudAPIKey = os.Getenv("GRAFANA_CLOUD_KEY")
-			}
+		applyProfile(config)
+		return newMetrics(config)
+	}
+
+	applyProfile(config)
+	applyDefaults(config)
+
+	// Enable by default if not explicitly set
+	if !config.EnableHTTPMetrics && config.ServiceName != "" {
+		config.EnableHTTPMetrics = true
+	}
+	if !config.EnableMetricsEndpoint && config.ServiceName != "" {
+		config.EnableMetricsEndpoint = true
+	}
+	if !config.EnableHealthEndpoint && config.ServiceName != "" {
+		config.EnableHealthEndpoint = true
+	}
+	return newMetrics(config)
+}
+
+// applyDefaults sets unset fields of config other than the Enable fields
+func applyDefaults(config *Config) {
+	if config.Namespace == "" {
+		config.Namespace = "app"
+	}
+	if config.HTTPBuckets == nil {
+		config.HTTPBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+	}
+	if config.PushInterval == 0 {
+		config.PushInterval = 15 * time.Second
+	}
+
+	// Auto-configure Grafana Cloud from environment variables
+	if config.GrafanaCloudURL == "" {
+		if url := os.Getenv("GRAFANA_CLOUD_URL"); url != "" {
+			config.GrafanaCloudURL = url
+			config.GrafanaCloudUser = os.Getenv("GRAFANA_CLOUD_USER")
+			config.GrafanaCloudAPIKey = os.Getenv("GRAFANA_CLOUD_KEY")
 		}
 	}
+}
+
+// newMetrics creates a metrics collector from a config with defaults applied
+func newMetrics(config *Config) *Metrics {
+	if config.Logger == nil {
+		config.Logger = stdoutLogger{}
+	}
+	sanitizeConfig(config)
 
 	registry := prometheus.NewRegistry()
 
 	m := &Metrics{
-		config:     config,
-		registry:   registry,
-		counters:   make(map[string]*prometheus.CounterVec),
-		gauges:     make(map[string]*prometheus.GaugeVec),
-		histograms: make(map[string]*prometheus.HistogramVec),
+		config:      config,
+		registry:    registry,
+		counters:    make(map[string]*prometheus.CounterVec),
+		gauges:      make(map[string]*prometheus.GaugeVec),
+		histograms:  make(map[string]*prometheus.HistogramVec),
+		labelKeys:   make(map[string]bool),
+		schemas:     make(map[string][]string),
+		lookup:      &metricLookup{},
+		owners:      newOwners(),
+		errLog:      newErrorLog(),
+		cardinality: newCardinalityTracker(),
+		labelEnums:  newLabelEnums(),
+		renames:     newRenameLog(),
+		routes:      newRouteWatch(),
+		events:      newEventSchemas(),
+		ttl:         newTTLTracker(),
+		eventLog:    config.EventLog,
+
+		grafanaClock: newPushClock("grafana"),
+		secrets:      newSecretFiles(),
+
+		remoteWriteStates: newRemoteWriteStates(),
+		gaugeHistograms:   newGaugeHistograms(),
+		pushers:           &pushLoops{},
+	}
+
+	m.health = newHealthChecker(m)
+	m.slo = m.newSLOTracker()
+	m.initInternalMetrics()
+
+	if config.EnableFailureInjection {
+		m.chaos = &FailureInjector{}
+		m.registry.MustRegister(m.chaos)
 	}
 
 	// Initialize HTTP metrics if enabled
@@ -78,9 +226,70 @@ func NewMetrics(config *Config) *Metrics {
 		m.initHTTPMetrics()
 	}
 
-	// Start Grafana Cloud push if configured
-	if config.GrafanaCloudURL != "" && config.GrafanaCloudAPIKey != "" {
-		m.StartGrafanaPush(context.Background())
+	if config.PushWALDir != "" {
+		m.initPushWAL()
+	}
+	if config.FallbackRemoteWrite.URL != "" {
+		m.initFailover()
+	}
+
+	// Start Grafana Cloud push if configured. Push failures are logged by
+	// the loop, so a collector that is down at startup is retried.
+	if config.GrafanaCloudURL != "" || config.GrafanaCloudAPIKey != "" || config.GrafanaCloudAPIKeyFile != "" || len(config.RemoteWrite) > 0 {
+		if err := m.checkGrafanaConfig(); err != nil {
+			m.logf("Failed to start Grafana Cloud push: %v", m.redactError(err))
+		} else {
+			if config.PushSampleInterval > 0 {
+				m.startGaugeSampler(context.Background())
+			}
+			m.startPusher(context.Background(), "grafana", m.pushToGrafana, nil)
+		}
+	}
+
+	// Expire stale series of metrics with a TTL
+	if len(config.SeriesTTL) > 0 {
+		m.startSeriesSweeper(context.Background())
+	}
+
+	// Start in-process history if configured
+	if config.EnableDashboard {
+		m.enableDashboardHistory()
+	}
+	if len(config.HistoryMetrics) > 0 {
+		if err := m.StartHistory(context.Background()); err != nil {
+			m.logf("Failed to start metrics history: %v", err)
+		}
+	}
+	if config.HTTPRouteStatsWindow > 0 && m.httpMetrics != nil {
+		if err := m.StartRouteStats(context.Background()); err != nil {
+			m.logf("Failed to start route stats: %v", err)
+		}
+	}
+	if len(config.AnomalyMetrics) > 0 {
+		if err := m.StartAnomalyDetection(context.Background()); err != nil {
+			m.logf("Failed to start anomaly detection: %v", err)
+		}
+	}
+
+	// Dump all metrics on signals if configured
+	if config.DebugDumpOnSignal {
+		if err := m.DumpOnSignal(context.Background(), config.DebugDumpPath); err != nil {
+			m.logf("Failed to start debug dumps: %v", err)
+		}
+	}
+
+	// Start OTLP push if configured
+	if config.OTLPEndpoint != "" {
+		if err := m.StartOTLPPush(context.Background()); err != nil {
+			m.logf("Failed to start OTLP push: %v", err)
+		}
+	}
+
+	// Compare the clock against NTP if configured
+	if config.NTPServer != "" {
+		if err := m.StartClockSkewCheck(context.Background()); err != nil {
+			m.logf("Failed to start clock skew check: %v", err)
+		}
 	}
 
 	return m
@@ -88,6 +297,9 @@ func NewMetrics(config *Config) *Metrics {
 
 // initHTTPMetrics initializes HTTP-related metrics
 func (m *Metrics) initHTTPMetrics() {
+	constLabels := m.httpConstLabels()
+	requestLabels := m.requestLabelNames()
+
 	m.httpMetrics = &HTTPMetrics{
 		RequestsTotal: prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -95,20 +307,20 @@ func (m *Metrics) initHTTPMetrics() {
 				Subsystem:   m.config.Subsystem,
 				Name:        "http_requests_total",
 				Help:        "Total number of HTTP requests",
-				ConstLabels: m.config.ConstLabels,
+				ConstLabels: constLabels,
 			},
-			[]string{"method", "path", "status"},
+			requestLabels,
 		),
 		RequestDuration: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Namespace:   m.config.Namespace,
 				Subsystem:   m.config.Subsystem,
-				Name:        "http_request_duration_seconds",
-				Help:        "HTTP request duration in seconds",
-				Buckets:     m.config.HTTPBuckets,
-				ConstLabels: m.config.ConstLabels,
+				Name:        "http_request_duration" + m.config.DurationUnit.suffix(),
+				Help:        "HTTP request duration",
+				Buckets:     m.config.DurationUnit.buckets(m.config.HTTPBuckets),
+				ConstLabels: constLabels,
 			},
-			[]string{"method", "path", "status"},
+			requestLabels,
 		),
 		RequestSize: prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
@@ -117,7 +329,7 @@ func (m *Metrics) initHTTPMetrics() {
 				Name:        "http_request_size_bytes",
 				Help:        "HTTP request size in bytes",
 				Buckets:     prometheus.ExponentialBuckets(100, 10, 7),
-				ConstLabels: m.config.ConstLabels,
+				ConstLabels: constLabels,
 			},
 			[]string{"method", "path"},
 		),
@@ -128,9 +340,19 @@ func (m *Metrics) initHTTPMetrics() {
 				Name:        "http_response_size_bytes",
 				Help:        "HTTP response size in bytes",
 				Buckets:     prometheus.ExponentialBuckets(100, 10, 7),
-				ConstLabels: m.config.ConstLabels,
+				ConstLabels: constLabels,
 			},
-			[]string{"method", "path"},
+			[]string{"method", "path", "streamed"},
+		),
+		HandlerPanics: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "http_handler_panics_total",
+				Help:        "Total number of HTTP handler panics recovered by the middleware",
+				ConstLabels: constLabels,
+			},
+			[]string{"path"},
 		),
 		RequestsInFlight: prometheus.NewGauge(
 			prometheus.GaugeOpts{
@@ -138,19 +360,72 @@ func (m *Metrics) initHTTPMetrics() {
 				Subsystem:   m.config.Subsystem,
 				Name:        "http_requests_in_flight",
 				Help:        "Current number of HTTP requests being processed",
-				ConstLabels: m.config.ConstLabels,
+				ConstLabels: constLabels,
 			},
 		),
 	}
 
-	// Register HTTP metrics
-	m.registry.MustRegister(
-		m.httpMetrics.RequestsTotal,
-		m.httpMetrics.RequestDuration,
-		m.httpMetrics.RequestSize,
-		m.httpMetrics.ResponseSize,
-		m.httpMetrics.RequestsInFlight,
-	)
+	m.httpMetrics.WebSocket = m.newWebSocketUpgradeMetrics()
+	m.registry.MustRegister(m.httpMetrics.WebSocket.collectors()...)
+	m.registry.MustRegister(m.httpMetrics.HandlerPanics)
+
+	if m.config.HTTPInFlightPerRoute {
+		m.httpMetrics.RouteRequestsInFlight = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "http_route_requests_in_flight",
+				Help:        "Current number of HTTP requests being processed per route",
+				ConstLabels: constLabels,
+			},
+			[]string{"method", "path"},
+		)
+		m.registry.MustRegister(m.httpMetrics.RouteRequestsInFlight)
+	}
+
+	if m.config.HTTPTrackRetries {
+		m.httpMetrics.retries = m.newRetryTracker()
+	}
+
+	if m.config.HTTPTimeBreakdown {
+		m.httpMetrics.TimeBreakdown = m.newTimeBreakdown()
+		m.registry.MustRegister(m.httpMetrics.TimeBreakdown)
+	}
+
+	// Register HTTP metrics of the selected schema
+	if m.config.HTTPMetricSchema != HTTPSchemaOTel {
+		m.registry.MustRegister(
+			m.httpMetrics.RequestsTotal,
+			m.httpMetrics.RequestDuration,
+			m.httpMetrics.RequestSize,
+			m.httpMetrics.ResponseSize,
+			m.httpMetrics.RequestsInFlight,
+		)
+	}
+	if m.config.HTTPMetricSchema != HTTPSchemaLegacy {
+		m.httpMetrics.OTel = newOTelHTTPMetrics(m.config, constLabels)
+		m.registry.MustRegister(m.httpMetrics.OTel.collectors()...)
+	}
+}
+
+// httpConstLabels returns the constant labels of the HTTP metrics, adding
+// deployment_track from the DeploymentTrackEnv variable if configured
+func (m *Metrics) httpConstLabels() prometheus.Labels {
+	if m.config.DeploymentTrackEnv == "" {
+		return m.config.ConstLabels
+	}
+
+	track := os.Getenv(m.config.DeploymentTrackEnv)
+	if track == "" {
+		track = "stable"
+	}
+
+	labels := maps.Clone(m.config.ConstLabels)
+	if labels == nil {
+		labels = make(prometheus.Labels, 1)
+	}
+	labels["deployment_track"] = track
+	return labels
 }
 
 // IncrementCounter increments a counter metric
@@ -160,134 +435,262 @@ func (m *Metrics) IncrementCounter(name string, labels MetricLabels) {
 
 // IncrementCounterBy increments a counter by a specific value
 func (m *Metrics) IncrementCounterBy(name string, value float64, labels MetricLabels) {
-	counter := m.getOrCreateCounter(name, getLabelKeys(labels))
-	counter.With(prometheus.Labels(labels)).Add(value)
+	m.logEvent(EventCounterAdd, name, value, labels)
+	m.handleError(name, m.addCounter(name, value, labels, nil))
 }
 
 // SetGauge sets a gauge metric value
 func (m *Metrics) SetGauge(name string, value float64, labels MetricLabels) {
-	gauge := m.getOrCreateGauge(name, getLabelKeys(labels))
-	gauge.With(prometheus.Labels(labels)).Set(value)
+	m.logEvent(EventGaugeSet, name, value, labels)
+	m.handleError(name, m.setGauge(name, value, labels))
 }
 
 // IncrementGauge increments a gauge metric
 func (m *Metrics) IncrementGauge(name string, labels MetricLabels) {
-	gauge := m.getOrCreateGauge(name, getLabelKeys(labels))
-	gauge.With(prometheus.Labels(labels)).Inc()
+	m.logEvent(EventGaugeAdd, name, 1, labels)
+	m.handleError(name, m.addGauge(name, 1, labels))
 }
 
 // DecrementGauge decrements a gauge metric
 func (m *Metrics) DecrementGauge(name string, labels MetricLabels) {
-	gauge := m.getOrCreateGauge(name, getLabelKeys(labels))
-	gauge.With(prometheus.Labels(labels)).Dec()
+	m.logEvent(EventGaugeAdd, name, -1, labels)
+	m.handleError(name, m.addGauge(name, -1, labels))
 }
 
 // RecordHistogram records a histogram observation
 func (m *Metrics) RecordHistogram(name string, value float64, labels MetricLabels) {
-	histogram := m.getOrCreateHistogram(name, getLabelKeys(labels))
-	histogram.With(prometheus.Labels(labels)).Observe(value)
+	m.logEvent(EventHistogramObserve, name, value, labels)
+	m.handleError(name, m.observeHistogram(name, value, labels, nil))
 }
 
-// getOrCreateCounter gets or creates a counter metric
-func (m *Metrics) getOrCreateCounter(name string, labelKeys []string) *prometheus.CounterVec {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	if counter, exists := m.counters[name]; exists {
-		return counter
+// RecordHistogramWeighted records weight observations of value at once, e.g.
+// a client batch of 500 frames at 16ms. A weight of 0 records nothing.
+func (m *Metrics) RecordHistogramWeighted(name string, value float64, weight uint64, labels MetricLabels) {
+	if weight == 0 {
+		return
 	}
+	m.writeEvent(Event{Op: EventHistogramObserve, Name: name, Value: value, Labels: labels, Weight: weight})
+	m.handleError(name, m.observeHistogramWeighted(name, value, weight, labels))
+}
 
-	counter := prometheus.NewCounterVec(
-		prometheus.CounterOpts{
-			Namespace:   m.config.Namespace,
-			Subsystem:   m.config.Subsystem,
-			Name:        name,
-			Help:        name + " counter",
-			ConstLabels: m.config.ConstLabels,
-		},
-		labelKeys,
-	)
-
-	m.registry.MustRegister(counter)
-	m.counters[name] = counter
-
-	return counter
+// RecordHistogramBatch records all values at once, e.g. for ingestion
+// endpoints receiving arrays of measurements. The series is resolved once
+// instead of per value.
+func (m *Metrics) RecordHistogramBatch(name string, values []float64, labels MetricLabels) {
+	if len(values) == 0 {
+		return
+	}
+	for _, value := range values {
+		m.logEvent(EventHistogramObserve, name, value, labels)
+	}
+	m.handleError(name, m.observeHistogramBatch(name, values, labels))
 }
 
-// getOrCreateGauge gets or creates a gauge metric
-func (m *Metrics) getOrCreateGauge(name string, labelKeys []string) *prometheus.GaugeVec {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// addCounter adds value to a counter without logging an event, attaching
+// the exemplar if not nil
+func (m *Metrics) addCounter(name string, value float64, labels, exemplar MetricLabels) error {
+	name = m.currentName(name)
+	if value < 0 {
+		return ErrNegativeCounter
+	}
+	labels, err := m.validateUpdate(labels, exemplar)
+	if err != nil {
+		return err
+	}
+	counter, err := m.getOrCreateCounter(name, getLabelKeys(labels))
+	if err != nil {
+		return err
+	}
+	add(counter.With(m.seriesLabels(name, labels)), value, exemplar)
+	return nil
+}
 
-	if gauge, exists := m.gauges[name]; exists {
-		return gauge
+// setGauge sets a gauge without logging an event
+func (m *Metrics) setGauge(name string, value float64, labels MetricLabels) error {
+	name = m.currentName(name)
+	labels, err := m.validateUpdate(labels, nil)
+	if err != nil {
+		return err
 	}
+	gauge, err := m.getOrCreateGauge(name, getLabelKeys(labels))
+	if err != nil {
+		return err
+	}
+	gauge.With(m.seriesLabels(name, labels)).Set(value)
+	return nil
+}
 
-	gauge := prometheus.NewGaugeVec(
-		prometheus.GaugeOpts{
-			Namespace:   m.config.Namespace,
-			Subsystem:   m.config.Subsystem,
-			Name:        name,
-			Help:        name + " gauge",
-			ConstLabels: m.config.ConstLabels,
-		},
-		labelKeys,
-	)
+// addGauge adds value to a gauge without logging an event
+func (m *Metrics) addGauge(name string, value float64, labels MetricLabels) error {
+	name = m.currentName(name)
+	labels, err := m.validateUpdate(labels, nil)
+	if err != nil {
+		return err
+	}
+	gauge, err := m.getOrCreateGauge(name, getLabelKeys(labels))
+	if err != nil {
+		return err
+	}
+	gauge.With(m.seriesLabels(name, labels)).Add(value)
+	return nil
+}
 
-	m.registry.MustRegister(gauge)
-	m.gauges[name] = gauge
+// observeHistogram records a histogram observation without logging an
+// event, attaching the exemplar if not nil
+func (m *Metrics) observeHistogram(name string, value float64, labels, exemplar MetricLabels) error {
+	name = m.currentName(name)
+	labels, err := m.validateUpdate(labels, exemplar)
+	if err != nil {
+		return err
+	}
+	observer, err := m.histogramObserver(name, labels)
+	if err != nil {
+		return err
+	}
+	observe(observer, value, exemplar)
+	return nil
+}
 
-	return gauge
+// observeHistogramWeighted observes value weight times without logging an
+// event. The client library has no weighted observations, so the series is
+// resolved once and observed in a loop.
+func (m *Metrics) observeHistogramWeighted(name string, value float64, weight uint64, labels MetricLabels) error {
+	name = m.currentName(name)
+	labels, err := m.validateUpdate(labels, nil)
+	if err != nil {
+		return err
+	}
+	observer, err := m.histogramObserver(name, labels)
+	if err != nil {
+		return err
+	}
+	for range weight {
+		observer.Observe(value)
+	}
+	return nil
 }
 
-// getOrCreateHistogram gets or creates a histogram metric
-func (m *Metrics) getOrCreateHistogram(name string, labelKeys []string) *prometheus.HistogramVec {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// observeHistogramBatch observes all values without logging events
+func (m *Metrics) observeHistogramBatch(name string, values []float64, labels MetricLabels) error {
+	name = m.currentName(name)
+	labels, err := m.validateUpdate(labels, nil)
+	if err != nil {
+		return err
+	}
+	observer, err := m.histogramObserver(name, labels)
+	if err != nil {
+		return err
+	}
+	for _, value := range values {
+		observer.Observe(value)
+	}
+	return nil
+}
 
-	if histogram, exists := m.histograms[name]; exists {
-		return histogram
+// histogramObserver returns the series of a histogram for labels, creating
+// the histogram if needed
+func (m *Metrics) histogramObserver(name string, labels MetricLabels) (prometheus.Observer, error) {
+	histogram, err := m.getOrCreateHistogram(name, getLabelKeys(labels))
+	if err != nil {
+		return nil, err
+	}
+	series := m.seriesLabels(name, labels)
+	if w := m.windows.Load(); w != nil {
+		return w.observer(name, series, histogram.With(series)), nil
 	}
+	return histogram.With(series), nil
+}
 
-	histogram := prometheus.NewHistogramVec(
-		prometheus.HistogramOpts{
-			Namespace:   m.config.Namespace,
-			Subsystem:   m.config.Subsystem,
-			Name:        name,
-			Help:        name + " histogram",
-			Buckets:     prometheus.DefBuckets,
-			ConstLabels: m.config.ConstLabels,
+// getOrCreateCounter gets or creates a counter metric, returning an error if
+// creation is rejected or the label keys do not match
+func (m *Metrics) getOrCreateCounter(name string, labelKeys []string) (*prometheus.CounterVec, error) {
+	return getOrCreate(m, &m.lookup.counters, "counter", name, labelKeys,
+		func(name string) *prometheus.CounterVec {
+			return prometheus.NewCounterVec(
+				prometheus.CounterOpts{
+					Namespace:   m.config.Namespace,
+					Subsystem:   m.config.Subsystem,
+					Name:        name,
+					Help:        name + " counter",
+					ConstLabels: m.config.ConstLabels,
+				},
+				labelKeys,
+			)
 		},
-		labelKeys,
+		func(counter *prometheus.CounterVec) { m.counters[name] = counter },
 	)
+}
 
-	m.registry.MustRegister(histogram)
-	m.histograms[name] = histogram
+// getOrCreateGauge gets or creates a gauge metric, returning an error if
+// creation is rejected or the label keys do not match
+func (m *Metrics) getOrCreateGauge(name string, labelKeys []string) (*prometheus.GaugeVec, error) {
+	return getOrCreate(m, &m.lookup.gauges, "gauge", name, labelKeys,
+		func(name string) *prometheus.GaugeVec {
+			return prometheus.NewGaugeVec(
+				prometheus.GaugeOpts{
+					Namespace:   m.config.Namespace,
+					Subsystem:   m.config.Subsystem,
+					Name:        name,
+					Help:        name + " gauge",
+					ConstLabels: m.config.ConstLabels,
+				},
+				labelKeys,
+			)
+		},
+		func(gauge *prometheus.GaugeVec) { m.gauges[name] = gauge },
+	)
+}
 
-	return histogram
+// getOrCreateHistogram gets or creates a histogram metric, returning an error if
+// creation is rejected or the label keys do not match
+func (m *Metrics) getOrCreateHistogram(name string, labelKeys []string) (*prometheus.HistogramVec, error) {
+	return getOrCreate(m, &m.lookup.histograms, "histogram", name, labelKeys,
+		func(name string) *prometheus.HistogramVec {
+			return prometheus.NewHistogramVec(
+				prometheus.HistogramOpts{
+					Namespace:   m.config.Namespace,
+					Subsystem:   m.config.Subsystem,
+					Name:        name,
+					Help:        name + " histogram",
+					Buckets:     defaultBuckets(name),
+					ConstLabels: m.config.ConstLabels,
+				},
+				labelKeys,
+			)
+		},
+		func(histogram *prometheus.HistogramVec) { m.histograms[name] = histogram },
+	)
 }
 
 // Handler returns the Prometheus HTTP handler
 func (m *Metrics) Handler() http.Handler {
-	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{
+	return promhttp.HandlerFor(m.gatherer(), promhttp.HandlerOpts{
 		EnableOpenMetrics: true,
 	})
 }
 
+// QualifiedName returns the exposed name of a custom metric, prefixed with
+// the namespace and subsystem and sanitized, e.g. "orders_total" becomes
+// "myapp_orders_total"
+func (m *Metrics) QualifiedName(name string) string {
+	return prometheus.BuildFQName(m.config.Namespace, m.config.Subsystem, SanitizeName(name))
+}
+
 // Registry returns the Prometheus registry
 func (m *Metrics) Registry() *prometheus.Registry {
 	return m.registry
 }
 
-// getLabelKeys extracts label keys from a label map
+// logf logs a message through the configured Logger
+func (m *Metrics) logf(format string, args ...any) {
+	m.config.Logger.Printf(format, args...)
+}
+
+// getLabelKeys extracts the sorted label keys from a label map
 func getLabelKeys(labels MetricLabels) []string {
 	if labels == nil {
 		return []string{}
 	}
 
-	keys := make([]string, 0, len(labels))
-	for k := range labels {
-		keys = append(keys, k)
-	}
-	return keys
+	return slices.Sorted(maps.Keys(labels))
 }
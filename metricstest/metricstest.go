@@ -0,0 +1,104 @@
+// Package metricstest provides assertions on metric values for unit tests.
+// Names are the same as passed to the metrics methods, without namespace.
+//
+//	m.IncrementCounter("orders_total", metrics.MetricLabels{"status": "paid"})
+//	metricstest.AssertCounterValue(t, m, "orders_total", metrics.MetricLabels{"status": "paid"}, 1)
+package metricstest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/OkanUysal/go-metrics"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// AssertCounterValue fails the test unless the counter series with labels
+// has value want
+func AssertCounterValue(t testing.TB, m *metrics.Metrics, name string, labels metrics.MetricLabels, want float64) {
+	t.Helper()
+	if sample, ok := find(t, m, name, "counter", labels); ok && sample.Value != want {
+		t.Errorf("counter %s%v = %v, want %v", name, labels, sample.Value, want)
+	}
+}
+
+// AssertGaugeValue fails the test unless the gauge series with labels has
+// value want
+func AssertGaugeValue(t testing.TB, m *metrics.Metrics, name string, labels metrics.MetricLabels, want float64) {
+	t.Helper()
+	if sample, ok := find(t, m, name, "gauge", labels); ok && sample.Value != want {
+		t.Errorf("gauge %s%v = %v, want %v", name, labels, sample.Value, want)
+	}
+}
+
+// AssertHistogramCount fails the test unless the histogram series with
+// labels has want observations
+func AssertHistogramCount(t testing.TB, m *metrics.Metrics, name string, labels metrics.MetricLabels, want uint64) {
+	t.Helper()
+	if sample, ok := find(t, m, name, "histogram", labels); ok && sample.Count != want {
+		t.Errorf("histogram %s%v has %d observations, want %d", name, labels, sample.Count, want)
+	}
+}
+
+// CollectAndCompare fails the test unless the metrics with the given names
+// match expected, in the text exposition format with qualified names. With no
+// names, all metrics are compared.
+func CollectAndCompare(t testing.TB, m *metrics.Metrics, expected string, names ...string) {
+	t.Helper()
+	qualified := make([]string, len(names))
+	for i, name := range names {
+		qualified[i] = m.QualifiedName(name)
+	}
+	if err := testutil.GatherAndCompare(m.Registry(), strings.NewReader(expected), qualified...); err != nil {
+		t.Error(err)
+	}
+}
+
+// find returns the only series of a metric of type typ whose labels include
+// labels. Labels that are not given, such as ConstLabels, are ignored.
+func find(t testing.TB, m *metrics.Metrics, name, typ string, labels metrics.MetricLabels) (metrics.SnapshotSample, bool) {
+	t.Helper()
+
+	snapshot, err := m.Snapshot()
+	if err != nil {
+		t.Errorf("failed to gather metrics: %v", err)
+		return metrics.SnapshotSample{}, false
+	}
+
+	family, ok := snapshot[m.QualifiedName(name)]
+	if !ok {
+		t.Errorf("metric %s not found", name)
+		return metrics.SnapshotSample{}, false
+	}
+	if family.Type != typ {
+		t.Errorf("metric %s is a %s, want %s", name, family.Type, typ)
+		return metrics.SnapshotSample{}, false
+	}
+
+	var found []metrics.SnapshotSample
+	for _, sample := range family.Samples {
+		if includes(sample.Labels, labels) {
+			found = append(found, sample)
+		}
+	}
+	switch len(found) {
+	case 0:
+		t.Errorf("metric %s has no series with labels %v", name, labels)
+		return metrics.SnapshotSample{}, false
+	case 1:
+		return found[0], true
+	default:
+		t.Errorf("metric %s has %d series with labels %v, give all labels", name, len(found), labels)
+		return metrics.SnapshotSample{}, false
+	}
+}
+
+// includes reports whether all labels are in have
+func includes(have map[string]string, labels metrics.MetricLabels) bool {
+	for key, value := range labels {
+		if v, ok := have[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
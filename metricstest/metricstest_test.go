@@ -0,0 +1,52 @@
+package metricstest
+
+import (
+	"testing"
+
+	"github.com/OkanUysal/go-metrics"
+)
+
+// recorder captures failures instead of failing the test
+type recorder struct {
+	testing.TB
+	failed bool
+}
+
+func (r *recorder) Helper()               {}
+func (r *recorder) Errorf(string, ...any) { r.failed = true }
+
+func TestAssertions(t *testing.T) {
+	m := metrics.NewMetrics(&metrics.Config{ServiceName: "test", Namespace: "test"})
+	m.IncrementCounterBy("orders_total", 2, metrics.MetricLabels{"status": "paid"})
+	m.IncrementCounter("orders_total", metrics.MetricLabels{"status": "failed"})
+	m.SetGauge("queue_depth", 5, nil)
+	m.RecordHistogram("latency_seconds", 0.2, nil)
+
+	AssertCounterValue(t, m, "orders_total", metrics.MetricLabels{"status": "paid"}, 2)
+	AssertGaugeValue(t, m, "queue_depth", nil, 5)
+	AssertHistogramCount(t, m, "latency_seconds", nil, 1)
+	CollectAndCompare(t, m, `
+		# HELP test_queue_depth queue_depth gauge
+		# TYPE test_queue_depth gauge
+		test_queue_depth 5
+	`, "queue_depth")
+
+	failures := map[string]func(testing.TB){
+		"wrong value": func(tb testing.TB) {
+			AssertCounterValue(tb, m, "orders_total", metrics.MetricLabels{"status": "paid"}, 3)
+		},
+		"missing": func(tb testing.TB) { AssertGaugeValue(tb, m, "nope", nil, 0) },
+		"wrong type": func(tb testing.TB) {
+			AssertGaugeValue(tb, m, "orders_total", metrics.MetricLabels{"status": "paid"}, 2)
+		},
+		"ambiguous":     func(tb testing.TB) { AssertCounterValue(tb, m, "orders_total", nil, 2) },
+		"no such label": func(tb testing.TB) { AssertCounterValue(tb, m, "orders_total", metrics.MetricLabels{"status": "x"}, 0) },
+	}
+	for name, assert := range failures {
+		r := &recorder{TB: t}
+		assert(r)
+		if !r.failed {
+			t.Errorf("%s: expected the assertion to fail", name)
+		}
+	}
+}
@@ -1,72 +1,342 @@
 package metrics
 
 import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"runtime/debug"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
-// Middleware returns a Gin middleware that collects HTTP metrics
-func (m *Metrics) Middleware() gin.HandlerFunc {
+// StatusFormat controls how the status label of HTTP metrics is rendered
+type StatusFormat int
+
+const (
+	// StatusCode labels with the numeric status code, e.g. "200" (default)
+	StatusCode StatusFormat = iota
+	// StatusText labels with the status text, e.g. "OK"
+	StatusText
+)
+
+// PathLabel controls how the path label of HTTP metrics is rendered
+type PathLabel int
+
+const (
+	// PathRoute labels with the matched route template, e.g. "/users/:id" (default)
+	PathRoute PathLabel = iota
+	// PathRaw labels with the raw request path, e.g. "/users/42".
+	// Beware that this creates one series per distinct URL.
+	PathRaw
+)
+
+// PanicHandling controls what the middleware does when a handler panics
+type PanicHandling int
+
+const (
+	// PanicIgnore leaves panics to other middleware such as gin.Recovery,
+	// without recording the request (default)
+	PanicIgnore PanicHandling = iota
+	// PanicRepanic records the request as a 500 and panics again, so an
+	// outer recovery middleware still handles it
+	PanicRepanic
+	// PanicRespond records the request as a 500, logs the panic and responds
+	// with a 500 status
+	PanicRespond
+)
+
+// MiddlewareOptions configures the HTTP metrics middleware
+type MiddlewareOptions struct {
+	StatusFormat StatusFormat
+	PathLabel    PathLabel
+
+	// UnmatchedPath is the path label for requests that matched no route
+	// when PathLabel is PathRoute (defaults to an empty label), and for
+	// paths PathNormalizer maps to ""
+	UnmatchedPath string
+
+	// PathNormalizer maps a raw request path to its label, e.g. /users/12345
+	// to /users/:id. It applies to all requests with PathRaw and to requests
+	// that matched no route with PathRoute. See NormalizeIDs and
+	// NormalizePaths.
+	PathNormalizer func(path string) string
+
+	// SkipPaths are request paths that are never measured
+	SkipPaths []string
+
+	// Skipper reports whether a request should not be measured
+	Skipper func(*gin.Context) bool
+
+	// Filter excludes requests by path prefix, method or status and
+	// downsamples routes, in addition to Config.HTTPMetricsFilter
+	Filter HTTPMetricsFilter
+
+	// MeasureRequestBody counts the request body bytes actually read by the
+	// handler, so chunked uploads without Content-Length are measured too
+	MeasureRequestBody bool
+
+	// Panics controls handler panics. Unless PanicIgnore, they are counted
+	// in http_handler_panics_total and the request is recorded.
+	Panics PanicHandling
+}
+
+// Middleware returns a Gin middleware that collects HTTP metrics, e.g.
+// m.Middleware(metrics.WithSkipMethods("OPTIONS"))
+func (m *Metrics) Middleware(options ...MiddlewareOption) gin.HandlerFunc {
+	var opts MiddlewareOptions
+	for _, option := range options {
+		option(&opts)
+	}
+	return m.MiddlewareWithOptions(opts)
+}
+
+// MiddlewareWithSkipper returns a middleware with a skipper function
+func (m *Metrics) MiddlewareWithSkipper(skipper func(*gin.Context) bool) gin.HandlerFunc {
+	return m.MiddlewareWithOptions(MiddlewareOptions{Skipper: skipper})
+}
+
+// GinMiddleware returns a Gin middleware for automatic metrics collection
+// that skips the /metrics and /health endpoints.
+//
+// Deprecated: Use MiddlewareWithOptions with SkipPaths instead. Status labels
+// are now numeric codes, matching Middleware.
+func (m *Metrics) GinMiddleware() gin.HandlerFunc {
+	return m.MiddlewareWithOptions(MiddlewareOptions{
+		SkipPaths: []string{"/metrics", "/health"},
+	})
+}
+
+// MiddlewareWithOptions returns a configurable Gin middleware that collects
+// HTTP metrics
+func (m *Metrics) MiddlewareWithOptions(opts MiddlewareOptions) gin.HandlerFunc {
 	if m.httpMetrics == nil {
+		// Return a no-op middleware if HTTP metrics are disabled
 		return func(c *gin.Context) {
 			c.Next()
 		}
 	}
 
+	filter := newHTTPFilter(m.config.HTTPMetricsFilter, opts.Filter, HTTPMetricsFilter{SkipPaths: opts.SkipPaths})
+
+	// All routes are registered by the time the first request arrives,
+	// unless they are mounted later and picked up by WatchRoutes
+	var precreate sync.Once
+	if m.config.HTTPPrecreateSeries {
+		m.onRoutesAdded(func(routes gin.RoutesInfo) {
+			m.precreateHTTPSeries(routes, opts, filter)
+		})
+	}
+
 	return func(c *gin.Context) {
+		if m.config.HTTPPrecreateSeries {
+			precreate.Do(func() {
+				if router := m.setupRouter(); router != nil {
+					m.precreateHTTPSeries(router.Routes(), opts, filter)
+				}
+			})
+		}
+		m.checkRoutes(time.Now())
+
+		if filter.skipRequest(c) || (opts.Skipper != nil && opts.Skipper(c)) {
+			c.Next()
+			return
+		}
+
+		// Upgrade handlers run for the lifetime of the connection, so they
+		// are measured as handshakes rather than requests
+		if isUpgradeRequest(c.Request) {
+			m.serveUpgrade(c, opts.path(c))
+			return
+		}
+
 		start := time.Now()
 
 		// Increment in-flight requests
 		m.httpMetrics.RequestsInFlight.Inc()
 		defer m.httpMetrics.RequestsInFlight.Dec()
 
-		// Record request size
-		if c.Request.ContentLength > 0 {
-			m.httpMetrics.RequestSize.WithLabelValues(
-				c.Request.Method,
-				c.FullPath(),
-			).Observe(float64(c.Request.ContentLength))
+		if otel := m.httpMetrics.OTel; otel != nil {
+			active := otel.ActiveRequests.WithLabelValues(c.Request.Method, scheme(c))
+			active.Inc()
+			defer active.Dec()
+		}
+
+		path := opts.path(c)
+
+		// Only matched routes are tracked so the series stay bounded
+		if route := c.FullPath(); route != "" && m.httpMetrics.RouteRequestsInFlight != nil {
+			inFlight := m.httpMetrics.RouteRequestsInFlight.WithLabelValues(c.Request.Method, route)
+			inFlight.Inc()
+			defer inFlight.Dec()
+		}
+
+		if m.httpMetrics.retries != nil {
+			m.httpMetrics.retries.record(c.Request, path)
+		}
+
+		// Histograms are only observed for sampled requests
+		sampled := sample(filter.sampleRate(c.FullPath(), m.config.HTTPSampleRate))
+
+		var body *countingReader
+		if opts.MeasureRequestBody && c.Request.Body != nil && c.Request.Body != http.NoBody {
+			body = &countingReader{ReadCloser: c.Request.Body}
+			c.Request.Body = body
+		}
+
+		writer := newResponseWriter(c.Writer)
+		c.Writer = writer
+
+		var times *requestTimes
+		if m.httpMetrics.TimeBreakdown != nil {
+			times = trackRequestTimes(c)
 		}
 
+		// Time spent in the library before handing over to the handler
+		overhead := time.Since(start)
+
 		// Process request
-		c.Next()
+		recovered, stack := serveNext(c, opts.Panics)
+		errorType := requestErrorType(c)
+		if recovered != nil {
+			m.httpMetrics.HandlerPanics.WithLabelValues(path).Inc()
+			errorType = ErrorTypePanic
+			if opts.Panics == PanicRepanic {
+				defer panic(recovered)
+			} else {
+				m.logf("Recovered panic in handler of %s %s: %v\n%s", c.Request.Method, path, recovered, stack)
+				c.Abort()
+			}
+			// Responses already started keep their status
+			if !c.Writer.Written() {
+				c.Writer.WriteHeader(http.StatusInternalServerError)
+				if opts.Panics == PanicRespond {
+					c.Writer.WriteHeaderNow()
+				}
+			}
+		}
+
+		if filter.skipStatus(c.Writer.Status()) {
+			return
+		}
 
-		// Calculate duration
-		duration := time.Since(start).Seconds()
-		status := strconv.Itoa(c.Writer.Status())
+		end := time.Now()
+		duration := end.Sub(start).Seconds()
 
-		// Record metrics
-		m.httpMetrics.RequestsTotal.WithLabelValues(
-			c.Request.Method,
-			c.FullPath(),
-			status,
-		).Inc()
+		requestSize := c.Request.ContentLength
+		if body != nil && body.n > requestSize {
+			requestSize = body.n
+		}
+
+		m.recordRequest(c, opts, path, errorType, duration, requestSize, writer, sampled)
+		m.recordSLO(c.Request.Method, c.FullPath(), c.Writer.Status())
+		if times != nil && sampled {
+			m.recordTimeBreakdown(c.Request.Method, path, times, duration)
+		}
+		if otel := m.httpMetrics.OTel; otel != nil {
+			otel.record(c, path, duration, requestSize, writer.size(), sampled)
+		}
+
+		if m.internal.Overhead != nil {
+			overhead += time.Since(end)
+			m.internal.Overhead.WithLabelValues("http_middleware").Observe(overhead.Seconds())
+		}
+	}
+}
+
+// recordRequest records the metrics of a completed request
+func (m *Metrics) recordRequest(c *gin.Context, opts MiddlewareOptions, path, errorType string, duration float64, requestSize int64, writer *responseWriter, sampled bool) {
+	code := c.Writer.Status()
+	labels := m.requestLabelValues(c.Request.Method, path, opts.status(code), code, errorType)
+	exemplar := requestExemplar(c.Request)
+
+	add(m.httpMetrics.RequestsTotal.WithLabelValues(labels...), 1, exemplar)
+
+	if !sampled {
+		return
+	}
 
-		m.httpMetrics.RequestDuration.WithLabelValues(
+	// Record request size
+	if requestSize > 0 {
+		m.httpMetrics.RequestSize.WithLabelValues(
 			c.Request.Method,
-			c.FullPath(),
-			status,
-		).Observe(duration)
+			path,
+		).Observe(float64(requestSize))
+	}
+
+	observe(m.httpMetrics.RequestDuration.WithLabelValues(labels...), m.config.DurationUnit.fromSeconds(duration), exemplar)
 
-		// Record response size
+	// Record response size, -1 means nothing was written
+	if size := writer.size(); size >= 0 {
 		m.httpMetrics.ResponseSize.WithLabelValues(
 			c.Request.Method,
-			c.FullPath(),
-		).Observe(float64(c.Writer.Size()))
+			path,
+			strconv.FormatBool(writer.streamed.Load()),
+		).Observe(float64(size))
 	}
 }
 
-// MiddlewareWithSkipper returns a middleware with a skipper function
-func (m *Metrics) MiddlewareWithSkipper(skipper func(*gin.Context) bool) gin.HandlerFunc {
-	middleware := m.Middleware()
+// serveNext runs the remaining handlers, returning the value of a panic
+// unless panics are ignored, and the stack of the panicking goroutine
+func serveNext(c *gin.Context, panics PanicHandling) (recovered any, stack []byte) {
+	if panics != PanicIgnore {
+		defer func() {
+			// The stack is taken before unwinding, so it shows where the
+			// handler panicked
+			if recovered = recover(); recovered != nil {
+				stack = debug.Stack()
+			}
+		}()
+	}
+	c.Next()
+	return nil, nil
+}
 
-	return func(c *gin.Context) {
-		if skipper(c) {
-			c.Next()
-			return
+// sample reports whether the current request is observed in HTTP histograms
+// according to a sample rate such as HTTPSampleRate
+func sample(rate float64) bool {
+	return rate <= 0 || rate >= 1 || rand.Float64() < rate
+}
+
+// path returns the path label for a request
+func (opts MiddlewareOptions) path(c *gin.Context) string {
+	if opts.PathLabel != PathRaw {
+		if route := c.FullPath(); route != "" {
+			return route
+		}
+		if opts.PathNormalizer == nil {
+			return opts.UnmatchedPath
+		}
+	}
+
+	path := c.Request.URL.Path
+	if opts.PathNormalizer != nil {
+		path = opts.PathNormalizer(path)
+		if path == "" {
+			return opts.UnmatchedPath
 		}
-		middleware(c)
 	}
+	return path
+}
+
+// status returns the status label for a status code
+func (opts MiddlewareOptions) status(code int) string {
+	if opts.StatusFormat == StatusText {
+		return http.StatusText(code)
+	}
+	return strconv.Itoa(code)
+}
+
+// countingReader counts the bytes read from a request body
+type countingReader struct {
+	io.ReadCloser
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	return n, err
 }
@@ -0,0 +1,211 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/common/model"
+)
+
+// Validate checks the names, URLs and intervals of c, returning an error
+// describing every problem found. Invalid names wrap ErrInvalidName.
+//
+// NewMetrics cannot fail, so it sanitizes invalid namespaces and label keys
+// of the config and logs a warning instead; call Validate first to reject
+// them:
+//
+//	if err := config.Validate(); err != nil {
+//	    log.Fatalf("invalid metrics config: %v", err)
+//	}
+func (c *Config) Validate() error {
+	var errs []error
+
+	for field, value := range map[string]string{
+		"Namespace":       c.Namespace,
+		"Subsystem":       c.Subsystem,
+		"ReplayNamespace": c.ReplayNamespace,
+	} {
+		if value != "" && !model.IsValidLegacyMetricName(value) {
+			errs = append(errs, fmt.Errorf("%w: %s %q must match %s", ErrInvalidName, field, value, metricNamePattern))
+		}
+	}
+	for key := range c.ConstLabels {
+		if !model.LabelName(key).IsValidLegacy() {
+			errs = append(errs, fmt.Errorf("%w: ConstLabels key %q must match %s", ErrInvalidName, key, labelNamePattern))
+		}
+	}
+	for key := range c.ExternalLabels {
+		if !model.LabelName(key).IsValidLegacy() {
+			errs = append(errs, fmt.Errorf("%w: ExternalLabels key %q must match %s", ErrInvalidName, key, labelNamePattern))
+		}
+	}
+
+	names := slices.Concat(slices.Collect(maps.Keys(c.SeriesTTL)), slices.Collect(maps.Keys(c.CardinalityLimits)), c.HistoryMetrics,
+		slices.Collect(maps.Keys(c.MetricRenames)), slices.Collect(maps.Values(c.MetricRenames)))
+	for _, name := range names {
+		if !model.IsValidLegacyMetricName(name) {
+			errs = append(errs, fmt.Errorf("%w: metric name %q must match %s", ErrInvalidName, name, metricNamePattern))
+		}
+	}
+
+	if c.GrafanaCloudURL != "" && !validPushURL(c.GrafanaCloudURL) {
+		errs = append(errs, fmt.Errorf("invalid GrafanaCloudURL %q", c.GrafanaCloudURL))
+	}
+	if err := validateRelabelRules(c.GrafanaCloudRelabel); err != nil {
+		errs = append(errs, fmt.Errorf("invalid GrafanaCloudRelabel: %w", err))
+	}
+	for team, target := range c.TeamRemoteWrite {
+		if !validPushURL(target.URL) {
+			errs = append(errs, fmt.Errorf("invalid TeamRemoteWrite URL %q of team %q", target.URL, team))
+		}
+		if err := validateRelabelRules(target.RelabelRules); err != nil {
+			errs = append(errs, fmt.Errorf("invalid RelabelRules of team %q: %w", team, err))
+		}
+	}
+	for _, target := range c.RemoteWrite {
+		if !validPushURL(target.URL) {
+			errs = append(errs, fmt.Errorf("invalid RemoteWrite URL %q", target.URL))
+		}
+		if err := validateRelabelRules(target.RelabelRules); err != nil {
+			errs = append(errs, fmt.Errorf("invalid RelabelRules of RemoteWrite %q: %w", target.name(), err))
+		}
+	}
+	if fallback := c.FallbackRemoteWrite.URL; fallback != "" && !validPushURL(fallback) {
+		errs = append(errs, fmt.Errorf("invalid FallbackRemoteWrite URL %q", fallback))
+	}
+	if c.PushInterval < 0 {
+		errs = append(errs, fmt.Errorf("PushInterval must not be negative, got %v", c.PushInterval))
+	}
+	for old, renamed := range c.MetricRenames {
+		if _, chained := c.MetricRenames[renamed]; chained {
+			errs = append(errs, fmt.Errorf("MetricRenames renames %q to %q, which is renamed again", old, renamed))
+		}
+	}
+	if c.PushSampleInterval < 0 {
+		errs = append(errs, fmt.Errorf("PushSampleInterval must not be negative, got %v", c.PushSampleInterval))
+	}
+	if c.HTTPSampleRate < 0 || c.HTTPSampleRate > 1 {
+		errs = append(errs, fmt.Errorf("HTTPSampleRate must be between 0 and 1, got %v", c.HTTPSampleRate))
+	}
+	for i := 1; i < len(c.HTTPBuckets); i++ {
+		if c.HTTPBuckets[i] <= c.HTTPBuckets[i-1] {
+			errs = append(errs, fmt.Errorf("HTTPBuckets must be strictly increasing, got %v", c.HTTPBuckets))
+			break
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+const (
+	metricNamePattern = "[a-zA-Z_:][a-zA-Z0-9_:]*"
+	labelNamePattern  = "[a-zA-Z_][a-zA-Z0-9_]*"
+)
+
+// SanitizeName returns name with every character not allowed in Prometheus
+// metric names replaced by "_", e.g. "cache-hits.total" becomes
+// "cache_hits_total". A "_" is prepended to names starting with a digit.
+func SanitizeName(name string) string {
+	if model.IsValidLegacyMetricName(name) {
+		return name
+	}
+	return sanitize(name, true)
+}
+
+// sanitizeLabelName is like SanitizeName for label keys, which cannot
+// contain colons
+func sanitizeLabelName(name string) string {
+	if model.LabelName(name).IsValidLegacy() {
+		return name
+	}
+	return sanitize(name, false)
+}
+
+// sanitize replaces the invalid characters of a metric name or label key
+func sanitize(name string, colons bool) string {
+	var b strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == ':' && colons:
+			b.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				b.WriteByte('_')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	if b.Len() == 0 {
+		return "_"
+	}
+	return b.String()
+}
+
+// metricName returns the name a custom metric is exposed under: name
+// sanitized, or an error if it is invalid and StrictNames is set
+func (m *Metrics) metricName(name string) (string, error) {
+	if model.IsValidLegacyMetricName(name) {
+		return name, nil
+	}
+	if m.config.StrictNames {
+		return "", fmt.Errorf("%w: metric name %q must match %s", ErrInvalidName, name, metricNamePattern)
+	}
+	return SanitizeName(name), nil
+}
+
+// sanitizeLabels returns labels with invalid keys sanitized, or an error
+// naming the first invalid key if StrictNames is set. Labels are only copied
+// if a key is invalid.
+func (m *Metrics) sanitizeLabels(labels MetricLabels) (MetricLabels, error) {
+	var sanitized MetricLabels
+	for key, value := range labels {
+		if model.LabelName(key).IsValidLegacy() {
+			continue
+		}
+		if m.config.StrictNames {
+			return nil, fmt.Errorf("%w: label key %q must match %s", ErrInvalidName, key, labelNamePattern)
+		}
+		if sanitized == nil {
+			sanitized = maps.Clone(labels)
+		}
+		delete(sanitized, key)
+		sanitized[sanitizeLabelName(key)] = value
+	}
+	if sanitized == nil {
+		return labels, nil
+	}
+	return sanitized, nil
+}
+
+// sanitizeConfig replaces the invalid namespaces and label keys of config,
+// which would make the registration of the HTTP metrics panic, logging each
+// replacement
+func sanitizeConfig(config *Config) {
+	for _, field := range []*string{&config.Namespace, &config.Subsystem, &config.ReplayNamespace} {
+		if *field != "" && !model.IsValidLegacyMetricName(*field) {
+			sanitized := SanitizeName(*field)
+			config.Logger.Printf("metrics: invalid name %q in config replaced by %q", *field, sanitized)
+			*field = sanitized
+		}
+	}
+
+	for _, labels := range []*map[string]string{(*map[string]string)(&config.ConstLabels), &config.ExternalLabels} {
+		for key := range *labels {
+			if model.LabelName(key).IsValidLegacy() {
+				continue
+			}
+			sanitized := make(map[string]string, len(*labels))
+			for key, value := range *labels {
+				sanitized[sanitizeLabelName(key)] = value
+			}
+			config.Logger.Printf("metrics: invalid label keys in config replaced by %v", sanitized)
+			*labels = sanitized
+			break
+		}
+	}
+}
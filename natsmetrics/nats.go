@@ -0,0 +1,88 @@
+// Package natsmetrics wires metrics.MessagingMetrics into nats.go
+// connections through connection callbacks and handler wrappers.
+//
+//	mm := m.NewMessagingMetrics()
+//	nc, err := nats.Connect(nats.DefaultURL, natsmetrics.Options(mm)...)
+//	sub, err := nc.Subscribe("orders.*", natsmetrics.Handler(mm, handleOrder))
+//	natsmetrics.CollectPending(ctx, mm, sub, 15*time.Second)
+package natsmetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/OkanUysal/go-metrics"
+	"github.com/nats-io/nats.go"
+)
+
+// Options returns connection options counting disconnects, reconnects and
+// asynchronous errors. They replace any DisconnectErrHandler,
+// ReconnectHandler and ErrorHandler set before them; call the metrics
+// methods from your own handlers instead if you need both.
+func Options(mm *metrics.MessagingMetrics) []nats.Option {
+	return []nats.Option{
+		nats.DisconnectErrHandler(func(*nats.Conn, error) {
+			mm.Disconnected()
+		}),
+		nats.ReconnectHandler(func(*nats.Conn) {
+			mm.Reconnected()
+		}),
+		nats.ErrorHandler(func(*nats.Conn, *nats.Subscription, error) {
+			mm.AsyncError()
+		}),
+	}
+}
+
+// Handler wraps a message handler to count received messages by the subject
+// of the subscription, e.g. "orders.*", so wildcard subscriptions do not
+// create one series per concrete subject
+func Handler(mm *metrics.MessagingMetrics, handler nats.MsgHandler) nats.MsgHandler {
+	return func(msg *nats.Msg) {
+		subject := msg.Subject
+		if msg.Sub != nil {
+			subject = msg.Sub.Subject
+		}
+		mm.MessageReceived(subject)
+		handler(msg)
+	}
+}
+
+// Publish publishes data to subject, recording the publish latency or the
+// failure. Subjects are used as labels, so avoid IDs in them.
+func Publish(mm *metrics.MessagingMetrics, nc *nats.Conn, subject string, data []byte) error {
+	start := time.Now()
+	if err := nc.Publish(subject, data); err != nil {
+		mm.PublishFailed(subject)
+		return err
+	}
+	mm.MessagePublished(subject, time.Since(start).Seconds())
+	return nil
+}
+
+// CollectPending periodically exports the messages and bytes pending in
+// sub until ctx is cancelled or the subscription is closed
+func CollectPending(ctx context.Context, mm *metrics.MessagingMetrics, sub *nats.Subscription, interval time.Duration) {
+	if interval <= 0 {
+		interval = 15 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			messages, bytes, err := sub.Pending()
+			if err != nil {
+				// The subscription was closed
+				return
+			}
+			mm.SetPending(sub.Subject, float64(messages), float64(bytes))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
@@ -0,0 +1,138 @@
+package natsmetrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/OkanUysal/go-metrics"
+	"github.com/OkanUysal/go-metrics/metricstest"
+	"github.com/nats-io/nats.go"
+)
+
+// startFakeServer serves the part of the NATS protocol the tests need:
+// PING is answered and every SUB gets one message "hello". It returns the
+// server URL.
+func startFakeServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				fmt.Fprint(conn, "INFO {\"server_id\":\"fake\",\"version\":\"2.10.0\",\"max_payload\":1048576}\r\n")
+
+				lines := bufio.NewScanner(conn)
+				for lines.Scan() {
+					fields := strings.Fields(lines.Text())
+					switch {
+					case len(fields) == 1 && fields[0] == "PING":
+						fmt.Fprint(conn, "PONG\r\n")
+					case len(fields) == 3 && fields[0] == "SUB":
+						fmt.Fprintf(conn, "MSG %s %s 5\r\nhello\r\n", fields[1], fields[2])
+					}
+				}
+			}()
+		}
+	}()
+	return "nats://" + ln.Addr().String()
+}
+
+func TestOptions(t *testing.T) {
+	m := metrics.New(metrics.WithoutHTTPMetrics())
+	opts := nats.GetDefaultOptions()
+	for _, opt := range Options(m.NewMessagingMetrics()) {
+		if err := opt(&opts); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts.DisconnectedErrCB(nil, errors.New("connection reset"))
+	opts.ReconnectedCB(nil)
+	opts.AsyncErrorCB(nil, nil, nats.ErrSlowConsumer)
+	opts.AsyncErrorCB(nil, nil, nats.ErrSlowConsumer)
+
+	metricstest.AssertCounterValue(t, m, "messaging_disconnects_total", nil, 1)
+	metricstest.AssertCounterValue(t, m, "messaging_reconnects_total", nil, 1)
+	metricstest.AssertCounterValue(t, m, "messaging_async_errors_total", nil, 2)
+}
+
+func TestHandler(t *testing.T) {
+	m := metrics.New(metrics.WithoutHTTPMetrics())
+	handled := 0
+	handler := Handler(m.NewMessagingMetrics(), func(*nats.Msg) { handled++ })
+
+	// Wildcard subscriptions are counted by their subject
+	handler(&nats.Msg{Subject: "orders.created", Sub: &nats.Subscription{Subject: "orders.*"}})
+	handler(&nats.Msg{Subject: "orders.paid", Sub: &nats.Subscription{Subject: "orders.*"}})
+	handler(&nats.Msg{Subject: "audit"})
+
+	if handled != 3 {
+		t.Errorf("Expected the wrapped handler to get 3 messages, got %d", handled)
+	}
+	metricstest.AssertCounterValue(t, m, "messaging_messages_received_total", metrics.MetricLabels{"subject": "orders.*"}, 2)
+	metricstest.AssertCounterValue(t, m, "messaging_messages_received_total", metrics.MetricLabels{"subject": "audit"}, 1)
+}
+
+func TestPublishAndPending(t *testing.T) {
+	m := metrics.New(metrics.WithoutHTTPMetrics())
+	mm := m.NewMessagingMetrics()
+
+	nc, err := nats.Connect(startFakeServer(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+
+	if err := Publish(mm, nc, "orders", []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Publish(mm, nc, "", []byte("{}")); !errors.Is(err, nats.ErrBadSubject) {
+		t.Errorf("Expected the publish error to be returned, got %v", err)
+	}
+	metricstest.AssertCounterValue(t, m, "messaging_messages_published_total", metrics.MetricLabels{"subject": "orders"}, 1)
+	metricstest.AssertHistogramCount(t, m, "messaging_publish_duration_seconds", metrics.MetricLabels{"subject": "orders"}, 1)
+	metricstest.AssertCounterValue(t, m, "messaging_publish_errors_total", metrics.MetricLabels{"subject": ""}, 1)
+
+	sub, err := nc.SubscribeSync("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Wait for the message of the fake server to be pending
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if messages, _, _ := sub.Pending(); messages > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	CollectPending(ctx, mm, sub, time.Hour)
+
+	pending := func() float64 {
+		snapshot, _ := m.Snapshot()
+		sample, _ := snapshot[m.QualifiedName("messaging_subscription_pending_messages")].Sample(map[string]string{"subject": "orders"})
+		return sample.Value
+	}
+	for pending() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	metricstest.AssertGaugeValue(t, m, "messaging_subscription_pending_messages", metrics.MetricLabels{"subject": "orders"}, 1)
+	metricstest.AssertGaugeValue(t, m, "messaging_subscription_pending_bytes", metrics.MetricLabels{"subject": "orders"}, 5)
+}
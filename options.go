@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Option configures a Metrics created with New
+type Option func(*Config)
+
+// New creates a metrics collector configured by options. Unlike NewMetrics,
+// HTTP metrics and the metrics and health endpoints are enabled unless
+// disabled by an option, whatever else is set:
+//
+//	m := metrics.New(
+//	    metrics.WithServiceName("orders"),
+//	    metrics.WithoutHTTPMetrics(),
+//	)
+func New(opts ...Option) *Metrics {
+	config := &Config{
+		ServiceName:           "app",
+		EnableHTTPMetrics:     true,
+		EnableMetricsEndpoint: true,
+		EnableHealthEndpoint:  true,
+		ConstLabels:           prometheus.Labels{},
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	applyProfile(config)
+	applyDefaults(config)
+	return newMetrics(config)
+}
+
+// WithServiceName sets the service name, "app" by default
+func WithServiceName(name string) Option {
+	return func(c *Config) { c.ServiceName = name }
+}
+
+// WithNamespace sets the Prometheus namespace, "app" by default
+func WithNamespace(namespace string) Option {
+	return func(c *Config) { c.Namespace = namespace }
+}
+
+// WithSubsystem sets the Prometheus subsystem
+func WithSubsystem(subsystem string) Option {
+	return func(c *Config) { c.Subsystem = subsystem }
+}
+
+// WithEnvironment selects an environment profile, see Profiles
+func WithEnvironment(environment string) Option {
+	return func(c *Config) { c.Environment = environment }
+}
+
+// WithConstLabels adds labels to all metrics
+func WithConstLabels(labels prometheus.Labels) Option {
+	return func(c *Config) {
+		if c.ConstLabels == nil {
+			c.ConstLabels = make(prometheus.Labels, len(labels))
+		}
+		for k, v := range labels {
+			c.ConstLabels[k] = v
+		}
+	}
+}
+
+// WithLogger sets the logger of warnings and errors, stdout by default
+func WithLogger(logger Logger) Option {
+	return func(c *Config) { c.Logger = logger }
+}
+
+// WithHTTPBuckets sets the histogram buckets of HTTP durations in seconds
+func WithHTTPBuckets(buckets ...float64) Option {
+	return func(c *Config) { c.HTTPBuckets = buckets }
+}
+
+// WithoutHTTPMetrics disables the HTTP metrics, e.g. for workers without an
+// HTTP server; Middleware then does nothing
+func WithoutHTTPMetrics() Option {
+	return func(c *Config) { c.EnableHTTPMetrics = false }
+}
+
+// WithoutMetricsEndpoint keeps Setup from registering /metrics
+func WithoutMetricsEndpoint() Option {
+	return func(c *Config) { c.EnableMetricsEndpoint = false }
+}
+
+// WithoutHealthEndpoint keeps Setup from registering /health
+func WithoutHealthEndpoint() Option {
+	return func(c *Config) { c.EnableHealthEndpoint = false }
+}
+
+// WithGrafanaCloud pushes to Grafana Cloud every interval, 15s if zero
+func WithGrafanaCloud(url, user, apiKey string, interval time.Duration) Option {
+	return func(c *Config) {
+		c.GrafanaCloudURL = url
+		c.GrafanaCloudUser = user
+		c.GrafanaCloudAPIKey = apiKey
+		c.PushInterval = interval
+	}
+}
+
+// WithDebugDump writes a debug dump of all metrics to path, or to stderr if
+// path is empty, on SIGUSR1 and SIGQUIT (see DumpOnSignal)
+func WithDebugDump(path string) Option {
+	return func(c *Config) {
+		c.DebugDumpOnSignal = true
+		c.DebugDumpPath = path
+	}
+}
+
+// WithConfig sets fields without a dedicated option:
+//
+//	metrics.WithConfig(func(c *metrics.Config) { c.MaxSeriesPerMetric = 1000 })
+func WithConfig(configure func(*Config)) Option {
+	return Option(configure)
+}
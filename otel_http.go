@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// HTTPMetricSchema selects the naming schema of HTTP metrics
+type HTTPMetricSchema int
+
+const (
+	// HTTPSchemaLegacy emits http_requests_total, http_request_duration_seconds, ... (default)
+	HTTPSchemaLegacy HTTPMetricSchema = iota
+	// HTTPSchemaOTel emits OpenTelemetry semantic-convention names only
+	HTTPSchemaOTel
+	// HTTPSchemaBoth emits both schemas, easing dashboard migration
+	HTTPSchemaBoth
+)
+
+// OTelHTTPMetrics contains HTTP metrics named after the OpenTelemetry
+// semantic conventions, translated to Prometheus naming (e.g.
+// http.server.request.duration becomes http_server_request_duration_seconds).
+// They are not prefixed with Namespace or Subsystem so standard OTel
+// dashboards work unchanged.
+type OTelHTTPMetrics struct {
+	RequestDuration  *prometheus.HistogramVec
+	ActiveRequests   *prometheus.GaugeVec
+	RequestBodySize  *prometheus.HistogramVec
+	ResponseBodySize *prometheus.HistogramVec
+}
+
+// Standard OpenTelemetry HTTP attribute names in Prometheus form
+var otelRequestLabels = []string{"http_request_method", "http_route", "http_response_status_code", "url_scheme"}
+
+// newOTelHTTPMetrics creates the OpenTelemetry HTTP metrics with the given
+// constant labels
+func newOTelHTTPMetrics(config *Config, constLabels prometheus.Labels) *OTelHTTPMetrics {
+	return &OTelHTTPMetrics{
+		RequestDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "http_server_request_duration_seconds",
+				Help:        "Duration of HTTP server requests",
+				Buckets:     config.HTTPBuckets,
+				ConstLabels: constLabels,
+			},
+			otelRequestLabels,
+		),
+		ActiveRequests: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name:        "http_server_active_requests",
+				Help:        "Number of active HTTP server requests",
+				ConstLabels: constLabels,
+			},
+			[]string{"http_request_method", "url_scheme"},
+		),
+		RequestBodySize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "http_server_request_body_size_bytes",
+				Help:        "Size of HTTP server request bodies",
+				Buckets:     prometheus.ExponentialBuckets(100, 10, 7),
+				ConstLabels: constLabels,
+			},
+			otelRequestLabels,
+		),
+		ResponseBodySize: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:        "http_server_response_body_size_bytes",
+				Help:        "Size of HTTP server response bodies",
+				Buckets:     prometheus.ExponentialBuckets(100, 10, 7),
+				ConstLabels: constLabels,
+			},
+			otelRequestLabels,
+		),
+	}
+}
+
+// collectors returns the metrics for registration
+func (o *OTelHTTPMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{
+		o.RequestDuration,
+		o.ActiveRequests,
+		o.RequestBodySize,
+		o.ResponseBodySize,
+	}
+}
+
+// scheme returns the url_scheme attribute of a request
+func scheme(c *gin.Context) string {
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// record records a completed request
+func (o *OTelHTTPMetrics) record(c *gin.Context, route string, duration float64, requestSize, responseSize int64, sampled bool) {
+	if !sampled {
+		return
+	}
+
+	labels := []string{
+		c.Request.Method,
+		route,
+		strconv.Itoa(c.Writer.Status()),
+		scheme(c),
+	}
+
+	observe(o.RequestDuration.WithLabelValues(labels...), duration, requestExemplar(c.Request))
+
+	if requestSize > 0 {
+		o.RequestBodySize.WithLabelValues(labels...).Observe(float64(requestSize))
+	}
+	if responseSize >= 0 {
+		o.ResponseBodySize.WithLabelValues(labels...).Observe(float64(responseSize))
+	}
+}
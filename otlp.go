@@ -0,0 +1,310 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+	collectorpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricspb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// OTLP protocols supported by StartOTLPPush
+const (
+	OTLPProtocolHTTP = "http/protobuf"
+	OTLPProtocolGRPC = "grpc"
+)
+
+// otlpExporter sends OTLP export requests over HTTP or gRPC
+type otlpExporter struct {
+	m      *Metrics
+	start  time.Time
+	clock  *pushClock
+	client *http.Client
+	url    string
+	grpc   collectorpb.MetricsServiceClient
+	conn   *grpc.ClientConn
+}
+
+// StartOTLPPush starts pushing metrics to an OpenTelemetry collector using
+// OTLPEndpoint, OTLPHeaders and OTLPProtocol from the config, until ctx is
+// cancelled or Shutdown is called. For http/protobuf the endpoint is a URL
+// (the /v1/metrics path is added if missing); for grpc it is host:port.
+// If the push was already started by NewMetrics, only the configuration is
+// checked.
+func (m *Metrics) StartOTLPPush(ctx context.Context) error {
+	if m.config.OTLPEndpoint == "" {
+		return fmt.Errorf("OTLPEndpoint is not configured")
+	}
+
+	exporter, err := m.newOTLPExporter()
+	if err != nil {
+		return err
+	}
+	if m.pushing("otlp") {
+		exporter.close()
+		return nil
+	}
+
+	push := func(ctx context.Context) error { return m.redactError(exporter.push(ctx)) }
+	m.startPusher(ctx, "otlp", push, exporter.close)
+	return nil
+}
+
+// newOTLPExporter creates an exporter for the configured protocol
+func (m *Metrics) newOTLPExporter() (*otlpExporter, error) {
+	e := &otlpExporter{m: m, start: time.Now(), clock: newPushClock("otlp")}
+
+	switch m.config.OTLPProtocol {
+	case "", OTLPProtocolHTTP:
+		u, err := url.Parse(m.config.OTLPEndpoint)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("invalid OTLP endpoint URL %q", m.config.OTLPEndpoint)
+		}
+		if u.Path == "" || u.Path == "/" {
+			u.Path = "/v1/metrics"
+		}
+		e.url = u.String()
+		e.client = &http.Client{Timeout: 10 * time.Second}
+
+	case OTLPProtocolGRPC:
+		creds := credentials.NewClientTLSFromCert(nil, "")
+		if m.config.OTLPInsecure {
+			creds = insecure.NewCredentials()
+		}
+		conn, err := grpc.NewClient(m.config.OTLPEndpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create OTLP gRPC client: %w", err)
+		}
+		e.conn = conn
+		e.grpc = collectorpb.NewMetricsServiceClient(conn)
+
+	default:
+		return nil, fmt.Errorf("unsupported OTLP protocol %q", m.config.OTLPProtocol)
+	}
+
+	return e, nil
+}
+
+// close releases the gRPC connection if any
+func (e *otlpExporter) close() {
+	if e.conn != nil {
+		e.conn.Close()
+	}
+}
+
+// push gathers the registry and sends it as one export request
+func (e *otlpExporter) push(ctx context.Context) error {
+	if err := e.m.chaos.pushError(); err != nil {
+		return err
+	}
+
+	metricFamilies, err := e.m.gatherer().Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	request := e.m.toOTLP(metricFamilies, e.start, e.clock.now(e.m))
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	if e.grpc != nil {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(e.m.config.OTLPHeaders))
+		if _, err := e.grpc.Export(ctx, request); err != nil {
+			return fmt.Errorf("failed to export metrics: %w", err)
+		}
+		return nil
+	}
+
+	data, err := proto.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to marshal protobuf: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "go-metrics/1.0")
+	for k, v := range e.m.config.OTLPHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("push failed with status %d: %s", resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// toOTLP converts gathered metric families into an OTLP export request.
+// Gauge histograms are left out: OTLP has no equivalent type, and an OTLP
+// histogram would be read as counts accumulated since start.
+func (m *Metrics) toOTLP(families []*dto.MetricFamily, start, now time.Time) *collectorpb.ExportMetricsServiceRequest {
+	startNano := uint64(start.UnixNano())
+	nowNano := uint64(now.UnixNano())
+
+	var otlpMetrics []*metricspb.Metric
+	for _, mf := range families {
+		metric := &metricspb.Metric{
+			Name:        mf.GetName(),
+			Description: mf.GetHelp(),
+		}
+
+		switch mf.GetType() {
+		case dto.MetricType_COUNTER:
+			points := make([]*metricspb.NumberDataPoint, 0, len(mf.GetMetric()))
+			for _, pm := range mf.GetMetric() {
+				points = append(points, numberPoint(pm, pm.GetCounter().GetValue(), startNano, nowNano))
+			}
+			metric.Data = &metricspb.Metric_Sum{Sum: &metricspb.Sum{
+				DataPoints:             points,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+				IsMonotonic:            true,
+			}}
+
+		case dto.MetricType_GAUGE, dto.MetricType_UNTYPED:
+			points := make([]*metricspb.NumberDataPoint, 0, len(mf.GetMetric()))
+			for _, pm := range mf.GetMetric() {
+				value := pm.GetGauge().GetValue()
+				if mf.GetType() == dto.MetricType_UNTYPED {
+					value = pm.GetUntyped().GetValue()
+				}
+				points = append(points, numberPoint(pm, value, startNano, nowNano))
+			}
+			metric.Data = &metricspb.Metric_Gauge{Gauge: &metricspb.Gauge{DataPoints: points}}
+
+		case dto.MetricType_HISTOGRAM:
+			points := make([]*metricspb.HistogramDataPoint, 0, len(mf.GetMetric()))
+			for _, pm := range mf.GetMetric() {
+				points = append(points, histogramPoint(pm, startNano, nowNano))
+			}
+			metric.Data = &metricspb.Metric_Histogram{Histogram: &metricspb.Histogram{
+				DataPoints:             points,
+				AggregationTemporality: metricspb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE,
+			}}
+
+		case dto.MetricType_SUMMARY:
+			points := make([]*metricspb.SummaryDataPoint, 0, len(mf.GetMetric()))
+			for _, pm := range mf.GetMetric() {
+				points = append(points, summaryPoint(pm, startNano, nowNano))
+			}
+			metric.Data = &metricspb.Metric_Summary{Summary: &metricspb.Summary{DataPoints: points}}
+
+		default:
+			// Gauge histograms, see above
+			continue
+		}
+
+		otlpMetrics = append(otlpMetrics, metric)
+	}
+
+	return &collectorpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricspb.ResourceMetrics{{
+			Resource: &resourcepb.Resource{
+				Attributes: []*commonpb.KeyValue{stringAttribute("service.name", m.config.ServiceName)},
+			},
+			ScopeMetrics: []*metricspb.ScopeMetrics{{
+				Scope:   &commonpb.InstrumentationScope{Name: "github.com/OkanUysal/go-metrics"},
+				Metrics: otlpMetrics,
+			}},
+		}},
+	}
+}
+
+// attributes converts Prometheus labels into OTLP attributes
+func attributes(pm *dto.Metric) []*commonpb.KeyValue {
+	attrs := make([]*commonpb.KeyValue, 0, len(pm.GetLabel()))
+	for _, label := range pm.GetLabel() {
+		attrs = append(attrs, stringAttribute(label.GetName(), label.GetValue()))
+	}
+	return attrs
+}
+
+// stringAttribute creates a string-valued OTLP attribute
+func stringAttribute(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{
+		Key:   key,
+		Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}},
+	}
+}
+
+// numberPoint creates a number data point
+func numberPoint(pm *dto.Metric, value float64, start, now uint64) *metricspb.NumberDataPoint {
+	return &metricspb.NumberDataPoint{
+		Attributes:        attributes(pm),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      now,
+		Value:             &metricspb.NumberDataPoint_AsDouble{AsDouble: value},
+	}
+}
+
+// histogramPoint converts cumulative Prometheus buckets into OTLP
+// per-bucket counts
+func histogramPoint(pm *dto.Metric, start, now uint64) *metricspb.HistogramDataPoint {
+	h := pm.GetHistogram()
+	sum := h.GetSampleSum()
+
+	bounds := make([]float64, 0, len(h.GetBucket()))
+	counts := make([]uint64, 0, len(h.GetBucket())+1)
+	var previous uint64
+	for _, b := range h.GetBucket() {
+		bounds = append(bounds, b.GetUpperBound())
+		counts = append(counts, b.GetCumulativeCount()-previous)
+		previous = b.GetCumulativeCount()
+	}
+	// +Inf bucket
+	counts = append(counts, h.GetSampleCount()-previous)
+
+	return &metricspb.HistogramDataPoint{
+		Attributes:        attributes(pm),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      now,
+		Count:             h.GetSampleCount(),
+		Sum:               &sum,
+		BucketCounts:      counts,
+		ExplicitBounds:    bounds,
+	}
+}
+
+// summaryPoint creates a summary data point
+func summaryPoint(pm *dto.Metric, start, now uint64) *metricspb.SummaryDataPoint {
+	s := pm.GetSummary()
+
+	quantiles := make([]*metricspb.SummaryDataPoint_ValueAtQuantile, 0, len(s.GetQuantile()))
+	for _, q := range s.GetQuantile() {
+		quantiles = append(quantiles, &metricspb.SummaryDataPoint_ValueAtQuantile{
+			Quantile: q.GetQuantile(),
+			Value:    q.GetValue(),
+		})
+	}
+
+	return &metricspb.SummaryDataPoint{
+		Attributes:        attributes(pm),
+		StartTimeUnixNano: start,
+		TimeUnixNano:      now,
+		Count:             s.GetSampleCount(),
+		Sum:               s.GetSampleSum(),
+		QuantileValues:    quantiles,
+	}
+}
@@ -0,0 +1,183 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// RemoteWriteTarget is a Prometheus remote write endpoint, e.g. a Grafana
+// Cloud tenant or a Mimir cluster
+type RemoteWriteTarget struct {
+	Name   string // Label of the target in push metrics, defaults to the URL host
+	URL    string
+	User   string // Basic auth, if set
+	APIKey string
+
+	// Files the credentials are read from instead, re-read when they change
+	UserFile   string
+	APIKeyFile string
+
+	// Headers added to every push, e.g. X-Scope-OrgID for Mimir tenants
+	Headers map[string]string
+
+	// RelabelRules filter and rewrite the pushed series, e.g. to keep
+	// high-cardinality internal metrics out of an expensive tenant
+	RelabelRules []RelabelRule
+
+	// Relabel is called after RelabelRules with the labels of every pushed series, including
+	// __name__, and may change them in place. Series are dropped if it
+	// returns false.
+	Relabel func(labels map[string]string) bool
+
+	// Team of a TeamRemoteWrite target, set when metrics are routed to it
+	team string
+}
+
+// owners maps fully-qualified metric names to their owning team. It is
+// shared by a Metrics and its scopes.
+type owners struct {
+	mu     sync.RWMutex
+	byName map[string]string
+}
+
+// newOwners creates an empty ownership map
+func newOwners() *owners {
+	return &owners{byName: make(map[string]string)}
+}
+
+// WithTeam returns a child scope whose custom metrics are owned by team.
+// Ownership routes metrics to the TeamRemoteWrite target of the team and
+// selects them in TeamGatherer, e.g. business metrics to one tenant and
+// infrastructure metrics to another:
+//
+//	billing := m.WithTeam("billing")
+//	billing.IncrementCounter("invoices_total", nil)
+func (m *Metrics) WithTeam(team string) *Metrics {
+	config := *m.config
+	child := m.scope(&config)
+	child.team = team
+	return child
+}
+
+// SetOwner assigns metrics that already exist, such as the HTTP metrics, to
+// team. Names are given without namespace and subsystem of m.
+func (m *Metrics) SetOwner(team string, names ...string) {
+	m.owners.mu.Lock()
+	defer m.owners.mu.Unlock()
+
+	for _, name := range names {
+		m.owners.byName[m.QualifiedName(name)] = team
+	}
+}
+
+// Owner returns the team owning a metric, or "" if it has none
+func (m *Metrics) Owner(name string) string {
+	return m.owners.get(m.QualifiedName(name))
+}
+
+// TeamGatherer returns a gatherer of the metrics owned by team, or of the
+// metrics without owner if team is "", e.g. to serve each team its own
+// scrape endpoint
+func (m *Metrics) TeamGatherer(team string) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := m.gatherer().Gather()
+		owned := families[:0]
+		for _, mf := range families {
+			if m.owners.get(mf.GetName()) == team {
+				owned = append(owned, mf)
+			}
+		}
+		return owned, err
+	})
+}
+
+// TeamHandler returns a Prometheus HTTP handler serving the metrics owned by
+// team, see TeamGatherer
+func (m *Metrics) TeamHandler(team string) http.Handler {
+	return promhttp.HandlerFor(m.TeamGatherer(team), promhttp.HandlerOpts{
+		EnableOpenMetrics: true,
+	})
+}
+
+// own records the team of m as owner of a newly created metric
+func (m *Metrics) own(name string) {
+	if m.team == "" {
+		return
+	}
+
+	m.owners.mu.Lock()
+	defer m.owners.mu.Unlock()
+
+	m.owners.byName[m.QualifiedName(name)] = m.team
+}
+
+// get returns the owner of a fully-qualified metric name
+func (o *owners) get(fqName string) string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.byName[fqName]
+}
+
+// remoteWriteBatch is the metric families pushed to one target
+type remoteWriteBatch struct {
+	target   RemoteWriteTarget
+	families []*dto.MetricFamily
+}
+
+// routeFamilies groups gathered metrics by remote write target: families
+// owned by a team with a TeamRemoteWrite target go there, all others to
+// Grafana Cloud. Every RemoteWrite target receives all families.
+func (m *Metrics) routeFamilies(families []*dto.MetricFamily) []remoteWriteBatch {
+	var batches []remoteWriteBatch
+	grafana, ok := m.grafanaTarget()
+	if len(m.config.TeamRemoteWrite) == 0 {
+		if ok {
+			batches = append(batches, remoteWriteBatch{target: grafana, families: families})
+		}
+	} else {
+		// One batch per team, so teams sharing a URL, e.g. Mimir tenants
+		// told apart by X-Scope-OrgID, keep their headers and credentials
+		byTeam := make(map[string]int) // "" for Grafana Cloud
+		for _, mf := range families {
+			team := m.owners.get(mf.GetName())
+			target, owned := m.config.TeamRemoteWrite[team]
+			if owned {
+				target.team = team
+			} else if ok {
+				target, team = grafana, ""
+			} else {
+				continue
+			}
+			i, exists := byTeam[team]
+			if !exists {
+				i = len(batches)
+				byTeam[team] = i
+				batches = append(batches, remoteWriteBatch{target: target})
+			}
+			batches[i].families = append(batches[i].families, mf)
+		}
+	}
+
+	for _, target := range m.config.RemoteWrite {
+		batches = append(batches, remoteWriteBatch{target: target, families: families})
+	}
+	return batches
+}
+
+// grafanaTarget returns the Grafana Cloud target, if configured
+func (m *Metrics) grafanaTarget() (RemoteWriteTarget, bool) {
+	return RemoteWriteTarget{
+		Name:         "grafana",
+		URL:          m.config.GrafanaCloudURL,
+		User:         m.config.GrafanaCloudUser,
+		APIKey:       m.config.GrafanaCloudAPIKey,
+		UserFile:     m.config.GrafanaCloudUserFile,
+		APIKeyFile:   m.config.GrafanaCloudAPIKeyFile,
+		RelabelRules: m.config.GrafanaCloudRelabel,
+	}, m.config.GrafanaCloudURL != ""
+}
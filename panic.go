@@ -0,0 +1,47 @@
+package metrics
+
+import "runtime/debug"
+
+// InstrumentPanics declares panics_total{component}, counting the panics
+// caught by Go and RecoverPanic, and creates the series of components at
+// zero so alerts on increase(panics_total[5m]) work before the first panic.
+//
+// Caught panics are re-raised after counting unless Config.RecoverPanics is
+// set, in which case they are logged with their stack trace instead.
+func (m *Metrics) InstrumentPanics(components ...string) error {
+	if err := m.RegisterCounter("panics_total", "Panics caught by component", []string{"component"}); err != nil {
+		return err
+	}
+	for _, component := range components {
+		m.IncrementCounterBy("panics_total", 0, MetricLabels{"component": component})
+	}
+	return nil
+}
+
+// Go runs fn in a new goroutine, counting a panic in fn under component.
+// Without it a panic in a goroutine crashes the process with no metric left
+// to tell which component failed.
+func (m *Metrics) Go(component string, fn func()) {
+	go func() {
+		defer m.RecoverPanic(component)
+		fn()
+	}()
+}
+
+// RecoverPanic counts a panic under component and re-raises or logs it, see
+// InstrumentPanics. It must be deferred directly:
+//
+//	defer m.RecoverPanic("ws_reader")
+func (m *Metrics) RecoverPanic(component string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	m.IncrementCounter("panics_total", MetricLabels{"component": component})
+
+	if !m.config.RecoverPanics {
+		panic(r)
+	}
+	m.logf("Recovered panic in %s: %v\n%s", component, r, debug.Stack())
+}
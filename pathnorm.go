@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"regexp"
+	"strings"
+)
+
+// PathRule rewrites request paths matching Pattern to Replacement, which may
+// refer to submatches as in regexp.Regexp.ReplaceAllString
+type PathRule struct {
+	Pattern     *regexp.Regexp
+	Replacement string
+}
+
+// NormalizePaths returns a PathNormalizer applying the first matching rule.
+// Paths matching no rule are labelled MiddlewareOptions.UnmatchedPath, so
+// unknown paths collapse into a single series.
+func NormalizePaths(rules ...PathRule) func(string) string {
+	return func(path string) string {
+		for _, rule := range rules {
+			if rule.Pattern.MatchString(path) {
+				return rule.Pattern.ReplaceAllString(path, rule.Replacement)
+			}
+		}
+		return ""
+	}
+}
+
+// idSegment matches path segments that look like identifiers: numbers,
+// UUIDs and hex strings of 16 characters or more
+var idSegment = regexp.MustCompile(`^(?:[0-9]+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// NormalizeIDs is a PathNormalizer replacing identifier segments with :id,
+// e.g. /users/12345/orders becomes /users/:id/orders
+func NormalizeIDs(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegment.MatchString(segment) {
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// precreatedStatuses represent the 2xx, 4xx and 5xx classes in pre-created
+// request series
+var precreatedStatuses = []int{http.StatusOK, http.StatusBadRequest, http.StatusInternalServerError}
+
+// PrecreateHTTPSeries creates the http_requests_total series of every route
+// of router with a 200, 400 and 500 status at zero, so alerts on the absence
+// of e.g. 5xx series do not misfire before the first error after a deploy.
+// Call it after all routes are registered. With HTTPPrecreateSeries set it
+// is called on the first request for the router passed to Setup.
+func (m *Metrics) PrecreateHTTPSeries(router *gin.Engine) {
+	m.precreateHTTPSeries(router.Routes(), MiddlewareOptions{}, newHTTPFilter(m.config.HTTPMetricsFilter))
+}
+
+// precreateHTTPSeries creates the request series of routes, labelled the way
+// the middleware configured by opts labels them. Series the filter excludes
+// are not created.
+func (m *Metrics) precreateHTTPSeries(routes gin.RoutesInfo, opts MiddlewareOptions, filter *httpFilter) {
+	// Raw paths are unknown until requested
+	if m.httpMetrics == nil || opts.PathLabel == PathRaw {
+		return
+	}
+
+	for _, route := range routes {
+		if filter.skip(route.Method, route.Path) {
+			continue
+		}
+		for _, code := range precreatedStatuses {
+			if filter.skipStatus(code) {
+				continue
+			}
+			m.httpMetrics.RequestsTotal.WithLabelValues(m.requestLabelValues(route.Method, route.Path, opts.status(code), code, "")...)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"os"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Profile bundles defaults appropriate for a deployment environment
+type Profile struct {
+	ConstLabels    prometheus.Labels
+	HTTPBuckets    []float64
+	PushInterval   time.Duration
+	HTTPSampleRate float64
+	DevMode        bool
+}
+
+// Profiles are the built-in environment profiles selectable via
+// Config.Environment or the METRICS_ENV environment variable
+var Profiles = map[string]Profile{
+	"dev": {
+		ConstLabels:    prometheus.Labels{"environment": "dev"},
+		HTTPBuckets:    []float64{.01, .1, .5, 1, 5},
+		PushInterval:   60 * time.Second,
+		HTTPSampleRate: 1,
+		DevMode:        true,
+	},
+	"staging": {
+		ConstLabels:    prometheus.Labels{"environment": "staging"},
+		HTTPBuckets:    []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+		PushInterval:   30 * time.Second,
+		HTTPSampleRate: 1,
+	},
+	"prod": {
+		ConstLabels:    prometheus.Labels{"environment": "prod"},
+		HTTPBuckets:    []float64{.001, .0025, .005, .01, .025, .05, .075, .1, .25, .5, .75, 1, 2.5, 5, 10},
+		PushInterval:   15 * time.Second,
+		HTTPSampleRate: 1,
+	},
+}
+
+// applyProfile fills unset config fields from the selected environment profile.
+// Explicitly configured values always take precedence.
+func applyProfile(config *Config) {
+	if config.Environment == "" {
+		config.Environment = os.Getenv("METRICS_ENV")
+	}
+	profile, ok := Profiles[config.Environment]
+	if !ok {
+		return
+	}
+
+	labels := make(prometheus.Labels, len(config.ConstLabels)+len(profile.ConstLabels))
+	for k, v := range profile.ConstLabels {
+		labels[k] = v
+	}
+	for k, v := range config.ConstLabels {
+		labels[k] = v
+	}
+	config.ConstLabels = labels
+
+	if config.HTTPBuckets == nil {
+		config.HTTPBuckets = profile.HTTPBuckets
+	}
+	if config.PushInterval == 0 {
+		config.PushInterval = profile.PushInterval
+	}
+	if config.HTTPSampleRate == 0 {
+		config.HTTPSampleRate = profile.HTTPSampleRate
+	}
+	if profile.DevMode {
+		config.DevMode = true
+	}
+}
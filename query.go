@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	v1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// QuerySample is one result of a remote instant query
+type QuerySample struct {
+	Labels    map[string]string
+	Value     float64
+	Timestamp time.Time
+}
+
+// QueryRemote runs an instant PromQL query against PrometheusURL, e.g. to
+// read back cluster-wide aggregates such as sum(myapp_active_users) for
+// admission control. Vector results return one sample per series, scalar
+// results a single sample without labels.
+func (m *Metrics) QueryRemote(ctx context.Context, promQL string) ([]QuerySample, error) {
+	if m.config.PrometheusURL == "" {
+		return nil, fmt.Errorf("PrometheusURL is not configured")
+	}
+
+	var rt http.RoundTripper = api.DefaultRoundTripper
+	if m.config.PrometheusUser != "" || m.config.PrometheusPassword != "" {
+		rt = basicAuthRoundTripper{user: m.config.PrometheusUser, password: m.config.PrometheusPassword, next: rt}
+	}
+
+	client, err := api.NewClient(api.Config{Address: m.config.PrometheusURL, RoundTripper: rt})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Prometheus client: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	value, warnings, err := v1.NewAPI(client).Query(ctx, promQL, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	for _, w := range warnings {
+		m.logf("Prometheus query warning: %s", w)
+	}
+
+	switch v := value.(type) {
+	case model.Vector:
+		samples := make([]QuerySample, 0, len(v))
+		for _, s := range v {
+			labels := make(map[string]string, len(s.Metric))
+			for k, lv := range s.Metric {
+				labels[string(k)] = string(lv)
+			}
+			samples = append(samples, QuerySample{
+				Labels:    labels,
+				Value:     float64(s.Value),
+				Timestamp: s.Timestamp.Time(),
+			})
+		}
+		return samples, nil
+	case *model.Scalar:
+		return []QuerySample{{Value: float64(v.Value), Timestamp: v.Timestamp.Time()}}, nil
+	default:
+		return nil, fmt.Errorf("unsupported query result type %s", value.Type())
+	}
+}
+
+// basicAuthRoundTripper adds basic auth to every request
+type basicAuthRoundTripper struct {
+	user, password string
+	next           http.RoundTripper
+}
+
+func (rt basicAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(rt.user, rt.password)
+	return rt.next.RoundTrip(req)
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// ratioCollector computes a gauge from custom counters on every scrape
+type ratioCollector struct {
+	m            *Metrics
+	desc         *prometheus.Desc
+	labelKeys    []string
+	numerator    string
+	denominators []string
+}
+
+// RegisterRatio registers the gauge name, computed at scrape time as the
+// numerator counter divided by the sum of the denominator counters for each
+// combination of labelKeys, e.g. a cache hit ratio:
+//
+//	m.RegisterRatio("cache_hit_ratio", "Cache hit ratio", []string{"type"},
+//		"cache_hits_total", "cache_hits_total", "cache_misses_total")
+//
+// The counters must use labelKeys. Series without denominator counts are
+// omitted. The ratio covers the lifetime of the process; use rate() in
+// queries for a ratio over a time window.
+func (m *Metrics) RegisterRatio(name, help string, labelKeys []string, numerator string, denominators ...string) error {
+	if len(denominators) == 0 {
+		return fmt.Errorf("metric %q: at least one denominator is required", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRegistration(name, help, labelKeys); err != nil {
+		return err
+	}
+
+	fqName := prometheus.BuildFQName(m.config.Namespace, m.config.Subsystem, name)
+	collector := &ratioCollector{
+		m:            m,
+		desc:         prometheus.NewDesc(fqName, help, labelKeys, m.config.ConstLabels),
+		labelKeys:    labelKeys,
+		numerator:    numerator,
+		denominators: denominators,
+	}
+	if err := m.registry.Register(collector); err != nil {
+		return fmt.Errorf("failed to register ratio %q: %w", name, err)
+	}
+	m.schemas[name] = slices.Sorted(slices.Values(labelKeys))
+	m.own(name)
+
+	return nil
+}
+
+func (c *ratioCollector) Describe(ch chan<- *prometheus.Desc) { ch <- c.desc }
+
+func (c *ratioCollector) Collect(ch chan<- prometheus.Metric) {
+	numerators := c.values(c.numerator)
+
+	denominators := make(map[string]float64)
+	labelValues := make(map[string][]string)
+	for _, name := range c.denominators {
+		for key, v := range c.values(name) {
+			denominators[key] += v.value
+			labelValues[key] = v.labelValues
+		}
+	}
+
+	for key, denominator := range denominators {
+		if denominator == 0 {
+			continue
+		}
+		ratio := numerators[key].value / denominator
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, ratio, labelValues[key]...)
+	}
+}
+
+// labeledValue is the value of one counter series
+type labeledValue struct {
+	labelValues []string
+	value       float64
+}
+
+// values returns the series of a custom counter keyed by their label values
+// in labelKeys order. Series with other label keys are skipped.
+func (c *ratioCollector) values(name string) map[string]labeledValue {
+	c.m.mu.RLock()
+	counter, exists := c.m.counters[name]
+	c.m.mu.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	metrics := make(chan prometheus.Metric)
+	go func() {
+		counter.Collect(metrics)
+		close(metrics)
+	}()
+
+	values := make(map[string]labeledValue)
+	for metric := range metrics {
+		var pm dto.Metric
+		if err := metric.Write(&pm); err != nil {
+			continue
+		}
+
+		labels := labelMap(&pm)
+		labelValues := make([]string, len(c.labelKeys))
+		for i, key := range c.labelKeys {
+			value, ok := labels[key]
+			if !ok {
+				labelValues = nil
+				break
+			}
+			labelValues[i] = value
+		}
+		if labelValues == nil || len(labels) != len(c.labelKeys)+len(c.m.config.ConstLabels) {
+			continue
+		}
+
+		values[strings.Join(labelValues, "\xff")] = labeledValue{labelValues, pm.GetCounter().GetValue()}
+	}
+	return values
+}
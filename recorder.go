@@ -0,0 +1,49 @@
+package metrics
+
+import "time"
+
+// Recorder records custom metrics. It is implemented by *Metrics, the
+// ContextMetrics of FromContext and Noop, so libraries can accept a Recorder
+// and tests can pass a mock:
+//
+//	type OrderService struct {
+//	    metrics metrics.Recorder
+//	}
+//
+//	svc := &OrderService{metrics: m} // Or metrics.Noop()
+type Recorder interface {
+	IncrementCounter(name string, labels MetricLabels)
+	IncrementCounterBy(name string, value float64, labels MetricLabels)
+	SetGauge(name string, value float64, labels MetricLabels)
+	IncrementGauge(name string, labels MetricLabels)
+	DecrementGauge(name string, labels MetricLabels)
+	RecordHistogram(name string, value float64, labels MetricLabels)
+	RecordHistogramWeighted(name string, value float64, weight uint64, labels MetricLabels)
+	RecordHistogramBatch(name string, values []float64, labels MetricLabels)
+	RecordDuration(name string, d time.Duration, labels MetricLabels)
+}
+
+var (
+	_ Recorder = (*Metrics)(nil)
+	_ Recorder = (*ContextMetrics)(nil)
+	_ Recorder = noop{}
+)
+
+// Noop returns a Recorder that records nothing, to disable metrics entirely
+// without nil checks
+func Noop() Recorder {
+	return noop{}
+}
+
+// noop is the Recorder returned by Noop
+type noop struct{}
+
+func (noop) IncrementCounter(string, MetricLabels)                         {}
+func (noop) IncrementCounterBy(string, float64, MetricLabels)              {}
+func (noop) SetGauge(string, float64, MetricLabels)                        {}
+func (noop) IncrementGauge(string, MetricLabels)                           {}
+func (noop) DecrementGauge(string, MetricLabels)                           {}
+func (noop) RecordHistogram(string, float64, MetricLabels)                 {}
+func (noop) RecordHistogramWeighted(string, float64, uint64, MetricLabels) {}
+func (noop) RecordHistogramBatch(string, []float64, MetricLabels)          {}
+func (noop) RecordDuration(string, time.Duration, MetricLabels)            {}
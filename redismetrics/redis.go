@@ -0,0 +1,218 @@
+// Package redismetrics wires metrics.RedisMetrics into go-redis clients
+// through a client hook.
+//
+//	rm := m.NewRedisMetrics()
+//	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+//	client.AddHook(redismetrics.NewHook(rm))
+//	redismetrics.CollectPoolStats(ctx, rm, client, "cache", 15*time.Second)
+//	redismetrics.CollectKeys(ctx, rm, client, redismetrics.Keys{Lengths: []string{"queue:emails"}}, 15*time.Second)
+package redismetrics
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/OkanUysal/go-metrics"
+	"github.com/redis/go-redis/v9"
+)
+
+// Hook records command latencies, command errors and pipeline sizes
+type Hook struct {
+	rm *metrics.RedisMetrics
+}
+
+// NewHook creates a go-redis hook
+func NewHook(rm *metrics.RedisMetrics) *Hook {
+	return &Hook{rm: rm}
+}
+
+// DialHook implements redis.Hook
+func (h *Hook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook implements redis.Hook
+func (h *Hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmd)
+		elapsed := time.Since(start)
+
+		h.rm.CommandExecuted(cmd.Name(), elapsed.Seconds())
+		metrics.AddRequestTime(ctx, metrics.ComponentCache, elapsed)
+		if failed(err) {
+			h.rm.CommandFailed(cmd.Name())
+		}
+		return err
+	}
+}
+
+// ProcessPipelineHook implements redis.Hook
+func (h *Hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+		err := next(ctx, cmds)
+		elapsed := time.Since(start)
+
+		h.rm.PipelineExecuted(len(cmds), elapsed.Seconds())
+		metrics.AddRequestTime(ctx, metrics.ComponentCache, elapsed)
+		for _, cmd := range cmds {
+			if failed(cmd.Err()) {
+				h.rm.CommandFailed(cmd.Name())
+			}
+		}
+		return err
+	}
+}
+
+// PoolStatser is implemented by redis.Client, redis.ClusterClient and redis.Ring
+type PoolStatser interface {
+	PoolStats() *redis.PoolStats
+}
+
+// CollectPoolStats periodically exports the connection pool statistics of
+// client until ctx is cancelled
+func CollectPoolStats(ctx context.Context, rm *metrics.RedisMetrics, client PoolStatser, pool string, interval time.Duration) {
+	rm.CollectPoolStats(ctx, pool, func() metrics.RedisPoolStats {
+		stats := client.PoolStats()
+		return metrics.RedisPoolStats{
+			Hits:       stats.Hits,
+			Misses:     stats.Misses,
+			Timeouts:   stats.Timeouts,
+			TotalConns: stats.TotalConns,
+			IdleConns:  stats.IdleConns,
+			StaleConns: stats.StaleConns,
+		}
+	}, interval)
+}
+
+// Keys selects the keys and INFO fields read by CollectKeys
+type Keys struct {
+	Lengths []string      // Keys whose length is exported, e.g. queue lists
+	Streams []StreamGroup // Consumer groups whose pending entries are exported
+	Info    []string      // INFO fields, e.g. "used_memory" or "connected_clients"
+}
+
+// StreamGroup is a consumer group of a stream
+type StreamGroup struct {
+	Stream string
+	Group  string
+}
+
+// CollectKeys periodically exports the lengths of app-owned keys, pending
+// entries of stream consumer groups and INFO fields until ctx is cancelled,
+// without running a separate redis_exporter. Lengths are read with the
+// command matching the type of each key; missing keys have length 0.
+func CollectKeys(ctx context.Context, rm *metrics.RedisMetrics, client redis.UniversalClient, keys Keys, interval time.Duration) {
+	rm.CollectKeyStats(ctx, func(ctx context.Context) (metrics.RedisKeyStats, error) {
+		return readKeys(ctx, client, keys)
+	}, interval)
+}
+
+// readKeys reads the selected keys, returning what could be read along with
+// the first error
+func readKeys(ctx context.Context, client redis.UniversalClient, keys Keys) (metrics.RedisKeyStats, error) {
+	var firstErr error
+	record := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	stats := metrics.RedisKeyStats{Lengths: make(map[string]int64, len(keys.Lengths))}
+	for _, key := range keys.Lengths {
+		length, err := keyLength(ctx, client, key)
+		if err != nil {
+			record(err)
+			continue
+		}
+		stats.Lengths[key] = length
+	}
+
+	for _, sg := range keys.Streams {
+		pending, err := client.XPending(ctx, sg.Stream, sg.Group).Result()
+		if err != nil {
+			record(err)
+			continue
+		}
+		stats.Pending = append(stats.Pending, metrics.RedisStreamPending{
+			Stream:  sg.Stream,
+			Group:   sg.Group,
+			Pending: pending.Count,
+		})
+	}
+
+	if len(keys.Info) > 0 {
+		info, err := client.Info(ctx).Result()
+		if err != nil {
+			record(err)
+		} else {
+			stats.Info = parseInfo(info, keys.Info)
+		}
+	}
+
+	return stats, firstErr
+}
+
+// keyLength returns the length of a key using the command for its type
+func keyLength(ctx context.Context, client redis.UniversalClient, key string) (int64, error) {
+	typ, err := client.Type(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	switch typ {
+	case "none":
+		return 0, nil
+	case "list":
+		return client.LLen(ctx, key).Result()
+	case "set":
+		return client.SCard(ctx, key).Result()
+	case "zset":
+		return client.ZCard(ctx, key).Result()
+	case "hash":
+		return client.HLen(ctx, key).Result()
+	case "stream":
+		return client.XLen(ctx, key).Result()
+	case "string":
+		return client.StrLen(ctx, key).Result()
+	default:
+		return 0, fmt.Errorf("key %q has unsupported type %s", key, typ)
+	}
+}
+
+// parseInfo returns the numeric values of the selected fields of an INFO
+// reply, which consists of "field:value" lines
+func parseInfo(info string, fields []string) map[string]float64 {
+	selected := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		selected[field] = true
+	}
+
+	values := make(map[string]float64, len(fields))
+	for _, line := range strings.Split(info, "\n") {
+		field, value, ok := strings.Cut(strings.TrimSpace(line), ":")
+		if !ok || !selected[field] {
+			continue
+		}
+		if v, err := strconv.ParseFloat(value, 64); err == nil {
+			values[field] = v
+		}
+	}
+	return values
+}
+
+// failed reports whether err is a command failure. redis.Nil only signals a
+// missing key.
+func failed(err error) bool {
+	return err != nil && !errors.Is(err, redis.Nil)
+}
+
+var _ redis.Hook = (*Hook)(nil)
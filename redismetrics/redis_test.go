@@ -0,0 +1,215 @@
+package redismetrics
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net"
+	"testing"
+
+	"github.com/OkanUysal/go-metrics"
+	"github.com/OkanUysal/go-metrics/metricstest"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeServer answers commands in a hook instead of a Redis server, so
+// clients never dial
+type fakeServer struct {
+	types   map[string]string
+	lengths map[string]int64
+	pending int64
+	info    string
+}
+
+func (f *fakeServer) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return nil, errors.New("fakeServer does not dial")
+	}
+}
+
+func (f *fakeServer) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		f.process(cmd)
+		return cmd.Err()
+	}
+}
+
+func (f *fakeServer) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		for _, cmd := range cmds {
+			f.process(cmd)
+		}
+		return nil
+	}
+}
+
+// process sets the reply of a command
+func (f *fakeServer) process(cmd redis.Cmder) {
+	key, _ := cmd.Args()[len(cmd.Args())-1].(string)
+	switch c := cmd.(type) {
+	case *redis.StatusCmd:
+		typ, ok := f.types[key]
+		if !ok {
+			typ = "none"
+		}
+		c.SetVal(typ)
+	case *redis.IntCmd:
+		c.SetVal(f.lengths[key])
+	case *redis.XPendingCmd:
+		c.SetVal(&redis.XPending{Count: f.pending})
+	case *redis.StringCmd:
+		switch cmd.Name() {
+		case "info":
+			c.SetVal(f.info)
+		case "get":
+			if key == "missing" {
+				c.SetErr(redis.Nil)
+			} else if key == "broken" {
+				c.SetErr(errors.New("READONLY You can't write against a read only replica"))
+			}
+		}
+	}
+}
+
+// newFakeClient creates a client answered by f, with hooks in front of it
+func newFakeClient(f *fakeServer, hooks ...redis.Hook) *redis.Client {
+	client := redis.NewClient(&redis.Options{Addr: "fake:6379", DisableIdentity: true})
+	for _, hook := range hooks {
+		client.AddHook(hook)
+	}
+	client.AddHook(f)
+	return client
+}
+
+func TestHook(t *testing.T) {
+	m := metrics.New(metrics.WithoutHTTPMetrics())
+	client := newFakeClient(&fakeServer{}, NewHook(m.NewRedisMetrics()))
+	ctx := context.Background()
+
+	client.Get(ctx, "user:1")
+	client.Get(ctx, "missing")
+	client.Get(ctx, "broken")
+
+	pipe := client.Pipeline()
+	pipe.Get(ctx, "user:1")
+	pipe.Get(ctx, "broken")
+	pipe.Get(ctx, "missing")
+	pipe.Exec(ctx)
+
+	get := metrics.MetricLabels{"command": "get"}
+	metricstest.AssertHistogramCount(t, m, "redis_command_duration_seconds", get, 3)
+	// redis.Nil is a missing key, not a failure
+	metricstest.AssertCounterValue(t, m, "redis_command_errors_total", get, 2)
+	metricstest.AssertHistogramCount(t, m, "redis_pipeline_size", nil, 1)
+	metricstest.AssertHistogramCount(t, m, "redis_pipeline_duration_seconds", nil, 1)
+}
+
+func TestKeyLength(t *testing.T) {
+	f := &fakeServer{
+		types: map[string]string{
+			"queue": "list", "tags": "set", "ranks": "zset", "user": "hash",
+			"events": "stream", "token": "string", "geo": "vectorset",
+		},
+		lengths: map[string]int64{
+			"queue": 1, "tags": 2, "ranks": 3, "user": 4, "events": 5, "token": 6, "geo": 7,
+		},
+	}
+	client := newFakeClient(f)
+
+	tests := []struct {
+		key     string
+		want    int64
+		wantErr bool
+	}{
+		{"queue", 1, false},
+		{"tags", 2, false},
+		{"ranks", 3, false},
+		{"user", 4, false},
+		{"events", 5, false},
+		{"token", 6, false},
+		{"absent", 0, false},
+		{"geo", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := keyLength(context.Background(), client, tt.key)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("keyLength(%q) = %d, %v, want %d, error %t", tt.key, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestReadKeys(t *testing.T) {
+	f := &fakeServer{
+		types:   map[string]string{"queue": "list", "geo": "vectorset"},
+		lengths: map[string]int64{"queue": 17},
+		pending: 4,
+		info:    "# Memory\r\nused_memory:1048576\r\n",
+	}
+	client := newFakeClient(f)
+
+	stats, err := readKeys(context.Background(), client, Keys{
+		Lengths: []string{"queue", "geo"},
+		Streams: []StreamGroup{{Stream: "events", Group: "billing"}},
+		Info:    []string{"used_memory"},
+	})
+	// Keys that cannot be read are reported without losing the others
+	if err == nil {
+		t.Error("Expected the error of the unsupported key")
+	}
+	if !maps.Equal(stats.Lengths, map[string]int64{"queue": 17}) {
+		t.Errorf("Unexpected lengths %v", stats.Lengths)
+	}
+	if len(stats.Pending) != 1 || stats.Pending[0] != (metrics.RedisStreamPending{Stream: "events", Group: "billing", Pending: 4}) {
+		t.Errorf("Unexpected pending entries %v", stats.Pending)
+	}
+	if stats.Info["used_memory"] != 1048576 {
+		t.Errorf("Unexpected INFO fields %v", stats.Info)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	tests := []struct {
+		name   string
+		info   string
+		fields []string
+		want   map[string]float64
+	}{
+		{
+			name:   "CRLF lines",
+			info:   "used_memory:1048576\r\nconnected_clients:12\r\n",
+			fields: []string{"used_memory", "connected_clients"},
+			want:   map[string]float64{"used_memory": 1048576, "connected_clients": 12},
+		},
+		{
+			name:   "section headers",
+			info:   "# Server\r\nredis_version:7.2.4\r\n\r\n# Clients\r\nconnected_clients:12\r\n",
+			fields: []string{"connected_clients", "# Clients", "Clients"},
+			want:   map[string]float64{"connected_clients": 12},
+		},
+		{
+			name:   "non-numeric values",
+			info:   "used_memory_human:1.00M\nrole:master\nmem_fragmentation_ratio:1.25\ndb0:keys=1,expires=0\n",
+			fields: []string{"used_memory_human", "role", "mem_fragmentation_ratio", "db0"},
+			want:   map[string]float64{"mem_fragmentation_ratio": 1.25},
+		},
+		{
+			name:   "unselected fields",
+			info:   "used_memory:1048576\nconnected_clients:12\n",
+			fields: []string{"connected_clients", "uptime_in_seconds"},
+			want:   map[string]float64{"connected_clients": 12},
+		},
+		{
+			name:   "empty reply",
+			info:   "",
+			fields: []string{"used_memory"},
+			want:   map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseInfo(tt.info, tt.fields); !maps.Equal(got, tt.want) {
+				t.Errorf("parseInfo() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
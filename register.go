@@ -0,0 +1,161 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+)
+
+// RegisterCounter declares a counter up front with help text and an explicit
+// label set. Later IncrementCounter calls must use exactly these label keys.
+func (m *Metrics) RegisterCounter(name, help string, labelKeys []string) error {
+	name = m.currentName(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRegistration(name, help, labelKeys); err != nil {
+		return err
+	}
+
+	counter := prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace:   m.config.Namespace,
+			Subsystem:   m.config.Subsystem,
+			Name:        name,
+			Help:        help,
+			ConstLabels: m.config.ConstLabels,
+		},
+		labelKeys,
+	)
+
+	counter, err := registerShared(m, counter)
+	if err != nil {
+		return fmt.Errorf("failed to register counter %q: %w", name, err)
+	}
+	m.counters[name] = counter
+	m.schemas[name] = slices.Sorted(slices.Values(labelKeys))
+	m.lookup.counters.Store(name, &lookupEntry[*prometheus.CounterVec]{vec: counter, schema: m.schemas[name]})
+	m.own(name)
+
+	return nil
+}
+
+// RegisterGauge declares a gauge up front with help text and an explicit
+// label set. Later gauge calls must use exactly these label keys.
+func (m *Metrics) RegisterGauge(name, help string, labelKeys []string) error {
+	name = m.currentName(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRegistration(name, help, labelKeys); err != nil {
+		return err
+	}
+
+	gauge := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace:   m.config.Namespace,
+			Subsystem:   m.config.Subsystem,
+			Name:        name,
+			Help:        help,
+			ConstLabels: m.config.ConstLabels,
+		},
+		labelKeys,
+	)
+
+	gauge, err := registerShared(m, gauge)
+	if err != nil {
+		return fmt.Errorf("failed to register gauge %q: %w", name, err)
+	}
+	m.gauges[name] = gauge
+	m.schemas[name] = slices.Sorted(slices.Values(labelKeys))
+	m.lookup.gauges.Store(name, &lookupEntry[*prometheus.GaugeVec]{vec: gauge, schema: m.schemas[name]})
+	m.own(name)
+
+	return nil
+}
+
+// RegisterHistogram declares a histogram up front with help text, an explicit
+// label set and buckets (nil uses the defaults). Later RecordHistogram calls
+// must use exactly these label keys.
+func (m *Metrics) RegisterHistogram(name, help string, labelKeys []string, buckets []float64) error {
+	name = m.currentName(name)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkRegistration(name, help, labelKeys); err != nil {
+		return err
+	}
+	if buckets == nil {
+		buckets = defaultBuckets(name)
+	}
+
+	histogram := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace:   m.config.Namespace,
+			Subsystem:   m.config.Subsystem,
+			Name:        name,
+			Help:        help,
+			Buckets:     buckets,
+			ConstLabels: m.config.ConstLabels,
+		},
+		labelKeys,
+	)
+
+	histogram, err := registerShared(m, histogram)
+	if err != nil {
+		return fmt.Errorf("failed to register histogram %q: %w", name, err)
+	}
+	m.histograms[name] = histogram
+	m.schemas[name] = slices.Sorted(slices.Values(labelKeys))
+	m.lookup.histograms.Store(name, &lookupEntry[*prometheus.HistogramVec]{vec: histogram, schema: m.schemas[name]})
+	m.own(name)
+
+	return nil
+}
+
+// ensureHistogram registers a histogram of a helper with its own buckets
+// unless it exists, logging a failure, so creating the helper twice works
+func (m *Metrics) ensureHistogram(name, help string, labelKeys []string, buckets []float64) {
+	m.mu.RLock()
+	_, exists := m.histograms[name]
+	m.mu.RUnlock()
+	if exists {
+		return
+	}
+	if err := m.RegisterHistogram(name, help, labelKeys, buckets); err != nil {
+		m.logf("Failed to register %s: %v", name, err)
+	}
+}
+
+// checkRegistration validates an explicit registration. Must be called with
+// m.mu held.
+func (m *Metrics) checkRegistration(name, help string, labelKeys []string) error {
+	if m.frozen {
+		return fmt.Errorf("cannot register metric %q after Freeze", name)
+	}
+	if !model.IsValidLegacyMetricName(name) {
+		return fmt.Errorf("%w: metric name %q must match %s", ErrInvalidName, name, metricNamePattern)
+	}
+	if help == "" {
+		return fmt.Errorf("metric %q: help text is required", name)
+	}
+	for _, key := range labelKeys {
+		if !model.LabelName(key).IsValidLegacy() {
+			return fmt.Errorf("%w: label key %q of metric %q must match %s", ErrInvalidName, key, name, labelNamePattern)
+		}
+	}
+
+	_, counter := m.counters[name]
+	_, gauge := m.gauges[name]
+	_, histogram := m.histograms[name]
+	if counter || gauge || histogram {
+		return fmt.Errorf("metric %q is already registered", name)
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// RelabelAction is what a RelabelRule does with the series it matches
+type RelabelAction string
+
+const (
+	// RelabelKeep drops the series whose SourceLabel does not match Regex
+	RelabelKeep RelabelAction = "keep"
+
+	// RelabelDrop drops the series whose SourceLabel matches Regex
+	RelabelDrop RelabelAction = "drop"
+
+	// RelabelReplace sets TargetLabel to Replacement, expanded with the
+	// groups of Regex, if SourceLabel matches. An empty result removes the
+	// label.
+	RelabelReplace RelabelAction = "replace"
+
+	// RelabelLabelDrop removes the labels whose name matches Regex
+	RelabelLabelDrop RelabelAction = "labeldrop"
+)
+
+// RelabelRule rewrites or filters the series pushed to a remote write
+// target, like a Prometheus write_relabel_configs entry. Rules only apply
+// to pushes; the /metrics endpoint keeps serving all series.
+//
+//	{Action: metrics.RelabelDrop, Regex: "go_gc_.*|process_.*"}
+//	{Action: metrics.RelabelReplace, SourceLabel: "path", Regex: "/users/.*", TargetLabel: "path", Replacement: "/users/:id"}
+type RelabelRule struct {
+	Action      RelabelAction
+	SourceLabel string // Label matched by Regex, defaults to __name__
+	Regex       string // Anchored at both ends, defaults to (.*)
+	TargetLabel string // Label set by RelabelReplace
+	Replacement string // Value of TargetLabel, may reference groups as $1; defaults to $1
+}
+
+// relabelRegexps caches the compiled regexps of relabel rules, which are
+// applied on every push
+var relabelRegexps sync.Map // string -> *regexp.Regexp
+
+// compile returns the anchored regexp of the rule
+func (r RelabelRule) compile() (*regexp.Regexp, error) {
+	expr := r.Regex
+	if expr == "" {
+		expr = "(.*)"
+	}
+	if re, ok := relabelRegexps.Load(expr); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile("^(?:" + expr + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid relabel regex %q: %w", r.Regex, err)
+	}
+	relabelRegexps.Store(expr, re)
+	return re, nil
+}
+
+// validateRelabelRules returns an error if a rule has an unknown action, an
+// invalid regex or misses its target label
+func validateRelabelRules(rules []RelabelRule) error {
+	for i, rule := range rules {
+		if _, err := rule.compile(); err != nil {
+			return fmt.Errorf("relabel rule %d: %w", i, err)
+		}
+		switch rule.Action {
+		case RelabelKeep, RelabelDrop, RelabelLabelDrop:
+		case RelabelReplace:
+			if rule.TargetLabel == "" {
+				return fmt.Errorf("relabel rule %d: replace requires a TargetLabel", i)
+			}
+		default:
+			return fmt.Errorf("relabel rule %d: unknown action %q", i, rule.Action)
+		}
+	}
+	return nil
+}
+
+// relabeler returns the function applying the RelabelRules and then the
+// Relabel function of the target to the labels of a series, or nil if the
+// target has neither
+func (t RemoteWriteTarget) relabeler() (func(map[string]string) bool, error) {
+	if len(t.RelabelRules) == 0 {
+		return t.Relabel, nil
+	}
+	if err := validateRelabelRules(t.RelabelRules); err != nil {
+		return nil, err
+	}
+
+	rules := t.RelabelRules
+	relabel := t.Relabel
+	return func(labels map[string]string) bool {
+		for _, rule := range rules {
+			if !rule.apply(labels) {
+				return false
+			}
+		}
+		return relabel == nil || relabel(labels)
+	}, nil
+}
+
+// apply applies a validated rule to the labels of a series, reporting
+// whether the series is kept
+func (r RelabelRule) apply(labels map[string]string) bool {
+	re, _ := r.compile()
+
+	if r.Action == RelabelLabelDrop {
+		for name := range labels {
+			if re.MatchString(name) {
+				delete(labels, name)
+			}
+		}
+		return true
+	}
+
+	source := r.SourceLabel
+	if source == "" {
+		source = "__name__"
+	}
+	value := labels[source]
+
+	switch r.Action {
+	case RelabelKeep:
+		return re.MatchString(value)
+	case RelabelDrop:
+		return !re.MatchString(value)
+	case RelabelReplace:
+		match := re.FindStringSubmatchIndex(value)
+		if match == nil {
+			return true
+		}
+		replacement := r.Replacement
+		if replacement == "" {
+			replacement = "$1"
+		}
+		result := string(re.ExpandString(nil, replacement, value, match))
+		if result == "" {
+			delete(labels, r.TargetLabel)
+		} else {
+			labels[r.TargetLabel] = result
+		}
+	}
+	return true
+}
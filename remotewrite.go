@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// maxSkippedPushes caps the pushes a failing target skips between attempts
+const maxSkippedPushes = 16
+
+// name returns the label of the target in push metrics
+func (t RemoteWriteTarget) name() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	if u, err := url.Parse(t.URL); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return t.URL
+}
+
+// key identifies a target: its URL, with the team of TeamRemoteWrite
+// targets as fragment, since teams may share a URL
+func (t RemoteWriteTarget) key() string {
+	if t.team == "" {
+		return t.URL
+	}
+	return t.URL + "#team=" + t.team
+}
+
+// remoteWriteStates keeps the retry state of every remote write target, so
+// a failing target backs off without delaying the others
+type remoteWriteStates struct {
+	mu     sync.Mutex
+	states map[string]*remoteWriteState // By target key
+}
+
+// remoteWriteState is the retry state of one target. After n consecutive
+// failures, the next 2^(n-1)-1 pushes are skipped, up to maxSkippedPushes.
+type remoteWriteState struct {
+	mu       sync.Mutex
+	failures int
+	skip     int  // Pushes left to skip
+	v1Only   bool // Remote-Write 2.0 was rejected
+}
+
+// newRemoteWriteStates creates an empty set of retry states
+func newRemoteWriteStates() *remoteWriteStates {
+	return &remoteWriteStates{states: make(map[string]*remoteWriteState)}
+}
+
+// get returns the retry state of target
+func (s *remoteWriteStates) get(target RemoteWriteTarget) *remoteWriteState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[target.key()]
+	if !ok {
+		state = &remoteWriteState{}
+		s.states[target.key()] = state
+	}
+	return state
+}
+
+// due reports whether the target should be pushed to now, counting down
+// the pushes to skip
+func (s *remoteWriteState) due() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.skip > 0 {
+		s.skip--
+		return false
+	}
+	return true
+}
+
+// succeeded resets the backoff after a successful push
+func (s *remoteWriteState) succeeded() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures = 0
+}
+
+// failed extends the backoff after a failed push
+func (s *remoteWriteState) failed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.failures++
+	s.skip = min(1<<min(s.failures-1, 8)-1, maxSkippedPushes)
+}
+
+// relabelSeries applies relabel to every series of a write request, dropping
+// the series it returns false for
+func relabelSeries(req *prompb.WriteRequest, relabel func(map[string]string) bool) {
+	kept := req.Timeseries[:0]
+	for _, ts := range req.Timeseries {
+		labels := make(map[string]string, len(ts.Labels))
+		for _, l := range ts.Labels {
+			labels[l.Name] = l.Value
+		}
+		if !relabel(labels) {
+			continue
+		}
+
+		ts.Labels = ts.Labels[:0]
+		for name, value := range labels {
+			ts.Labels = append(ts.Labels, prompb.Label{Name: name, Value: value})
+		}
+		// Remote write requires labels sorted by name
+		slices.SortFunc(ts.Labels, func(a, b prompb.Label) int { return strings.Compare(a.Name, b.Name) })
+		kept = append(kept, ts)
+	}
+	req.Timeseries = kept
+}
@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/prometheus/prompb"
+	writev2 "github.com/prometheus/prometheus/prompb/io/prometheus/write/v2"
+)
+
+// Content types of the remote write protocol versions
+const (
+	remoteWriteContentTypeV1 = "application/x-protobuf"
+	remoteWriteContentTypeV2 = "application/x-protobuf;proto=io.prometheus.write.v2.Request"
+)
+
+// remoteWriteBody is a snappy-compressed remote write request
+type remoteWriteBody struct {
+	compressed []byte
+	v2         bool // io.prometheus.write.v2.Request instead of prometheus.WriteRequest
+}
+
+// remoteWriteV2 reports whether requests to target are encoded with
+// Remote-Write 2.0: if RemoteWriteV2 is set and the endpoint did not reject it
+func (m *Metrics) remoteWriteV2(target RemoteWriteTarget) bool {
+	return m.config.RemoteWriteV2 && m.remoteWriteStates.get(target).acceptsV2()
+}
+
+// acceptsV2 reports whether the endpoint has not rejected Remote-Write 2.0
+func (s *remoteWriteState) acceptsV2() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.v1Only
+}
+
+// rejectedV2 makes the endpoint fall back to Remote-Write 1.0, reporting
+// whether it accepted 2.0 before
+func (s *remoteWriteState) rejectedV2() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	accepted := !s.v1Only
+	s.v1Only = true
+	return accepted
+}
+
+// remoteWriteV2Request converts gathered metrics to a Remote-Write 2.0
+// request. Labels, help texts and units are stored once in the symbol table,
+// native histograms are sent as such and counters, histograms and summaries
+// carry their created timestamp. relabel is applied as in relabelSeries.
+func remoteWriteV2Request(metricFamilies []*dto.MetricFamily, externalLabels map[string]string, at time.Time, relabel func(map[string]string) bool) *writev2.Request {
+	symbols := writev2.NewSymbolTable()
+	var timeseries []writev2.TimeSeries
+	now := at.UnixMilli()
+
+	for _, mf := range metricFamilies {
+		metadata := writev2.Metadata{
+			Type:    writev2.Metadata_MetricType(metadataType(mf.GetType())),
+			HelpRef: symbols.Symbolize(mf.GetHelp()),
+			UnitRef: symbols.Symbolize(metricUnit(mf)),
+		}
+
+		for _, metric := range mf.GetMetric() {
+			labels := make(map[string]string, len(metric.GetLabel())+len(externalLabels)+1)
+			for name, value := range externalLabels {
+				labels[name] = value
+			}
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			labels["__name__"] = mf.GetName()
+			if relabel != nil && !relabel(labels) {
+				continue
+			}
+
+			// Remote write requires labels sorted by name
+			refs := make([]uint32, 0, 2*len(labels))
+			for _, name := range slices.Sorted(maps.Keys(labels)) {
+				refs = append(refs, symbols.Symbolize(name), symbols.Symbolize(labels[name]))
+			}
+
+			// Backfilled series carry the time of their latest event
+			timestamp := now
+			if metric.TimestampMs != nil {
+				timestamp = metric.GetTimestampMs()
+			}
+
+			ts := writev2.TimeSeries{LabelsRefs: refs, Metadata: metadata}
+			if h := metric.GetHistogram(); h != nil && h.Schema != nil {
+				ts.Histograms = []writev2.Histogram{nativeHistogram(h, timestamp, createdTimestamp(metric))}
+			} else {
+				ts.Samples = []writev2.Sample{{
+					Value:          sampleValue(mf.GetType(), metric),
+					Timestamp:      timestamp,
+					StartTimestamp: createdTimestamp(metric),
+				}}
+			}
+			timeseries = append(timeseries, ts)
+		}
+	}
+
+	return &writev2.Request{
+		Symbols:    symbols.Symbols(),
+		Timeseries: timeseries,
+	}
+}
+
+// nativeHistogram converts a gathered native histogram
+func nativeHistogram(h *dto.Histogram, timestamp, startTimestamp int64) writev2.Histogram {
+	spans := func(spans []*dto.BucketSpan) []writev2.BucketSpan {
+		converted := make([]writev2.BucketSpan, 0, len(spans))
+		for _, s := range spans {
+			converted = append(converted, writev2.BucketSpan{Offset: s.GetOffset(), Length: s.GetLength()})
+		}
+		return converted
+	}
+
+	return writev2.Histogram{
+		Count:          &writev2.Histogram_CountInt{CountInt: h.GetSampleCount()},
+		Sum:            h.GetSampleSum(),
+		Schema:         h.GetSchema(),
+		ZeroThreshold:  h.GetZeroThreshold(),
+		ZeroCount:      &writev2.Histogram_ZeroCountInt{ZeroCountInt: h.GetZeroCount()},
+		NegativeSpans:  spans(h.GetNegativeSpan()),
+		NegativeDeltas: h.GetNegativeDelta(),
+		PositiveSpans:  spans(h.GetPositiveSpan()),
+		PositiveDeltas: h.GetPositiveDelta(),
+		Timestamp:      timestamp,
+		StartTimestamp: startTimestamp,
+	}
+}
+
+// createdTimestamp returns the time a counter, histogram or summary was
+// created in milliseconds, or 0 if unknown
+func createdTimestamp(metric *dto.Metric) int64 {
+	switch {
+	case metric.GetCounter().GetCreatedTimestamp() != nil:
+		return metric.GetCounter().GetCreatedTimestamp().AsTime().UnixMilli()
+	case metric.GetHistogram().GetCreatedTimestamp() != nil:
+		return metric.GetHistogram().GetCreatedTimestamp().AsTime().UnixMilli()
+	case metric.GetSummary().GetCreatedTimestamp() != nil:
+		return metric.GetSummary().GetCreatedTimestamp().AsTime().UnixMilli()
+	}
+	return 0
+}
+
+// downgradeRemoteWrite converts a compressed Remote-Write 2.0 request to
+// 1.0, for queued requests to an endpoint that turned out not to support 2.0.
+// Metadata is sent once per family and created timestamps are dropped.
+func downgradeRemoteWrite(compressed []byte) ([]byte, error) {
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress request: %w", err)
+	}
+	var v2 writev2.Request
+	if err := proto.Unmarshal(data, &v2); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+
+	symbol := func(ref uint32) string {
+		if int(ref) < len(v2.Symbols) {
+			return v2.Symbols[ref]
+		}
+		return ""
+	}
+
+	var req prompb.WriteRequest
+	families := make(map[string]bool)
+	for _, ts := range v2.Timeseries {
+		var series prompb.TimeSeries
+		var name string
+		for i := 0; i+1 < len(ts.LabelsRefs); i += 2 {
+			label := prompb.Label{Name: symbol(ts.LabelsRefs[i]), Value: symbol(ts.LabelsRefs[i+1])}
+			if label.Name == "__name__" {
+				name = label.Value
+			}
+			series.Labels = append(series.Labels, label)
+		}
+		for _, s := range ts.Samples {
+			series.Samples = append(series.Samples, prompb.Sample{Value: s.Value, Timestamp: s.Timestamp})
+		}
+		for _, h := range ts.Histograms {
+			series.Histograms = append(series.Histograms, downgradeHistogram(h))
+		}
+		req.Timeseries = append(req.Timeseries, series)
+
+		if !families[name] {
+			families[name] = true
+			req.Metadata = append(req.Metadata, prompb.MetricMetadata{
+				Type:             prompb.MetricMetadata_MetricType(ts.Metadata.Type),
+				MetricFamilyName: name,
+				Help:             symbol(ts.Metadata.HelpRef),
+				Unit:             symbol(ts.Metadata.UnitRef),
+			})
+		}
+	}
+
+	data, err = proto.Marshal(&req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal protobuf: %w", err)
+	}
+	return snappy.Encode(nil, data), nil
+}
+
+// downgradeHistogram converts a Remote-Write 2.0 native histogram to 1.0
+func downgradeHistogram(h writev2.Histogram) prompb.Histogram {
+	spans := func(spans []writev2.BucketSpan) []prompb.BucketSpan {
+		converted := make([]prompb.BucketSpan, 0, len(spans))
+		for _, s := range spans {
+			converted = append(converted, prompb.BucketSpan{Offset: s.Offset, Length: s.Length})
+		}
+		return converted
+	}
+
+	return prompb.Histogram{
+		Count:          &prompb.Histogram_CountInt{CountInt: h.GetCountInt()},
+		Sum:            h.Sum,
+		Schema:         h.Schema,
+		ZeroThreshold:  h.ZeroThreshold,
+		ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: h.GetZeroCountInt()},
+		NegativeSpans:  spans(h.NegativeSpans),
+		NegativeDeltas: h.NegativeDeltas,
+		PositiveSpans:  spans(h.PositiveSpans),
+		PositiveDeltas: h.PositiveDeltas,
+		Timestamp:      h.Timestamp,
+	}
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// renameLog remembers the old metric names whose use was logged, so each
+// call site is reported once. It is shared by a Metrics and its scopes.
+type renameLog struct {
+	mu     sync.Mutex
+	logged map[string]bool
+}
+
+// newRenameLog creates an empty rename log
+func newRenameLog() *renameLog {
+	return &renameLog{logged: make(map[string]bool)}
+}
+
+// currentName returns the new name of a metric renamed in MetricRenames,
+// counting and logging the use of the old name, or name unchanged
+func (m *Metrics) currentName(name string) string {
+	renamed, ok := m.config.MetricRenames[name]
+	if !ok {
+		return name
+	}
+
+	m.internal.RenamedUsage.WithLabelValues(name).Inc()
+
+	l := m.renames
+	l.mu.Lock()
+	first := !l.logged[name]
+	l.logged[name] = true
+	l.mu.Unlock()
+	if first {
+		m.logf("Metric %s was renamed to %s, update the call site", name, renamed)
+	}
+	return renamed
+}
+
+// renameTransition reports whether old names are still emitted at now
+func (m *Metrics) renameTransition(now time.Time) bool {
+	return len(m.config.MetricRenames) > 0 && (m.config.MetricRenamesUntil.IsZero() || now.Before(m.config.MetricRenamesUntil))
+}
+
+// renameGatherer adds a copy of every renamed metric under its old name to
+// the gathered families during the transition, so dashboards and alerts
+// keep working while they are migrated
+func (m *Metrics) renameGatherer(g prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := g.Gather()
+		if !m.renameTransition(time.Now()) {
+			return families, err
+		}
+
+		byName := make(map[string]*dto.MetricFamily, len(families))
+		for _, mf := range families {
+			byName[mf.GetName()] = mf
+		}
+		for old, renamed := range m.config.MetricRenames {
+			oldName := m.QualifiedName(old)
+			mf, ok := byName[m.QualifiedName(renamed)]
+			if !ok || byName[oldName] != nil {
+				continue
+			}
+			families = append(families, &dto.MetricFamily{
+				Name:   proto.String(oldName),
+				Help:   proto.String("Deprecated, renamed to " + mf.GetName() + ". " + mf.GetHelp()),
+				Type:   mf.Type,
+				Unit:   mf.Unit,
+				Metric: mf.Metric,
+			})
+		}
+		return families, err
+	})
+}
@@ -0,0 +1,108 @@
+package metrics
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IdempotencyKeyHeader is the header carrying a client idempotency key
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// DefaultRetryHeaders are the headers that mark a request as a client retry
+// when RetryHeaders is not set
+var DefaultRetryHeaders = []string{"X-Retry-Count", "X-Retry-Attempt"}
+
+// maxIdempotencyKeys bounds the keys remembered per window
+const maxIdempotencyKeys = 100000
+
+// retryTracker counts retried requests and repeated idempotency keys
+type retryTracker struct {
+	IdempotentRequests *prometheus.CounterVec
+	DuplicateRequests  *prometheus.CounterVec
+	RetryRequests      *prometheus.CounterVec
+
+	headers []string
+	window  time.Duration
+
+	// Keys are kept for one to two windows by rotating two generations
+	current, previous map[string]struct{}
+	rotated           time.Time
+	mu                sync.Mutex
+}
+
+// newRetryTracker creates the retry metrics, registering them with the registry
+func (m *Metrics) newRetryTracker() *retryTracker {
+	counter := func(name, help string) *prometheus.CounterVec {
+		return prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        name,
+				Help:        help,
+				ConstLabels: m.httpConstLabels(),
+			},
+			[]string{"method", "path"},
+		)
+	}
+
+	t := &retryTracker{
+		IdempotentRequests: counter("http_idempotent_requests_total", "HTTP requests carrying an Idempotency-Key header"),
+		DuplicateRequests:  counter("http_idempotent_duplicates_total", "HTTP requests repeating an Idempotency-Key seen within the window"),
+		RetryRequests:      counter("http_retry_requests_total", "HTTP requests marked as client retries by a retry header"),
+		headers:            m.config.RetryHeaders,
+		window:             m.config.IdempotencyKeyWindow,
+		current:            make(map[string]struct{}),
+		previous:           make(map[string]struct{}),
+		rotated:            time.Now(),
+	}
+	if t.headers == nil {
+		t.headers = DefaultRetryHeaders
+	}
+	if t.window <= 0 {
+		t.window = 10 * time.Minute
+	}
+
+	m.registry.MustRegister(t.IdempotentRequests, t.DuplicateRequests, t.RetryRequests)
+	return t
+}
+
+// record counts a request carrying an idempotency key or retry header
+func (t *retryTracker) record(r *http.Request, path string) {
+	for _, h := range t.headers {
+		if v := r.Header.Get(h); v != "" && v != "0" {
+			t.RetryRequests.WithLabelValues(r.Method, path).Inc()
+			break
+		}
+	}
+
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		return
+	}
+
+	t.IdempotentRequests.WithLabelValues(r.Method, path).Inc()
+	if t.seen(r.Method + " " + path + " " + key) {
+		t.DuplicateRequests.WithLabelValues(r.Method, path).Inc()
+	}
+}
+
+// seen remembers key and reports whether it was already seen
+func (t *retryTracker) seen(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if time.Since(t.rotated) >= t.window || len(t.current) >= maxIdempotencyKeys {
+		t.previous, t.current = t.current, make(map[string]struct{})
+		t.rotated = time.Now()
+	}
+
+	if _, ok := t.current[key]; ok {
+		return true
+	}
+	_, ok := t.previous[key]
+	t.current[key] = struct{}{}
+	return ok
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// routeWatch tracks the routes of watched routers, so series and path
+// normalization follow routes added after startup. It is shared by a
+// Metrics and its scopes.
+type routeWatch struct {
+	mu        sync.RWMutex
+	routers   []*gin.Engine
+	interval  time.Duration
+	next      atomic.Int64    // Unix nanoseconds of the next check
+	known     map[string]bool // By method and path
+	paths     map[string]bool // Known paths of any method
+	templates [][]string      // Path segments of the known paths
+	listeners []func(gin.RoutesInfo)
+}
+
+// newRouteWatch creates an empty route watch
+func newRouteWatch() *routeWatch {
+	return &routeWatch{known: make(map[string]bool), paths: make(map[string]bool)}
+}
+
+// WatchRoutes checks router for added routes at most every interval (10s
+// if zero), for applications that mount routes after startup. The series of
+// added routes are pre-created for middleware with HTTPPrecreateSeries, and
+// RouteNormalizer learns their templates. With HTTPRouteWatchInterval set,
+// Setup watches its router.
+//
+// The check runs in the middleware while serving a request, like the route
+// lookup of gin, so it is as safe as serving while routes are mounted; a
+// separate goroutine would race with routes being added.
+func (m *Metrics) WatchRoutes(router *gin.Engine, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	w := m.routes
+	w.mu.Lock()
+	w.routers = append(w.routers, router)
+	w.interval = interval
+	w.mu.Unlock()
+	w.next.Store(0)
+
+	m.refreshRoutes(router.Routes())
+}
+
+// RefreshRoutes picks up the routes added to router right away, e.g. after
+// mounting a plugin, instead of waiting for WatchRoutes to check
+func (m *Metrics) RefreshRoutes(router *gin.Engine) {
+	m.refreshRoutes(router.Routes())
+}
+
+// checkRoutes refreshes the routes of the watched routers if the interval
+// has passed since the last check
+func (m *Metrics) checkRoutes(now time.Time) {
+	w := m.routes
+	next := w.next.Load()
+	if now.UnixNano() < next {
+		return
+	}
+
+	w.mu.RLock()
+	routers, interval := w.routers, w.interval
+	w.mu.RUnlock()
+	if len(routers) == 0 || !w.next.CompareAndSwap(next, now.Add(interval).UnixNano()) {
+		return
+	}
+	for _, router := range routers {
+		m.refreshRoutes(router.Routes())
+	}
+}
+
+// refreshRoutes adds the routes not seen before and notifies the listeners
+// of them
+func (m *Metrics) refreshRoutes(routes gin.RoutesInfo) {
+	w := m.routes
+	w.mu.Lock()
+	var added gin.RoutesInfo
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		if w.known[key] {
+			continue
+		}
+		if !w.paths[route.Path] {
+			w.paths[route.Path] = true
+			w.templates = append(w.templates, strings.Split(route.Path, "/"))
+		}
+		w.known[key] = true
+		added = append(added, route)
+	}
+	listeners := w.listeners
+	w.mu.Unlock()
+
+	if len(added) == 0 {
+		return
+	}
+	for _, listener := range listeners {
+		listener(added)
+	}
+}
+
+// onRoutesAdded calls listener with the routes added to watched routers
+func (m *Metrics) onRoutesAdded(listener func(gin.RoutesInfo)) {
+	w := m.routes
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, listener)
+}
+
+// RouteNormalizer returns a PathNormalizer mapping raw paths to the template
+// of the route they match, e.g. /users/42 to /users/:id, among the routes of
+// routers watched with WatchRoutes. Static segments win over parameters,
+// which win over wildcards. Paths matching no route map to "", i.e.
+// MiddlewareOptions.UnmatchedPath. Use it with PathRaw, or for requests
+// handled outside of the router such as NoRoute handlers of mounted plugins.
+func (m *Metrics) RouteNormalizer() func(string) string {
+	w := m.routes
+	return func(path string) string {
+		segments := strings.Split(path, "/")
+
+		w.mu.RLock()
+		defer w.mu.RUnlock()
+
+		var best []string
+		bestScore := -1
+		for _, template := range w.templates {
+			if score := matchRoute(template, segments); score > bestScore {
+				best, bestScore = template, score
+			}
+		}
+		if best == nil {
+			return ""
+		}
+		return strings.Join(best, "/")
+	}
+}
+
+// matchRoute scores how specifically a route template matches path
+// segments: 2 per static and 1 per parameter segment, or -1 if it does not
+// match
+func matchRoute(template, segments []string) int {
+	score := 0
+	for i, t := range template {
+		if strings.HasPrefix(t, "*") {
+			return score
+		}
+		if i >= len(segments) {
+			return -1
+		}
+		switch {
+		case strings.HasPrefix(t, ":"):
+			if segments[i] == "" {
+				return -1
+			}
+			score++
+		case t == segments[i]:
+			score += 2
+		default:
+			return -1
+		}
+	}
+	if len(segments) != len(template) {
+		return -1
+	}
+	return score
+}
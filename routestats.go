@@ -0,0 +1,195 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// RouteStats are the recent request rate, error rate and latency of a route,
+// computed from the HTTP metrics over HTTPRouteStatsWindow
+type RouteStats struct {
+	Requests  float64       // Requests per second
+	ErrorRate float64       // Fraction of requests answered with a 5xx status
+	P95       time.Duration // 95th percentile of the request duration, 0 without requests
+	Window    time.Duration // Time the stats cover, shorter than the window after startup
+}
+
+// routeKey identifies a route by method and route template
+type routeKey struct {
+	method, route string
+}
+
+// routeTotals are the cumulative request counts and duration buckets of a
+// route at one point in time
+type routeTotals struct {
+	requests, errors float64
+	buckets          map[float64]uint64 // Cumulative by upper bound, including +Inf
+}
+
+// routeSample holds the totals of all routes at one point in time
+type routeSample struct {
+	at     time.Time
+	routes map[routeKey]*routeTotals
+}
+
+// routeStatsTracker keeps the samples of the last window
+type routeStatsTracker struct {
+	mu      sync.RWMutex
+	window  time.Duration
+	samples []routeSample // Oldest first
+}
+
+// StartRouteStats samples the HTTP metrics per route until ctx is
+// cancelled, so RouteStats can answer from memory. The samples cover
+// HTTPRouteStatsWindow and are taken every tenth of it, at least every
+// second. It is started automatically when HTTPRouteStatsWindow is set.
+func (m *Metrics) StartRouteStats(ctx context.Context) error {
+	if m.httpMetrics == nil {
+		return fmt.Errorf("HTTP metrics are disabled")
+	}
+	window := m.config.HTTPRouteStatsWindow
+	if window <= 0 {
+		return fmt.Errorf("HTTPRouteStatsWindow is not configured")
+	}
+	t := &routeStatsTracker{window: window}
+
+	m.mu.Lock()
+	m.routeStats = t
+	m.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(max(window/10, time.Second))
+		defer ticker.Stop()
+
+		for {
+			t.add(m.sampleRoutes(time.Now()))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// RouteStats returns the recent stats of a route, e.g. for an adaptive rate
+// limiter to throttle by the same numbers operators see on dashboards:
+//
+//	if stats, ok := m.RouteStats("POST", "/orders"); ok && stats.ErrorRate > 0.05 {
+//	    limiter.SetLimit(limiter.Limit() / 2)
+//	}
+//
+// route is the route template, e.g. "/users/:id". It reports false until
+// two samples were taken or if the route has no requests in the window.
+func (m *Metrics) RouteStats(method, route string) (RouteStats, bool) {
+	m.mu.RLock()
+	t := m.routeStats
+	m.mu.RUnlock()
+	if t == nil {
+		return RouteStats{}, false
+	}
+
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	if len(t.samples) < 2 {
+		return RouteStats{}, false
+	}
+	oldest, newest := t.samples[0], t.samples[len(t.samples)-1]
+	key := routeKey{method, route}
+	current, ok := newest.routes[key]
+	if !ok {
+		return RouteStats{}, false
+	}
+	last, ok := oldest.routes[key]
+	if !ok {
+		// The route had no requests at the start of the window
+		last = &routeTotals{}
+	}
+
+	requests := current.requests - last.requests
+	if requests <= 0 {
+		return RouteStats{}, false
+	}
+	elapsed := newest.at.Sub(oldest.at)
+	stats := RouteStats{
+		Requests:  requests / elapsed.Seconds(),
+		ErrorRate: max(current.errors-last.errors, 0) / requests,
+		Window:    elapsed,
+	}
+	if p95, ok := deltaQuantile(current.buckets, last.buckets, 0.95); ok {
+		seconds := p95 / m.config.DurationUnit.fromSeconds(1)
+		stats.P95 = time.Duration(seconds * float64(time.Second))
+	}
+	return stats, true
+}
+
+// sampleRoutes collects the request counts and durations of all routes
+func (m *Metrics) sampleRoutes(now time.Time) routeSample {
+	sample := routeSample{at: now, routes: make(map[routeKey]*routeTotals)}
+	totals := func(pm *dto.Metric) *routeTotals {
+		labels := labelMap(pm)
+		key := routeKey{labels["method"], labels["path"]}
+		t, ok := sample.routes[key]
+		if !ok {
+			t = &routeTotals{buckets: make(map[float64]uint64)}
+			sample.routes[key] = t
+		}
+		return t
+	}
+
+	for _, pm := range collectMetrics(m.httpMetrics.RequestsTotal) {
+		t := totals(pm)
+		t.requests += pm.GetCounter().GetValue()
+		if serverError(labelMap(pm)) {
+			t.errors += pm.GetCounter().GetValue()
+		}
+	}
+	for _, pm := range collectMetrics(m.httpMetrics.RequestDuration) {
+		t := totals(pm)
+		for _, b := range pm.GetHistogram().GetBucket() {
+			t.buckets[b.GetUpperBound()] += b.GetCumulativeCount()
+		}
+		t.buckets[math.Inf(1)] += pm.GetHistogram().GetSampleCount()
+	}
+	return sample
+}
+
+// add appends a sample and drops those that left the window, keeping the
+// newest sample at least window old as the start
+func (t *routeStatsTracker) add(sample routeSample) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.samples = append(t.samples, sample)
+	for len(t.samples) > 2 && sample.at.Sub(t.samples[1].at) >= t.window {
+		t.samples = t.samples[1:]
+	}
+}
+
+// collectMetrics returns the series of a collector as protobuf metrics
+func collectMetrics(c prometheus.Collector) []*dto.Metric {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		c.Collect(ch)
+		close(ch)
+	}()
+
+	var metrics []*dto.Metric
+	for metric := range ch {
+		var pm dto.Metric
+		if err := metric.Write(&pm); err == nil {
+			metrics = append(metrics, &pm)
+		}
+	}
+	return metrics
+}
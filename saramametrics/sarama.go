@@ -0,0 +1,75 @@
+// Package saramametrics wires metrics.KafkaMetrics into IBM/sarama clients
+// through producer and consumer interceptors.
+//
+//	km := m.NewKafkaMetrics()
+//	config := sarama.NewConfig()
+//	config.Producer.Interceptors = []sarama.ProducerInterceptor{saramametrics.NewProducerInterceptor(km)}
+//	config.Consumer.Interceptors = []sarama.ConsumerInterceptor{saramametrics.NewConsumerInterceptor(km)}
+package saramametrics
+
+import (
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/OkanUysal/go-metrics"
+)
+
+// ProducerInterceptor counts produced messages
+type ProducerInterceptor struct {
+	km *metrics.KafkaMetrics
+}
+
+// NewProducerInterceptor creates a sarama producer interceptor
+func NewProducerInterceptor(km *metrics.KafkaMetrics) *ProducerInterceptor {
+	return &ProducerInterceptor{km: km}
+}
+
+// OnSend implements sarama.ProducerInterceptor
+func (i *ProducerInterceptor) OnSend(msg *sarama.ProducerMessage) {
+	i.km.MessageProduced(msg.Topic, messageSize(msg.Key, msg.Value))
+}
+
+// ConsumerInterceptor counts consumed messages and records consume latency
+type ConsumerInterceptor struct {
+	km *metrics.KafkaMetrics
+}
+
+// NewConsumerInterceptor creates a sarama consumer interceptor
+func NewConsumerInterceptor(km *metrics.KafkaMetrics) *ConsumerInterceptor {
+	return &ConsumerInterceptor{km: km}
+}
+
+// OnConsume implements sarama.ConsumerInterceptor
+func (i *ConsumerInterceptor) OnConsume(msg *sarama.ConsumerMessage) {
+	i.km.MessageConsumed(msg.Topic, len(msg.Key)+len(msg.Value))
+	if !msg.Timestamp.IsZero() {
+		i.km.ConsumeLatency(msg.Topic, time.Since(msg.Timestamp).Seconds())
+	}
+}
+
+// RecordClaimLag sets the consumer lag of a claimed partition after msg was
+// processed. Call it from ConsumerGroupHandler.ConsumeClaim.
+func RecordClaimLag(km *metrics.KafkaMetrics, claim sarama.ConsumerGroupClaim, msg *sarama.ConsumerMessage) {
+	lag := claim.HighWaterMarkOffset() - msg.Offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	km.SetConsumerLag(claim.Topic(), claim.Partition(), float64(lag))
+}
+
+// messageSize returns the encoded size of a message key and value
+func messageSize(key, value sarama.Encoder) int {
+	size := 0
+	if key != nil {
+		size += key.Length()
+	}
+	if value != nil {
+		size += value.Length()
+	}
+	return size
+}
+
+var (
+	_ sarama.ProducerInterceptor = (*ProducerInterceptor)(nil)
+	_ sarama.ConsumerInterceptor = (*ConsumerInterceptor)(nil)
+)
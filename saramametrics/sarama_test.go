@@ -0,0 +1,71 @@
+package saramametrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/IBM/sarama"
+	"github.com/OkanUysal/go-metrics"
+	"github.com/OkanUysal/go-metrics/metricstest"
+)
+
+// fakeClaim is a claimed partition with a fixed high water mark
+type fakeClaim struct {
+	highWaterMark int64
+}
+
+func (fakeClaim) Topic() string                            { return "orders" }
+func (fakeClaim) Partition() int32                         { return 3 }
+func (fakeClaim) InitialOffset() int64                     { return 0 }
+func (c fakeClaim) HighWaterMarkOffset() int64             { return c.highWaterMark }
+func (fakeClaim) Messages() <-chan *sarama.ConsumerMessage { return nil }
+
+func TestProducerInterceptor(t *testing.T) {
+	m := metrics.New(metrics.WithoutHTTPMetrics())
+	i := NewProducerInterceptor(m.NewKafkaMetrics())
+
+	i.OnSend(&sarama.ProducerMessage{Topic: "orders", Key: sarama.StringEncoder("id-1"), Value: sarama.ByteEncoder("{}")})
+	i.OnSend(&sarama.ProducerMessage{Topic: "orders", Value: sarama.StringEncoder("hello")})
+	i.OnSend(&sarama.ProducerMessage{Topic: "orders"})
+
+	orders := metrics.MetricLabels{"topic": "orders"}
+	metricstest.AssertCounterValue(t, m, "kafka_messages_produced_total", orders, 3)
+	// Keys and values without encoder count as empty
+	metricstest.AssertCounterValue(t, m, "kafka_produced_bytes_total", orders, 4+2+5)
+}
+
+func TestConsumerInterceptor(t *testing.T) {
+	m := metrics.New(metrics.WithoutHTTPMetrics())
+	i := NewConsumerInterceptor(m.NewKafkaMetrics())
+
+	i.OnConsume(&sarama.ConsumerMessage{Topic: "orders", Key: []byte("id-1"), Value: []byte("{}"), Timestamp: time.Now().Add(-time.Second)})
+	// Messages without timestamp, e.g. from old brokers, have no latency
+	i.OnConsume(&sarama.ConsumerMessage{Topic: "orders", Value: []byte("hello")})
+
+	orders := metrics.MetricLabels{"topic": "orders"}
+	metricstest.AssertCounterValue(t, m, "kafka_messages_consumed_total", orders, 2)
+	metricstest.AssertCounterValue(t, m, "kafka_consumed_bytes_total", orders, 4+2+5)
+	metricstest.AssertHistogramCount(t, m, "kafka_consume_latency_seconds", orders, 1)
+}
+
+func TestRecordClaimLag(t *testing.T) {
+	tests := []struct {
+		name          string
+		highWaterMark int64
+		offset        int64
+		want          float64
+	}{
+		{"behind", 100, 89, 10},
+		{"caught up", 100, 99, 0},
+		// The high water mark is fetched separately and may be stale
+		{"stale high water mark", 100, 120, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := metrics.New(metrics.WithoutHTTPMetrics())
+			RecordClaimLag(m.NewKafkaMetrics(), fakeClaim{tt.highWaterMark}, &sarama.ConsumerMessage{Offset: tt.offset})
+
+			metricstest.AssertGaugeValue(t, m, "kafka_consumer_lag", metrics.MetricLabels{"topic": "orders", "partition": "3"}, tt.want)
+		})
+	}
+}
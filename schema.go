@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"fmt"
+	"slices"
+)
+
+// checkSchema returns an error unless labelKeys match the label keys the
+// metric was created with. Both key slices must be sorted.
+func checkSchema(schema, labelKeys []string) error {
+	if slices.Equal(schema, labelKeys) {
+		return nil
+	}
+	return fmt.Errorf("%w: want %v, got %v", ErrLabelMismatch, schema, labelKeys)
+}
@@ -0,0 +1,90 @@
+package metrics
+
+import (
+	"maps"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WithSubsystem returns a child scope whose custom metrics are prefixed with
+// subsystem, e.g. myapp_payments_orders_total. A subsystem already set on m
+// is kept as prefix, so scopes can be nested.
+//
+// Scopes share the registry, HTTP metrics and health checks of m, so modules
+// of a monolith can namespace their metrics without separate registries.
+func (m *Metrics) WithSubsystem(subsystem string) *Metrics {
+	config := *m.config
+	if config.Subsystem != "" && subsystem != "" {
+		subsystem = config.Subsystem + "_" + subsystem
+	} else if subsystem == "" {
+		subsystem = config.Subsystem
+	}
+	config.Subsystem = subsystem
+	return m.scope(&config)
+}
+
+// WithConstLabels returns a child scope adding labels to all its custom
+// metrics, merged with the constant labels of m.
+//
+// A metric name must not be used both with and without the added labels, as
+// the registry requires consistent label names per metric.
+func (m *Metrics) WithConstLabels(labels MetricLabels) *Metrics {
+	config := *m.config
+	config.ConstLabels = maps.Clone(m.config.ConstLabels)
+	if config.ConstLabels == nil {
+		config.ConstLabels = make(prometheus.Labels, len(labels))
+	}
+	maps.Copy(config.ConstLabels, labels)
+	return m.scope(&config)
+}
+
+// scope creates a child sharing everything but its custom metrics with m.
+// Children have no event log, as replayed events would lose their scope.
+func (m *Metrics) scope(config *Config) *Metrics {
+	sanitizeConfig(config)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	child := &Metrics{
+		config:      config,
+		registry:    m.registry,
+		httpMetrics: m.httpMetrics,
+		health:      m.health,
+		counters:    make(map[string]*prometheus.CounterVec),
+		gauges:      make(map[string]*prometheus.GaugeVec),
+		histograms:  make(map[string]*prometheus.HistogramVec),
+		internal:    m.internal,
+		frozen:      m.frozen,
+		cardinality: newCardinalityTracker(),
+		labelEnums:  newLabelEnums(),
+		renames:     m.renames,
+		routes:      m.routes,
+		events:      newEventSchemas(),
+		chaos:       m.chaos,
+		history:     m.history,
+		anomalies:   m.anomalies,
+		routeStats:  m.routeStats,
+		slo:         m.slo,
+		ttl:         m.ttl,
+		labelKeys:   make(map[string]bool),
+		schemas:     make(map[string][]string),
+		lookup:      &metricLookup{},
+		team:        m.team,
+		owners:      m.owners,
+		secrets:     m.secrets,
+		errLog:      newErrorLog(),
+
+		grafanaClock:      m.grafanaClock,
+		wal:               m.wal,
+		failover:          m.failover,
+		gaugeSampler:      m.gaugeSampler,
+		remoteWriteStates: m.remoteWriteStates,
+		gaugeHistograms:   m.gaugeHistograms,
+		pushers:           m.pushers,
+	}
+	if child.frozen {
+		child.frozenRejected = make(map[string]bool)
+	}
+	return child
+}
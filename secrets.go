@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// secretFiles caches credentials read from files, such as Kubernetes secret
+// mounts, re-reading a file when its modification time or size changes
+type secretFiles struct {
+	mu    sync.Mutex
+	files map[string]secretFile // By path
+}
+
+// secretFile is the last content read from a secret file
+type secretFile struct {
+	value   string
+	modTime time.Time
+	size    int64
+}
+
+// newSecretFiles creates an empty secret file cache
+func newSecretFiles() *secretFiles {
+	return &secretFiles{files: make(map[string]secretFile)}
+}
+
+// read returns the trimmed content of the secret file at path, reading it
+// again only if it changed. Kubernetes updates secret mounts by swapping a
+// symlink, which Stat follows.
+func (s *secretFiles) read(path string) (value string, reloaded bool, err error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.files[path]
+	if ok && f.modTime.Equal(info.ModTime()) && f.size == info.Size() {
+		return f.value, false, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read secret file: %w", err)
+	}
+	value = strings.TrimSpace(string(data))
+	s.files[path] = secretFile{value: value, modTime: info.ModTime(), size: info.Size()}
+	return value, ok && value != f.value, nil
+}
+
+// values returns the current content of all secret files
+func (s *secretFiles) values() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values := make([]string, 0, len(s.files))
+	for _, f := range s.files {
+		values = append(values, f.value)
+	}
+	return values
+}
+
+// credentials returns target with User and APIKey read from UserFile and
+// APIKeyFile if set
+func (m *Metrics) credentials(target RemoteWriteTarget) (RemoteWriteTarget, error) {
+	for _, secret := range []struct {
+		path  string
+		value *string
+	}{
+		{target.UserFile, &target.User},
+		{target.APIKeyFile, &target.APIKey},
+	} {
+		if secret.path == "" {
+			continue
+		}
+		value, reloaded, err := m.secrets.read(secret.path)
+		if err != nil {
+			return target, err
+		}
+		if reloaded {
+			m.logf("Reloaded push credentials from %s", secret.path)
+		}
+		*secret.value = value
+	}
+	return target, nil
+}
+
+// minSecretLength is the length below which configured values are not
+// redacted, as they would match ordinary words of a message
+const minSecretLength = 4
+
+// urlPassword matches the password in the user info of a URL
+var urlPassword = regexp.MustCompile(`(://[^/\s:@]*:)[^/\s@]+@`)
+
+// redact replaces the configured credentials, and passwords in URLs, in a
+// message of the push pipeline by "[REDACTED]"
+func (m *Metrics) redact(message string) string {
+	secrets := append(m.secrets.values(), m.config.GrafanaCloudAPIKey, m.config.FallbackRemoteWrite.APIKey, m.config.PrometheusPassword)
+	for _, target := range m.config.TeamRemoteWrite {
+		secrets = append(secrets, target.APIKey)
+	}
+	for _, target := range m.config.RemoteWrite {
+		secrets = append(secrets, target.APIKey)
+		for _, value := range target.Headers {
+			secrets = append(secrets, value)
+		}
+	}
+	for _, value := range m.config.OTLPHeaders {
+		secrets = append(secrets, value)
+	}
+
+	for _, secret := range secrets {
+		if len(secret) >= minSecretLength {
+			message = strings.ReplaceAll(message, secret, "[REDACTED]")
+		}
+	}
+	return urlPassword.ReplaceAllString(message, "${1}[REDACTED]@")
+}
+
+// redactError returns err with credentials redacted from its message, see
+// redact. The original error can still be matched with errors.Is.
+func (m *Metrics) redactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	message := m.redact(err.Error())
+	if message == err.Error() {
+		return err
+	}
+	return &redactedError{message: message, err: err}
+}
+
+// redactedError is an error whose message had credentials removed
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string { return e.message }
+
+func (e *redactedError) Unwrap() error { return e.err }
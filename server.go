@@ -0,0 +1,100 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// StartServer starts a dedicated HTTP server on addr exposing /metrics,
+// /health and /ready according to EnableMetricsEndpoint and
+// EnableHealthEndpoint.
+// The server is shut down gracefully when ctx is cancelled.
+//
+// StartServer returns once the listener is bound; listen errors are returned
+// directly, serve errors are logged.
+func (m *Metrics) StartServer(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	if m.config.EnableMetricsEndpoint {
+		mux.Handle("/metrics", m.Handler())
+	}
+	if m.config.EnableHealthEndpoint {
+		mux.Handle("/health", m.HealthHandler())
+		mux.Handle("/ready", m.ReadyHandler())
+	}
+
+	readTimeout := m.config.ServerReadTimeout
+	if readTimeout == 0 {
+		readTimeout = 5 * time.Second
+	}
+	writeTimeout := m.config.ServerWriteTimeout
+	if writeTimeout == 0 {
+		writeTimeout = 10 * time.Second
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadTimeout:       readTimeout,
+		ReadHeaderTimeout: readTimeout,
+		WriteTimeout:      writeTimeout,
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	useTLS := m.config.ServerTLSCertFile != "" && m.config.ServerTLSKeyFile != ""
+
+	go func() {
+		var err error
+		if useTLS {
+			err = server.ServeTLS(listener, m.config.ServerTLSCertFile, m.config.ServerTLSKeyFile)
+		} else {
+			err = server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			m.logf("Metrics server error: %v", err)
+		}
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			m.logf("Failed to shut down metrics server: %v", err)
+		}
+	}()
+
+	return nil
+}
+
+// HealthHandler returns a net/http handler for the /health endpoint
+func (m *Metrics) HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := m.health.Check(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(report.HTTPStatus())
+		json.NewEncoder(w).Encode(report)
+	})
+}
+
+// ReadyHandler returns a net/http handler for the /ready endpoint
+func (m *Metrics) ReadyHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := m.health.Check(r.Context())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(report.ReadyStatus())
+		json.NewEncoder(w).Encode(report)
+	})
+}
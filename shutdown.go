@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// pusher periodically pushes the registry to one remote target
+type pusher struct {
+	target string
+	push   func(ctx context.Context) error
+	close  func() // Optional, releases the connection after the last push
+	stop   context.CancelFunc
+	done   chan struct{}
+	closed bool // Set by the loop if it exited because its context was cancelled
+
+	mu sync.Mutex // Serializes pushes
+}
+
+// pushLoops are the running push loops, shared with scopes so Shutdown on
+// any of them flushes all
+type pushLoops struct {
+	mu    sync.Mutex
+	loops []*pusher
+}
+
+// startPusher pushes to a target every PushInterval until ctx is cancelled
+// or Shutdown is called. Pushes use ctx, so Shutdown lets an in-flight push
+// complete.
+func (m *Metrics) startPusher(ctx context.Context, target string, push func(context.Context) error, closeFn func()) {
+	interval := m.config.PushInterval
+	if interval == 0 {
+		interval = 15 * time.Second
+	}
+
+	stopCtx, stop := context.WithCancel(ctx)
+	p := &pusher{
+		target: target,
+		push:   push,
+		close:  closeFn,
+		stop:   stop,
+		done:   make(chan struct{}),
+	}
+
+	m.pushers.mu.Lock()
+	m.pushers.loops = append(m.pushers.loops, p)
+	m.pushers.mu.Unlock()
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			// Push immediately on start
+			if err := p.run(ctx); err != nil && ctx.Err() == nil {
+				m.pushFailed(target, err)
+			}
+
+			select {
+			case <-stopCtx.Done():
+				if ctx.Err() != nil {
+					p.release()
+				}
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// pushing reports whether a push loop to target is running
+func (m *Metrics) pushing(target string) bool {
+	m.pushers.mu.Lock()
+	defer m.pushers.mu.Unlock()
+
+	for _, p := range m.pushers.loops {
+		select {
+		case <-p.done:
+		default:
+			if p.target == target {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// run performs a single push, waiting for any push in progress
+func (p *pusher) run(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.push(ctx)
+}
+
+// release closes the connection of the pusher, if any
+func (p *pusher) release() {
+	p.closed = true
+	if p.close != nil {
+		p.close()
+	}
+}
+
+// Shutdown stops all push loops started by StartGrafanaPush and
+// StartOTLPPush, waits for in-flight pushes to complete and then pushes the
+// registry one last time, so data recorded since the previous push is not
+// lost on exit. Push loops whose context was cancelled are skipped. Call it
+// before the process exits; ctx bounds the final pushes.
+func (m *Metrics) Shutdown(ctx context.Context) error {
+	m.pushers.mu.Lock()
+	pushers := m.pushers.loops
+	m.pushers.loops = nil
+	m.pushers.mu.Unlock()
+
+	var errs []error
+	for _, p := range pushers {
+		p.stop()
+		<-p.done
+		if p.closed {
+			continue
+		}
+
+		if err := p.run(ctx); err != nil {
+			m.pushFailed(p.target, err)
+			errs = append(errs, err)
+		}
+		p.release()
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,78 @@
+package metrics
+
+// checkSimilarNames warns when a new metric name or label key is a likely typo
+// of an existing one. It only runs in dev mode and must be called with m.mu held.
+func (m *Metrics) checkSimilarNames(name string, labelKeys []string) {
+	if !m.config.DevMode {
+		return
+	}
+
+	for _, existing := range m.metricNames() {
+		if existing != name && similar(name, existing) {
+			m.logf("metrics: new metric %q is similar to existing metric %q, possible typo", name, existing)
+		}
+	}
+
+	for _, key := range labelKeys {
+		if m.labelKeys[key] {
+			continue
+		}
+		for existing := range m.labelKeys {
+			if similar(key, existing) {
+				m.logf("metrics: new label key %q on %q is similar to existing label key %q, possible typo", key, name, existing)
+			}
+		}
+		m.labelKeys[key] = true
+	}
+}
+
+// metricNames returns the names of all custom metrics. Must be called with m.mu held.
+func (m *Metrics) metricNames() []string {
+	names := make([]string, 0, len(m.counters)+len(m.gauges)+len(m.histograms))
+	for name := range m.counters {
+		names = append(names, name)
+	}
+	for name := range m.gauges {
+		names = append(names, name)
+	}
+	for name := range m.histograms {
+		names = append(names, name)
+	}
+	return names
+}
+
+// similar reports whether two distinct names are within a small edit distance
+func similar(a, b string) bool {
+	if a == b {
+		return false
+	}
+
+	maxDistance := 2
+	if len(a) < 8 || len(b) < 8 {
+		maxDistance = 1
+	}
+	return editDistance(a, b) <= maxDistance
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
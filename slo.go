@@ -0,0 +1,140 @@
+package metrics
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// SLO declares a request success objective for a route. Once added with
+// AddSLO, the HTTP middleware counts good and bad events for matching
+// requests without further call sites.
+type SLO struct {
+	// Name identifies the SLO in the slo label (defaults to Route)
+	Name string
+
+	// Route is the route template as registered with Gin, e.g. "/api/match"
+	Route string
+
+	// Method restricts the SLO to one HTTP method (empty matches all)
+	Method string
+
+	// Objective is the target percentage of good events, e.g. 99.9
+	Objective float64
+
+	// IsBad reports whether a response status is a bad event
+	// (defaults to 5xx status codes)
+	IsBad func(status int) bool
+}
+
+// sloTracker holds the declared SLOs and their metrics
+type sloTracker struct {
+	slos       []SLO
+	events     *prometheus.CounterVec
+	objectives *prometheus.GaugeVec
+	registered bool
+	mu         sync.RWMutex
+}
+
+// AddSLO declares an SLO whose good and bad events are fed by the HTTP
+// middleware from response status codes.
+//
+// The following metrics are exposed per SLO:
+//   - slo_events_total{slo, result}: result is "good" or "bad"
+//   - slo_objective_ratio{slo}: the objective as a ratio, e.g. 0.999
+//
+// The remaining error budget can then be computed in PromQL.
+func (m *Metrics) AddSLO(slo SLO) error {
+	if slo.Route == "" {
+		return fmt.Errorf("SLO route is required")
+	}
+	if slo.Objective <= 0 || slo.Objective >= 100 {
+		return fmt.Errorf("SLO %q: objective must be between 0 and 100, got %v", slo.Route, slo.Objective)
+	}
+	if slo.Name == "" {
+		slo.Name = slo.Route
+	}
+	if slo.IsBad == nil {
+		slo.IsBad = func(status int) bool { return status >= 500 }
+	}
+
+	t := m.slo
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, existing := range t.slos {
+		if existing.Name == slo.Name {
+			return fmt.Errorf("SLO %q is already declared", slo.Name)
+		}
+	}
+
+	if !t.registered {
+		if err := m.registry.Register(t.events); err != nil {
+			return fmt.Errorf("failed to register SLO metrics: %w", err)
+		}
+		if err := m.registry.Register(t.objectives); err != nil {
+			m.registry.Unregister(t.events)
+			return fmt.Errorf("failed to register SLO metrics: %w", err)
+		}
+		t.registered = true
+	}
+
+	t.slos = append(t.slos, slo)
+	t.objectives.WithLabelValues(slo.Name).Set(slo.Objective / 100)
+
+	// Expose both series from the start so ratios are defined
+	t.events.WithLabelValues(slo.Name, "good")
+	t.events.WithLabelValues(slo.Name, "bad")
+
+	return nil
+}
+
+// newSLOTracker creates an empty tracker whose metrics are registered with
+// the first SLO
+func (m *Metrics) newSLOTracker() *sloTracker {
+	return &sloTracker{
+		events: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "slo_events_total",
+				Help:        "Good and bad events per SLO",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"slo", "result"},
+		),
+		objectives: prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "slo_objective_ratio",
+				Help:        "Objective of each SLO as a ratio of good events",
+				ConstLabels: m.config.ConstLabels,
+			},
+			[]string{"slo"},
+		),
+	}
+}
+
+// recordSLO counts a completed request against every matching SLO
+func (m *Metrics) recordSLO(method, route string, status int) {
+	if route == "" {
+		return
+	}
+
+	t := m.slo
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	for _, slo := range t.slos {
+		if slo.Route != route || (slo.Method != "" && slo.Method != method) {
+			continue
+		}
+		result := "good"
+		if slo.IsBad(status) {
+			result = "bad"
+		}
+		t.events.WithLabelValues(slo.Name, result).Inc()
+	}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"maps"
+	"strings"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// MetricSnapshot is the state of one metric at the time of Snapshot
+type MetricSnapshot struct {
+	Name    string           `json:"name"`
+	Help    string           `json:"help"`
+	Type    string           `json:"type"` // counter, gauge, histogram, gaugehistogram, summary or untyped
+	Samples []SnapshotSample `json:"samples"`
+}
+
+// SnapshotSample is the state of one series. Counters, gauges and untyped
+// metrics set Value; histograms and summaries set Count, Sum and their
+// buckets or quantiles.
+type SnapshotSample struct {
+	Labels    map[string]string  `json:"labels,omitempty"`
+	Value     float64            `json:"value"`
+	Count     uint64             `json:"count,omitempty"`
+	Sum       float64            `json:"sum,omitempty"`
+	Buckets   []SnapshotBucket   `json:"buckets,omitempty"`
+	Quantiles []SnapshotQuantile `json:"quantiles,omitempty"`
+}
+
+// SnapshotBucket is a cumulative histogram bucket
+type SnapshotBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// SnapshotQuantile is a summary quantile
+type SnapshotQuantile struct {
+	Quantile float64 `json:"quantile"`
+	Value    float64 `json:"value"`
+}
+
+// Snapshot gathers all metrics into a map keyed by fully-qualified name, e.g.
+// to expose them over an admin RPC, log them on crash or assert on them in
+// integration tests. On gather errors, everything that could be collected is
+// returned along with the error.
+func (m *Metrics) Snapshot() (map[string]MetricSnapshot, error) {
+	families, err := m.gatherer().Gather()
+
+	snapshot := make(map[string]MetricSnapshot, len(families))
+	for _, mf := range families {
+		samples := make([]SnapshotSample, 0, len(mf.GetMetric()))
+		for _, pm := range mf.GetMetric() {
+			samples = append(samples, snapshotSample(mf.GetType(), pm))
+		}
+		snapshot[mf.GetName()] = MetricSnapshot{
+			Name:    mf.GetName(),
+			Help:    mf.GetHelp(),
+			Type:    strings.ToLower(strings.ReplaceAll(mf.GetType().String(), "_", "")),
+			Samples: samples,
+		}
+	}
+	return snapshot, err
+}
+
+// Sample returns the series with exactly labels, including any ConstLabels
+func (s MetricSnapshot) Sample(labels map[string]string) (SnapshotSample, bool) {
+	for _, sample := range s.Samples {
+		if maps.Equal(sample.Labels, labels) {
+			return sample, true
+		}
+	}
+	return SnapshotSample{}, false
+}
+
+// snapshotSample converts a gathered series
+func snapshotSample(typ dto.MetricType, pm *dto.Metric) SnapshotSample {
+	sample := SnapshotSample{Labels: labelMap(pm)}
+	if len(sample.Labels) == 0 {
+		sample.Labels = nil
+	}
+
+	switch typ {
+	case dto.MetricType_COUNTER:
+		sample.Value = pm.GetCounter().GetValue()
+	case dto.MetricType_GAUGE:
+		sample.Value = pm.GetGauge().GetValue()
+	case dto.MetricType_UNTYPED:
+		sample.Value = pm.GetUntyped().GetValue()
+	case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+		h := pm.GetHistogram()
+		sample.Count = h.GetSampleCount()
+		sample.Sum = h.GetSampleSum()
+		for _, b := range h.GetBucket() {
+			sample.Buckets = append(sample.Buckets, SnapshotBucket{UpperBound: b.GetUpperBound(), Count: b.GetCumulativeCount()})
+		}
+	case dto.MetricType_SUMMARY:
+		s := pm.GetSummary()
+		sample.Count = s.GetSampleCount()
+		sample.Sum = s.GetSampleSum()
+		for _, q := range s.GetQuantile() {
+			sample.Quantiles = append(sample.Quantiles, SnapshotQuantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+		}
+	}
+	return sample
+}
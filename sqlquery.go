@@ -0,0 +1,179 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// SQLQuery maps the rows of a read-only query to gauges, e.g.
+//
+//	SQLQuery{
+//		Name:   "orders_by_status",
+//		Query:  "SELECT status, count(*) AS n FROM orders GROUP BY status",
+//		Labels: []string{"status"},
+//		Values: map[string]string{"orders": "n"},
+//	}
+//
+// exports orders{status="..."} with one series per row
+type SQLQuery struct {
+	Name     string            // Identifies the query in logs and sql_query_* metrics
+	Query    string            // Run in a read-only transaction
+	Args     []any             // Query parameters
+	Labels   []string          // Columns exported as labels
+	Values   map[string]string // Gauge name to the column holding its value
+	Interval time.Duration     // Time between runs (defaults to 1m)
+	Timeout  time.Duration     // Per-run timeout (defaults to Interval)
+}
+
+// CollectQuery runs a query against db every Interval until ctx is cancelled
+// and exports its rows as gauges. Series of rows that disappeared are
+// deleted; NULL values are skipped. Failed runs are counted in
+// sql_query_errors_total and run time is recorded as
+// sql_query_duration_seconds.
+func (dm *DatabaseMetrics) CollectQuery(ctx context.Context, db *sql.DB, q SQLQuery) error {
+	if q.Name == "" || q.Query == "" || len(q.Values) == 0 {
+		return fmt.Errorf("SQL query %q: Name, Query and Values are required", q.Name)
+	}
+	if q.Interval <= 0 {
+		q.Interval = time.Minute
+	}
+	if q.Timeout <= 0 {
+		q.Timeout = q.Interval
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(q.Values)) {
+		help := fmt.Sprintf("Column %s of SQL query %s", q.Values[name], q.Name)
+		if err := dm.m.RegisterGauge(name, help, q.Labels); err != nil {
+			return err
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(q.Interval)
+		defer ticker.Stop()
+
+		var last []MetricLabels
+		for {
+			last = dm.runQuery(ctx, db, q, last)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// runQuery runs a query once and exports its rows. It returns the label
+// sets exported, or last on failure so the previous values are kept.
+func (dm *DatabaseMetrics) runQuery(ctx context.Context, db *sql.DB, q SQLQuery, last []MetricLabels) []MetricLabels {
+	ctx, cancel := context.WithTimeout(ctx, q.Timeout)
+	defer cancel()
+
+	queryLabels := MetricLabels{"query": q.Name}
+
+	start := time.Now()
+	rows, err := dm.queryRows(ctx, db, q)
+	dm.m.recordDuration("sql_query_duration", time.Since(start).Seconds(), queryLabels)
+	if err != nil {
+		dm.m.IncrementCounter("sql_query_errors_total", queryLabels)
+		dm.m.logf("Failed to run SQL query %s: %v", q.Name, err)
+		return last
+	}
+
+	seen := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		seen[seriesKey(row.labels)] = true
+		for name, value := range row.values {
+			dm.m.SetGauge(name, value, row.labels)
+		}
+	}
+
+	// Delete the series of rows that are gone
+	for _, labels := range last {
+		if !seen[seriesKey(labels)] {
+			for name := range q.Values {
+				dm.m.DeleteSeries(name, labels)
+			}
+		}
+	}
+
+	exported := make([]MetricLabels, len(rows))
+	for i, row := range rows {
+		exported[i] = row.labels
+	}
+	return exported
+}
+
+// queryRow is a row of a query mapped to labels and gauge values
+type queryRow struct {
+	labels MetricLabels
+	values map[string]float64
+}
+
+// queryRows runs a query in a read-only transaction and maps its rows
+func (dm *DatabaseMetrics) queryRows(ctx context.Context, db *sql.DB, q SQLQuery) ([]queryRow, error) {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, q.Query, q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	index := make(map[string]int, len(columns))
+	for i, column := range columns {
+		index[column] = i
+	}
+	for _, column := range append(slices.Clone(q.Labels), slices.Collect(maps.Values(q.Values))...) {
+		if _, ok := index[column]; !ok {
+			return nil, fmt.Errorf("column %q not in result", column)
+		}
+	}
+
+	var result []queryRow
+	cells := make([]sql.NullString, len(columns))
+	dest := make([]any, len(columns))
+	for i := range cells {
+		dest[i] = &cells[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		row := queryRow{labels: make(MetricLabels, len(q.Labels)), values: make(map[string]float64, len(q.Values))}
+		for _, column := range q.Labels {
+			row.labels[column] = cells[index[column]].String
+		}
+		for name, column := range q.Values {
+			cell := cells[index[column]]
+			if !cell.Valid {
+				continue
+			}
+			value, err := strconv.ParseFloat(cell.String, 64)
+			if err != nil {
+				return nil, fmt.Errorf("column %q: %w", column, err)
+			}
+			row.values[name] = value
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
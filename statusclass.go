@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Values of the error_type label added by HTTPErrorType
+const (
+	ErrorTypeTimeout  = "timeout"  // The request deadline expired, or the status is 408 or 504
+	ErrorTypeCanceled = "canceled" // The client went away before the response, or the status is 499
+	ErrorTypePanic    = "panic"    // The handler panicked, see MiddlewareOptions.Panics
+)
+
+// requestLabelNames returns the label names of request counts and durations
+func (m *Metrics) requestLabelNames() []string {
+	names := []string{"method", "path", "status"}
+	if m.config.HTTPStatusClass {
+		names = append(names, "status_class")
+	}
+	if m.config.HTTPErrorType {
+		names = append(names, "error_type")
+	}
+	return names
+}
+
+// requestLabelValues returns the label values of request counts and
+// durations, in requestLabelNames order
+func (m *Metrics) requestLabelValues(method, path, status string, code int, errorType string) []string {
+	values := []string{method, path, status}
+	if m.config.HTTPStatusClass {
+		values = append(values, statusClass(code))
+	}
+	if m.config.HTTPErrorType {
+		values = append(values, errorType)
+	}
+	return values
+}
+
+// statusClass returns the class of a status code, e.g. "4xx"
+func statusClass(code int) string {
+	if code < 100 || code > 599 {
+		return "unknown"
+	}
+	return strconv.Itoa(code/100) + "xx"
+}
+
+// requestErrorType classifies a failed request by its context, the errors
+// attached with c.Error and its status. It returns "" for other requests.
+func requestErrorType(c *gin.Context) string {
+	err := c.Request.Context().Err()
+	for _, e := range c.Errors {
+		if err != nil {
+			break
+		}
+		if errors.Is(e.Err, context.DeadlineExceeded) || errors.Is(e.Err, context.Canceled) {
+			err = e.Err
+		}
+	}
+
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorTypeTimeout
+	case errors.Is(err, context.Canceled):
+		return ErrorTypeCanceled
+	}
+
+	switch c.Writer.Status() {
+	case http.StatusRequestTimeout, http.StatusGatewayTimeout:
+		return ErrorTypeTimeout
+	case 499: // Client closed request, as logged by nginx
+		return ErrorTypeCanceled
+	}
+	return ""
+}
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RoundTripper wraps next (http.DefaultTransport if nil) so that outbound
+// requests to a third-party provider are measured. Standard rate-limit
+// response headers are parsed into remaining-quota gauges so quota
+// exhaustion is visible before requests start failing.
+//
+//	client := &http.Client{Transport: m.RoundTripper("github", nil)}
+func (m *Metrics) RoundTripper(provider string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &roundTripper{m: m, provider: provider, next: next}
+}
+
+// roundTripper records metrics of outbound requests
+type roundTripper struct {
+	m        *Metrics
+	provider string
+	next     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper
+func (rt *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := rt.next.RoundTrip(req)
+	elapsed := time.Since(start)
+	duration := elapsed.Seconds()
+	AddRequestTime(req.Context(), ComponentExternal, elapsed)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+
+	rt.m.IncrementCounter("http_client_requests_total", MetricLabels{
+		"provider": rt.provider,
+		"method":   req.Method,
+		"status":   status,
+	})
+	rt.m.recordDuration("http_client_request_duration", duration, MetricLabels{
+		"provider": rt.provider,
+		"method":   req.Method,
+	})
+
+	if err == nil {
+		rt.recordRateLimit(resp.Header, start)
+	}
+	return resp, err
+}
+
+// recordRateLimit exports the quota advertised by X-RateLimit-* or the
+// IETF RateLimit-* response headers
+func (rt *roundTripper) recordRateLimit(h http.Header, now time.Time) {
+	labels := MetricLabels{"provider": rt.provider}
+
+	if limit, ok := rateLimitHeader(h, "Limit"); ok {
+		rt.m.SetGauge("http_client_ratelimit_limit", limit, labels)
+	}
+	if remaining, ok := rateLimitHeader(h, "Remaining"); ok {
+		rt.m.SetGauge("http_client_ratelimit_remaining", remaining, labels)
+	}
+	if reset, ok := rateLimitHeader(h, "Reset"); ok {
+		// Values beyond a year of seconds are Unix timestamps, smaller
+		// values are seconds until the reset
+		if reset > 365*24*60*60 {
+			reset = time.Unix(int64(reset), 0).Sub(now).Seconds()
+			if reset < 0 {
+				reset = 0
+			}
+		}
+		rt.m.SetGauge("http_client_ratelimit_reset_seconds", reset, labels)
+	}
+}
+
+// rateLimitHeader parses the X-RateLimit-<field> or RateLimit-<field> header
+func rateLimitHeader(h http.Header, field string) (float64, bool) {
+	v := h.Get("X-RateLimit-" + field)
+	if v == "" {
+		v = h.Get("RateLimit-" + field)
+	}
+	if v == "" {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 {
+		return 0, false
+	}
+	return f, true
+}
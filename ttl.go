@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ttlTracker remembers when each series of a metric with a TTL was last
+// updated. It is shared by a Metrics and its scopes, so one sweeper expires
+// the series of all of them.
+type ttlTracker struct {
+	updated map[ttlMetric]map[string]ttlSeries
+	mu      sync.Mutex
+}
+
+// ttlMetric identifies a custom metric of a scope
+type ttlMetric struct {
+	scope *Metrics
+	name  string
+}
+
+// ttlSeries is the last update of a series
+type ttlSeries struct {
+	labels  MetricLabels
+	updated time.Time
+}
+
+// newTTLTracker creates an empty tracker
+func newTTLTracker() *ttlTracker {
+	return &ttlTracker{
+		updated: make(map[ttlMetric]map[string]ttlSeries),
+	}
+}
+
+// seriesLabels applies the declared label values and the cardinality limit
+// to labels and records the update of the resulting series for TTL expiry
+func (m *Metrics) seriesLabels(name string, labels MetricLabels) prometheus.Labels {
+	labels = m.enforceLabelValues(name, labels)
+	labels = m.limitCardinality(name, labels)
+	m.touchSeries(name, labels)
+	return prometheus.Labels(labels)
+}
+
+// touchSeries records an update of a series if its metric has a TTL
+func (m *Metrics) touchSeries(name string, labels MetricLabels) {
+	if _, ok := m.config.SeriesTTL[name]; !ok {
+		return
+	}
+
+	t := m.ttl
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := ttlMetric{scope: m, name: name}
+	series, ok := t.updated[key]
+	if !ok {
+		series = make(map[string]ttlSeries)
+		t.updated[key] = series
+	}
+	series[seriesKey(labels)] = ttlSeries{labels: labels, updated: time.Now()}
+}
+
+// startSeriesSweeper deletes expired series until ctx is cancelled. Series
+// are checked every half of the shortest TTL, but at most once a second.
+func (m *Metrics) startSeriesSweeper(ctx context.Context) {
+	interval := time.Duration(0)
+	for _, ttl := range m.config.SeriesTTL {
+		if interval == 0 || ttl/2 < interval {
+			interval = ttl / 2
+		}
+	}
+	interval = max(interval, time.Second)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				m.sweepSeries(now)
+			}
+		}
+	}()
+}
+
+// sweepSeries deletes all series of m and its scopes not updated within
+// their metric's TTL and returns how many were deleted
+func (m *Metrics) sweepSeries(now time.Time) int {
+	t := m.ttl
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Deleting under the lock keeps a concurrent update from being lost
+	deleted := 0
+	for metric, series := range t.updated {
+		ttl := metric.scope.config.SeriesTTL[metric.name]
+		for key, s := range series {
+			if now.Sub(s.updated) < ttl {
+				continue
+			}
+			delete(series, key)
+			if metric.scope.deleteSeries(metric.name, s.labels) {
+				deleted++
+			}
+		}
+		// Releases scopes that are no longer used
+		if len(series) == 0 {
+			delete(t.updated, metric)
+		}
+	}
+	return deleted
+}
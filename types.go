@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"fmt"
+	"io"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -9,26 +11,183 @@ import (
 // Config contains metrics configuration
 type Config struct {
 	ServiceName string // Service name for metrics
+	Environment string // Environment profile: "dev", "staging" or "prod" (see Profiles)
 	Namespace   string // Prometheus namespace (e.g., "outcome")
 	Subsystem   string // Prometheus subsystem (optional)
 
 	// HTTP metrics configuration
-	EnableHTTPMetrics     bool
-	HTTPBuckets           []float64 // Custom histogram buckets for HTTP duration
-	EnableMetricsEndpoint bool      // Auto-register /metrics endpoint
-	EnableHealthEndpoint  bool      // Auto-register /health endpoint
+	EnableHTTPMetrics      bool
+	HTTPBuckets            []float64         // Custom histogram buckets for HTTP duration
+	HTTPSampleRate         float64           // Fraction of requests observed in HTTP histograms, which scales their _count and _sum (0 means all)
+	HTTPMetricSchema       HTTPMetricSchema  // Legacy (default), OpenTelemetry or both
+	DurationUnit           DurationUnit      // Unit of duration histograms; buckets are always given in seconds
+	HTTPInFlightPerRoute   bool              // Also track in-flight requests per registered route
+	DeploymentTrackEnv     string            // Env variable whose value labels HTTP metrics as deployment_track, e.g. "DEPLOYMENT_TRACK"
+	HTTPPrecreateSeries    bool              // Create request series of all routes of the Setup router at zero (see PrecreateHTTPSeries)
+	HTTPRouteWatchInterval time.Duration     // Check the Setup router for added routes this often (see WatchRoutes)
+	HTTPRouteStatsWindow   time.Duration     // Keep recent per-route request, error and latency stats in memory (see RouteStats)
+	HTTPMetricsFilter      HTTPMetricsFilter // Requests excluded from HTTP metrics by every middleware
+	HTTPStatusClass        bool              // Add a status_class label ("2xx" to "5xx") to request counts and durations
+	HTTPErrorType          bool              // Add an error_type label ("timeout", "canceled", "panic" or empty) to request counts and durations
+	HTTPTimeBreakdown      bool              // Break request durations down into DB, cache, external and app time per route (see AddRequestTime)
+	EnableMetricsEndpoint  bool              // Auto-register /metrics endpoint
+	EnableHealthEndpoint   bool              // Auto-register /health endpoint
+
+	// Client retry tracking (see IdempotencyKeyHeader)
+	HTTPTrackRetries     bool          // Count retried requests and repeated idempotency keys
+	RetryHeaders         []string      // Headers marking a retry (defaults to DefaultRetryHeaders)
+	IdempotencyKeyWindow time.Duration // How long idempotency keys are remembered (defaults to 10m)
+
+	// Health check configuration
+	HealthCheckTimeout     time.Duration // Per-check timeout for /health (defaults to 5s)
+	HealthCheckInterval    time.Duration // Minimum time between check runs, whose report is shared by all probes (defaults to 5s)
+	HealthFailureThreshold int           // Consecutive failures of a critical check before /ready fails (defaults to 3)
+
+	// Metrics server configuration (optional, see StartServer)
+	ServerReadTimeout  time.Duration // Defaults to 5s
+	ServerWriteTimeout time.Duration // Defaults to 10s
+	ServerTLSCertFile  string        // Serve over TLS when both cert and key are set
+	ServerTLSKeyFile   string
 
 	// Push gateway configuration (optional)
 	PushGatewayURL string
 	PushInterval   time.Duration
 
+	// Gauges are sampled every PushSampleInterval between remote write pushes
+	// and pushed as <name>_min and <name>_max next to the last value, so
+	// spikes between pushes show in graphs of long PushIntervals (optional)
+	PushSampleInterval time.Duration
+
 	// Grafana Cloud configuration (optional)
-	GrafanaCloudURL    string
-	GrafanaCloudUser   string
-	GrafanaCloudAPIKey string
+	GrafanaCloudURL        string
+	GrafanaCloudUser       string
+	GrafanaCloudAPIKey     string
+	GrafanaCloudUserFile   string                       // Read GrafanaCloudUser from a file instead, e.g. a Kubernetes secret mount
+	GrafanaCloudAPIKeyFile string                       // Read GrafanaCloudAPIKey from a file instead; files are re-read when they change
+	ExternalLabels         map[string]string            // Labels added to pushed series; job and instance default to ServiceName and the hostname, "" removes one
+	GrafanaCloudRelabel    []RelabelRule                // Filter and rewrite the series pushed to GrafanaCloudURL; /metrics still serves all series
+	TeamRemoteWrite        map[string]RemoteWriteTarget // Remote write targets of the metrics owned by a team (see WithTeam); other metrics go to GrafanaCloudURL
+	RemoteWrite            []RemoteWriteTarget          // Further targets receiving all metrics, e.g. an internal Mimir, with or without GrafanaCloudURL
+	RemoteWriteV2          bool                         // Push with Remote-Write 2.0; endpoints rejecting it with 415 fall back to 1.0
+
+	// Warm standby for GrafanaCloudURL (optional), e.g. a tenant in another
+	// region. Pushes fail over after FailoverAfter consecutive failures (defaults
+	// to 3) and the primary is probed every FailbackInterval (defaults to 1m).
+	FallbackRemoteWrite RemoteWriteTarget
+	FailoverAfter       int
+	FailbackInterval    time.Duration
+
+	// Disk-backed queue of remote write pushes (optional). Pushes are written
+	// to PushWALDir and sent oldest first, so samples recorded while Grafana
+	// Cloud is unreachable survive a restart, e.g. of a spot instance.
+	PushWALDir      string
+	PushWALMaxBytes int64 // Disk budget; the oldest pushes are dropped beyond it (defaults to 256MB)
+
+	// Prometheus query API for QueryRemote (optional)
+	PrometheusURL      string // e.g. "http://prometheus:9090"
+	PrometheusUser     string // Basic auth, e.g. for Grafana Cloud
+	PrometheusPassword string
+
+	// OpenTelemetry OTLP export configuration (optional, see StartOTLPPush)
+	OTLPEndpoint string            // e.g. "http://collector:4318" or "collector:4317" for grpc
+	OTLPProtocol string            // "http/protobuf" (default) or "grpc"
+	OTLPHeaders  map[string]string // Extra headers, e.g. authentication
+	OTLPInsecure bool              // Disable TLS for grpc
+
+	// Clock skew detection (optional, see StartClockSkewCheck)
+	NTPServer    string        // e.g. "pool.ntp.org"
+	MaxClockSkew time.Duration // Skew logged as a warning (defaults to 1s)
+
+	// In-process history of selected metrics (see History)
+	HistoryMetrics  []string      // Metric names to keep, e.g. "http_requests_total"
+	HistoryWindow   time.Duration // How far back samples are kept (defaults to 10m)
+	HistoryInterval time.Duration // Sampling interval (defaults to 15s)
+	EnableDashboard bool          // Serve /metrics/ui with sparklines of key metrics from the history
+
+	// CurrencyRates normalizes the amounts of the payment helpers of
+	// BusinessMetrics to USD, adding payment_amount_usd and refund_amount_usd
+	// histograms and a revenue_usd_total counter next to the native-currency
+	// counters
+	CurrencyRates CurrencyRateFunc
+
+	// Anomaly detection on own metrics (optional, see StartAnomalyDetection)
+	AnomalyMetrics  []string      // Signals to watch, e.g. AnomalyHTTPErrorRate or a metric name
+	AnomalyInterval time.Duration // Sampling interval (defaults to 15s)
+	AnomalySigma    float64       // Deviation from the baseline in standard deviations reported as an anomaly (defaults to 3)
+	AnomalyAlpha    float64       // Weight of a new sample in the EWMA baseline, between 0 and 1 (defaults to 0.1)
+
+	// Debug dumps of all metrics on SIGUSR1 or SIGQUIT (optional, see DumpOnSignal)
+	DebugDumpOnSignal bool   // Listen for the signals
+	DebugDumpPath     string // File the dump is written to (defaults to stderr)
+
+	// Goroutine leak detection (optional, see StartGoroutineLeakDetector)
+	GoroutineSampleInterval time.Duration // Time between goroutine samples (defaults to 1m)
+
+	// Optional append-only log of metric operations, see Replay
+	EventLog io.Writer
+
+	// Namespace of counters recorded with IncrementCounterAt (defaults to
+	// Namespace + "_replay")
+	ReplayNamespace string
+
+	// Cardinality limits for custom metrics. Label combinations beyond the
+	// limit are collapsed into a single series with all values set to "other".
+	MaxSeriesPerMetric int            // Default limit per metric (0 = unlimited)
+	CardinalityLimits  map[string]int // Per-metric overrides of MaxSeriesPerMetric
+
+	// SeriesTTL deletes series of the given metrics that were not updated
+	// within the TTL, e.g. gauges keyed by ephemeral IDs such as room_id
+	SeriesTTL map[string]time.Duration
+
+	// MetricRenames maps old to new names of custom metrics during a rename.
+	// Calls using an old name record the new metric and are counted in
+	// metrics_renamed_usage_total, and every renamed metric is also exported
+	// under its old name until MetricRenamesUntil (forever if zero), so
+	// dashboards can be migrated before the old name disappears.
+	MetricRenames      map[string]string
+	MetricRenamesUntil time.Time
 
 	// Custom labels for all metrics
 	ConstLabels prometheus.Labels
+
+	// Logger receives warnings and errors (defaults to stdout)
+	Logger Logger
+
+	// EnableSelfProfiling records the time spent inside the library per
+	// request as metrics_overhead_seconds
+	EnableSelfProfiling bool
+
+	// EnableFailureInjection makes FailureInjector available to simulate
+	// push failures, gather errors and slow collectors (testing only)
+	EnableFailureInjection bool
+
+	// DevMode enables development checks such as metric name typo warnings
+	DevMode bool
+
+	// RecoverPanics makes Go and RecoverPanic log the panics they count
+	// instead of re-raising them
+	RecoverPanics bool
+
+	// PanicOnMetricError makes failed updates of custom metrics, such as
+	// label key mismatches, panic instead of being logged and dropped
+	PanicOnMetricError bool
+
+	// StrictNames makes updates and registrations with metric names or label
+	// keys outside [a-zA-Z_:][a-zA-Z0-9_:]* fail with ErrInvalidName instead
+	// of having the invalid characters replaced by "_" (see SanitizeName)
+	StrictNames bool
+}
+
+// Logger is the minimal logging interface used by Metrics
+type Logger interface {
+	Printf(format string, args ...any)
+}
+
+// stdoutLogger is the default Logger
+type stdoutLogger struct{}
+
+func (stdoutLogger) Printf(format string, args ...any) {
+	fmt.Printf(format+"\n", args...)
 }
 
 // DefaultConfig returns default configuration
@@ -52,6 +211,24 @@ type HTTPMetrics struct {
 	RequestSize      *prometheus.HistogramVec
 	ResponseSize     *prometheus.HistogramVec
 	RequestsInFlight prometheus.Gauge
+
+	// HandlerPanics counts handler panics recovered by the middleware
+	HandlerPanics *prometheus.CounterVec
+
+	// RouteRequestsInFlight is set when HTTPInFlightPerRoute is enabled
+	RouteRequestsInFlight *prometheus.GaugeVec
+
+	// WebSocket records upgrade handshakes instead of regular request metrics
+	WebSocket *WebSocketUpgradeMetrics
+
+	// TimeBreakdown is set when HTTPTimeBreakdown is enabled
+	TimeBreakdown *prometheus.HistogramVec
+
+	// retries is set when HTTPTrackRetries is enabled
+	retries *retryTracker
+
+	// OTel is set when HTTPMetricSchema includes OpenTelemetry names
+	OTel *OTelHTTPMetrics
 }
 
 // Labels contains common label keys
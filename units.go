@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DurationUnit selects the unit of duration histograms
+type DurationUnit int
+
+const (
+	// DurationSeconds records durations in seconds with a _seconds suffix (default)
+	DurationSeconds DurationUnit = iota
+	// DurationMilliseconds records durations in milliseconds with a _milliseconds suffix
+	DurationMilliseconds
+)
+
+// suffix returns the metric name suffix of the unit
+func (u DurationUnit) suffix() string {
+	if u == DurationMilliseconds {
+		return "_milliseconds"
+	}
+	return "_seconds"
+}
+
+// fromSeconds converts a duration in seconds to the unit
+func (u DurationUnit) fromSeconds(seconds float64) float64 {
+	if u == DurationMilliseconds {
+		return seconds * 1000
+	}
+	return seconds
+}
+
+// buckets converts histogram buckets given in seconds to the unit
+func (u DurationUnit) buckets(seconds []float64) []float64 {
+	if u != DurationMilliseconds {
+		return seconds
+	}
+
+	scaled := make([]float64, len(seconds))
+	for i, b := range seconds {
+		scaled[i] = b * 1000
+	}
+	return scaled
+}
+
+// defaultBuckets returns the default buckets for a custom histogram.
+// Histograms named *_milliseconds get the default buckets in milliseconds.
+func defaultBuckets(name string) []float64 {
+	if strings.HasSuffix(name, "_milliseconds") {
+		return DurationMilliseconds.buckets(prometheus.DefBuckets)
+	}
+	return prometheus.DefBuckets
+}
+
+// recordDuration records a duration given in seconds into the histogram
+// base+unit suffix, e.g. "match_duration" becomes match_duration_seconds or
+// match_duration_milliseconds depending on Config.DurationUnit
+func (m *Metrics) recordDuration(base string, seconds float64, labels MetricLabels) {
+	unit := m.config.DurationUnit
+	m.RecordHistogram(base+unit.suffix(), unit.fromSeconds(seconds), labels)
+}
+
+// RecordDuration records d into the duration histogram name plus the unit
+// suffix of Config.DurationUnit, converting it to that unit, e.g.
+// "export_duration" becomes export_duration_seconds or
+// export_duration_milliseconds. A name already ending in the suffix is kept.
+// Prefer it over RecordHistogram with a float64, whose unit is easy to get
+// wrong:
+//
+//	start := time.Now()
+//	export()
+//	m.RecordDuration("export_duration", time.Since(start), nil)
+func (m *Metrics) RecordDuration(name string, d time.Duration, labels MetricLabels) {
+	m.recordDuration(strings.TrimSuffix(name, m.config.DurationUnit.suffix()), d.Seconds(), labels)
+}
@@ -0,0 +1,111 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// WebSocketUpgradeMetrics records WebSocket upgrade handshakes, which are
+// kept out of the regular HTTP request metrics because their handlers run
+// for the lifetime of the connection
+type WebSocketUpgradeMetrics struct {
+	Attempts          *prometheus.CounterVec
+	Failures          *prometheus.CounterVec
+	HandshakeDuration *prometheus.HistogramVec
+}
+
+// newWebSocketUpgradeMetrics creates the upgrade metrics
+func (m *Metrics) newWebSocketUpgradeMetrics() *WebSocketUpgradeMetrics {
+	constLabels := m.httpConstLabels()
+
+	return &WebSocketUpgradeMetrics{
+		Attempts: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "websocket_upgrade_attempts_total",
+				Help:        "WebSocket upgrade requests",
+				ConstLabels: constLabels,
+			},
+			[]string{"path"},
+		),
+		Failures: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "websocket_upgrade_failures_total",
+				Help:        "WebSocket upgrade requests that were not upgraded",
+				ConstLabels: constLabels,
+			},
+			[]string{"path", "status"},
+		),
+		HandshakeDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Namespace:   m.config.Namespace,
+				Subsystem:   m.config.Subsystem,
+				Name:        "websocket_handshake_duration" + m.config.DurationUnit.suffix(),
+				Help:        "Time until a WebSocket upgrade succeeded",
+				Buckets:     m.config.DurationUnit.buckets(m.config.HTTPBuckets),
+				ConstLabels: constLabels,
+			},
+			[]string{"path"},
+		),
+	}
+}
+
+// collectors returns all upgrade metrics for registration
+func (u *WebSocketUpgradeMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{u.Attempts, u.Failures, u.HandshakeDuration}
+}
+
+// isUpgradeRequest reports whether r asks for a WebSocket upgrade
+func isUpgradeRequest(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") &&
+		headerContainsToken(r.Header, "Connection", "upgrade")
+}
+
+// headerContainsToken reports whether a comma separated header contains token
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serveUpgrade runs the handler of an upgrade request, recording the
+// handshake once the connection is hijacked or the handler fails to upgrade
+func (m *Metrics) serveUpgrade(c *gin.Context, path string) {
+	u := m.httpMetrics.WebSocket
+	u.Attempts.WithLabelValues(path).Inc()
+
+	start := time.Now()
+	var once sync.Once
+	finish := func(upgraded bool, status int) {
+		once.Do(func() {
+			if upgraded {
+				u.HandshakeDuration.WithLabelValues(path).Observe(m.config.DurationUnit.fromSeconds(time.Since(start).Seconds()))
+				return
+			}
+			u.Failures.WithLabelValues(path, strconv.Itoa(status)).Inc()
+		})
+	}
+
+	writer := newResponseWriter(c.Writer)
+	writer.onHijack = func() { finish(true, http.StatusSwitchingProtocols) }
+	c.Writer = writer
+
+	c.Next()
+
+	status := writer.Status()
+	finish(status == http.StatusSwitchingProtocols, status)
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import "sync"
+
+// maxMessageVersions bounds the version label when no versions are declared
+const maxMessageVersions = 10
+
+// MessageVersionMetrics counts the schema or protocol versions clients use
+// per message type, so old versions can be deprecated once unused
+type MessageVersionMetrics struct {
+	m *Metrics
+
+	known map[string]bool // Declared versions, or those seen first
+	fixed bool            // Set if versions were declared
+	mu    sync.Mutex
+}
+
+// NewMessageVersionMetrics creates message version metrics helper. Versions
+// other than known are counted as "other" to keep the version label bounded.
+// Without known versions, the first 10 distinct versions seen are kept.
+func (m *Metrics) NewMessageVersionMetrics(known ...string) *MessageVersionMetrics {
+	vm := &MessageVersionMetrics{
+		m:     m,
+		known: make(map[string]bool, len(known)),
+		fixed: len(known) > 0,
+	}
+	for _, version := range known {
+		vm.known[version] = true
+	}
+	return vm
+}
+
+// MessageReceived counts a message of the given type and schema version in
+// message_versions_total
+func (vm *MessageVersionMetrics) MessageReceived(messageType, version string) {
+	vm.m.IncrementCounter("message_versions_total", MetricLabels{
+		"type":    messageType,
+		"version": vm.label(version),
+	})
+}
+
+// label returns the version label of a version
+func (vm *MessageVersionMetrics) label(version string) string {
+	vm.mu.Lock()
+	defer vm.mu.Unlock()
+
+	if vm.known[version] {
+		return version
+	}
+	if !vm.fixed && len(vm.known) < maxMessageVersions {
+		vm.known[version] = true
+		return version
+	}
+	return "other"
+}
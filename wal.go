@@ -0,0 +1,428 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// defaultWALMaxBytes is the default disk budget of the push WAL
+	defaultWALMaxBytes = 256 << 20
+
+	// walMagic starts every WAL record of a Remote-Write 1.0 request,
+	// followed by the CRC32 of the rest
+	walMagic = "GMW1"
+
+	// walMagicV2 starts WAL records of Remote-Write 2.0 requests
+	walMagicV2 = "GMW2"
+
+	walSuffix = ".wal"
+)
+
+var walTable = crc32.MakeTable(crc32.Castagnoli)
+
+// pushWAL is a write-ahead log of remote write requests on disk, one file
+// per request named by sequence number, so samples survive a process that
+// is killed while the endpoint is unreachable. Files are only removed once
+// sent, giving at-least-once delivery.
+type pushWAL struct {
+	dir      string
+	maxBytes int64
+
+	mu       sync.Mutex
+	segments []walSegment // Oldest first
+	bytes    int64
+	next     uint64
+	dropped  map[string]float64 // By reason
+
+	drainMu sync.Mutex // Serializes drains, so records are sent once
+
+	bytesDesc   *prometheus.Desc
+	recordsDesc *prometheus.Desc
+	droppedDesc *prometheus.Desc
+}
+
+// walSegment is a queued request on disk
+type walSegment struct {
+	path string
+	url  string // Target, so requests can be skipped without reading them
+	size int64
+}
+
+// initPushWAL opens the WAL in PushWALDir, logging an error and pushing
+// directly if it cannot be opened
+func (m *Metrics) initPushWAL() {
+	w, err := m.openPushWAL()
+	if err != nil {
+		m.logf("Failed to open push WAL, pushing without it: %v", err)
+		return
+	}
+	m.wal = w
+	m.registry.MustRegister(w)
+}
+
+// openPushWAL opens or creates the WAL in PushWALDir. Files left by writes
+// interrupted by a crash are removed.
+func (m *Metrics) openPushWAL() (*pushWAL, error) {
+	dir := m.config.PushWALDir
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create WAL directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read WAL directory: %w", err)
+	}
+
+	maxBytes := m.config.PushWALMaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultWALMaxBytes
+	}
+	fqName := func(name string) string {
+		return prometheus.BuildFQName(m.config.Namespace, m.config.Subsystem, name)
+	}
+	w := &pushWAL{
+		dir:         dir,
+		maxBytes:    maxBytes,
+		dropped:     make(map[string]float64),
+		bytesDesc:   prometheus.NewDesc(fqName("metrics_wal_bytes"), "Disk space used by queued pushes", nil, m.config.ConstLabels),
+		recordsDesc: prometheus.NewDesc(fqName("metrics_wal_records"), "Pushes queued on disk", nil, m.config.ConstLabels),
+		droppedDesc: prometheus.NewDesc(fqName("metrics_wal_dropped_total"), "Queued pushes dropped without being sent, by reason", []string{"reason"}, m.config.ConstLabels),
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(dir, name)
+		if strings.HasSuffix(name, walSuffix+".tmp") {
+			os.Remove(path)
+			w.dropped["corrupt"]++
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, walSuffix), 10, 64)
+		if err != nil || !strings.HasSuffix(name, walSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		url, err := readWALURL(path)
+		if err != nil {
+			m.logf("Dropping corrupt queued push %s: %v", path, err)
+			os.Remove(path)
+			w.dropped["corrupt"]++
+			continue
+		}
+		w.segments = append(w.segments, walSegment{path: path, url: url, size: info.Size()})
+		w.bytes += info.Size()
+		w.next = max(w.next, seq+1)
+	}
+	// Zero-padded names sort by sequence
+	slices.SortFunc(w.segments, func(a, b walSegment) int { return strings.Compare(a.path, b.path) })
+
+	if len(w.segments) > 0 {
+		m.logf("Recovered %d queued pushes from %s", len(w.segments), dir)
+	}
+	return w, nil
+}
+
+// queueRemoteWrite appends the remote write request of metric families for
+// target to the WAL. Only the URL and team are stored; credentials are
+// looked up when the request is sent.
+func (m *Metrics) queueRemoteWrite(target RemoteWriteTarget, metricFamilies []*dto.MetricFamily, at time.Time) error {
+	relabel, err := target.relabeler()
+	if err != nil {
+		return err
+	}
+	body, err := encodeRemoteWrite(metricFamilies, m.externalLabels(), at, relabel, m.remoteWriteV2(target))
+	if err != nil {
+		return err
+	}
+	return m.wal.append(target.key(), body)
+}
+
+// drainPushWAL sends the queued requests of every target concurrently, each
+// oldest first. A target stops at its first failure so its requests are
+// retried by the next push in order, while the requests of other targets
+// are still sent.
+func (m *Metrics) drainPushWAL(ctx context.Context) error {
+	w := m.wal
+	w.drainMu.Lock()
+	defer w.drainMu.Unlock()
+
+	queues := make(map[string][]walSegment) // By URL
+	for _, segment := range w.pending() {
+		queues[segment.url] = append(queues[segment.url], segment)
+	}
+
+	urls := slices.Sorted(maps.Keys(queues))
+	sent := make([]int, len(urls))
+	errs := make([]error, len(urls))
+	var wg sync.WaitGroup
+	for i, url := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sent[i], errs[i] = m.drainWALTarget(ctx, url, queues[url])
+		}()
+	}
+	wg.Wait()
+
+	total := 0
+	for _, n := range sent {
+		total += n
+	}
+	if total > 0 {
+		m.logf("Successfully pushed %d queued requests", total)
+	}
+	return errors.Join(errs...)
+}
+
+// drainWALTarget sends the queued requests of url in order until one fails,
+// leaving the rest unread. As with direct pushes, nothing is sent while the
+// target backs off after failures. Requests that cannot be read or that the
+// endpoint rejects permanently are dropped.
+func (m *Metrics) drainWALTarget(ctx context.Context, url string, segments []walSegment) (sent int, err error) {
+	w := m.wal
+	target, ok := m.remoteWriteTarget(url)
+	if !ok {
+		m.logf("Dropping %d queued pushes to %s, which is no longer configured", len(segments), m.redact(url))
+		for _, segment := range segments {
+			w.remove(segment, "unknown_target")
+		}
+		return 0, nil
+	}
+	state := m.remoteWriteStates.get(target)
+	if !state.due() {
+		return 0, nil
+	}
+
+	for _, segment := range segments {
+		_, body, err := readWALRecord(segment.path)
+		if errors.Is(err, os.ErrNotExist) {
+			// Dropped for the disk budget meanwhile
+			continue
+		}
+		if err != nil {
+			m.logf("Dropping corrupt queued push %s: %v", segment.path, err)
+			w.remove(segment, "corrupt")
+			continue
+		}
+
+		if err := m.sendRemoteWrite(ctx, target, body); err != nil {
+			var rejected *remoteWriteError
+			if errors.As(err, &rejected) && rejected.permanent() {
+				m.logf("Dropping queued push rejected by %s: %v", m.redact(url), m.redactError(err))
+				w.remove(segment, "rejected")
+				continue
+			}
+			state.failed()
+			return sent, fmt.Errorf("%s: %w", target.name(), err)
+		}
+		w.remove(segment, "")
+		sent++
+	}
+	state.succeeded()
+	return sent, nil
+}
+
+// remoteWriteTarget returns the configured target with key
+func (m *Metrics) remoteWriteTarget(key string) (RemoteWriteTarget, bool) {
+	if grafana, ok := m.grafanaTarget(); ok && grafana.key() == key {
+		return grafana, true
+	}
+	for team, target := range m.config.TeamRemoteWrite {
+		target.team = team
+		if target.key() == key {
+			return target, true
+		}
+	}
+	for _, target := range m.config.RemoteWrite {
+		if target.key() == key {
+			return target, true
+		}
+	}
+	return RemoteWriteTarget{}, false
+}
+
+// append writes a request to a new WAL file, then drops the oldest requests
+// beyond the disk budget. The file is synced and renamed into place, so a
+// crash leaves either the whole record or a temporary file.
+func (w *pushWAL) append(url string, body remoteWriteBody) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	path := filepath.Join(w.dir, fmt.Sprintf("%020d%s", w.next, walSuffix))
+	record := encodeWALRecord(url, body)
+	if err := writeFileSync(path, record); err != nil {
+		return fmt.Errorf("failed to queue push: %w", err)
+	}
+	w.next++
+	w.segments = append(w.segments, walSegment{path: path, url: url, size: int64(len(record))})
+	w.bytes += int64(len(record))
+
+	// The newest request is kept even if it alone exceeds the budget
+	for w.bytes > w.maxBytes && len(w.segments) > 1 {
+		w.removeLocked(w.segments[0], "disk_limit")
+	}
+	return nil
+}
+
+// pending returns the queued requests, oldest first
+func (w *pushWAL) pending() []walSegment {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return slices.Clone(w.segments)
+}
+
+// remove deletes a request that was sent, or dropped for reason
+func (w *pushWAL) remove(segment walSegment, reason string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.removeLocked(segment, reason)
+}
+
+// removeLocked is remove with w.mu held. Requests already dropped for the
+// disk budget are ignored.
+func (w *pushWAL) removeLocked(segment walSegment, reason string) {
+	i := slices.Index(w.segments, segment)
+	if i < 0 {
+		return
+	}
+	os.Remove(segment.path)
+	w.segments = slices.Delete(w.segments, i, i+1)
+	w.bytes -= segment.size
+	if reason != "" {
+		w.dropped[reason]++
+	}
+}
+
+// Describe implements prometheus.Collector
+func (w *pushWAL) Describe(ch chan<- *prometheus.Desc) {
+	ch <- w.bytesDesc
+	ch <- w.recordsDesc
+	ch <- w.droppedDesc
+}
+
+// Collect implements prometheus.Collector
+func (w *pushWAL) Collect(ch chan<- prometheus.Metric) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(w.bytesDesc, prometheus.GaugeValue, float64(w.bytes))
+	ch <- prometheus.MustNewConstMetric(w.recordsDesc, prometheus.GaugeValue, float64(len(w.segments)))
+	for _, reason := range []string{"corrupt", "disk_limit", "rejected", "unknown_target"} {
+		ch <- prometheus.MustNewConstMetric(w.droppedDesc, prometheus.CounterValue, w.dropped[reason], reason)
+	}
+}
+
+// encodeWALRecord encodes a request as magic, CRC32, URL length, URL and
+// compressed request. The magic identifies the protocol version.
+func encodeWALRecord(url string, body remoteWriteBody) []byte {
+	var payload bytes.Buffer
+	binary.Write(&payload, binary.BigEndian, uint16(len(url)))
+	payload.WriteString(url)
+	payload.Write(body.compressed)
+
+	magic := walMagic
+	if body.v2 {
+		magic = walMagicV2
+	}
+	record := make([]byte, 0, len(magic)+4+payload.Len())
+	record = append(record, magic...)
+	record = binary.BigEndian.AppendUint32(record, crc32.Checksum(payload.Bytes(), walTable))
+	return append(record, payload.Bytes()...)
+}
+
+// readWALURL reads only the URL of a record written by encodeWALRecord. The
+// checksum is verified when the whole record is read.
+func readWALURL(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	header := make([]byte, len(walMagic)+4+2)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return "", fmt.Errorf("invalid header")
+	}
+	if magic := string(header[:len(walMagic)]); magic != walMagic && magic != walMagicV2 {
+		return "", fmt.Errorf("invalid header")
+	}
+	url := make([]byte, binary.BigEndian.Uint16(header[len(walMagic)+4:]))
+	if _, err := io.ReadFull(f, url); err != nil {
+		return "", fmt.Errorf("truncated URL")
+	}
+	return string(url), nil
+}
+
+// readWALRecord reads and verifies a record written by encodeWALRecord
+func readWALRecord(path string) (url string, body remoteWriteBody, err error) {
+	record, err := os.ReadFile(path)
+	if err != nil {
+		return "", body, err
+	}
+	if len(record) < len(walMagic)+4+2 {
+		return "", body, fmt.Errorf("invalid header")
+	}
+	switch string(record[:len(walMagic)]) {
+	case walMagic:
+	case walMagicV2:
+		body.v2 = true
+	default:
+		return "", body, fmt.Errorf("invalid header")
+	}
+
+	checksum := binary.BigEndian.Uint32(record[len(walMagic):])
+	payload := record[len(walMagic)+4:]
+	if crc32.Checksum(payload, walTable) != checksum {
+		return "", body, fmt.Errorf("checksum mismatch")
+	}
+
+	n := int(binary.BigEndian.Uint16(payload))
+	if len(payload) < 2+n {
+		return "", body, fmt.Errorf("truncated URL")
+	}
+	body.compressed = payload[2+n:]
+	return string(payload[2 : 2+n]), body, nil
+}
+
+// writeFileSync writes data to path atomically and durably
+func writeFileSync(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,191 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// windowSamples caps the values kept per series and window for the p95;
+// beyond it a uniform sample of the window is kept
+const windowSamples = 1024
+
+// HistogramWindow summarizes the observations of one histogram series during
+// one window
+type HistogramWindow struct {
+	Name   string
+	Labels MetricLabels
+	Count  uint64
+	Min    float64
+	Max    float64
+	Avg    float64
+	P95    float64
+}
+
+// histogramWindows collects the observations of custom histograms since the
+// last rotation
+type histogramWindows struct {
+	mu     sync.Mutex
+	series map[string]*histogramWindow
+}
+
+// histogramWindow is the working window of one series
+type histogramWindow struct {
+	name     string
+	labels   MetricLabels
+	count    uint64
+	sum      float64
+	min, max float64
+	samples  []float64
+}
+
+// StartHistogramWindows summarizes custom histograms every PushInterval
+// until ctx is cancelled, for push-only backends such as StatsD or
+// CloudWatch that cannot ingest buckets. Each interval the min, max, average
+// and p95 of every series observed since the last interval are passed to
+// export, then the window is reset. The p95 is computed from up to 1024
+// values per series and window, sampled uniformly beyond that.
+//
+// With a nil export, the summaries are published as gauges instead, e.g.
+// job_duration_seconds_window_p95, and series without observations in a
+// window are deleted.
+func (m *Metrics) StartHistogramWindows(ctx context.Context, export func([]HistogramWindow)) error {
+	w := &histogramWindows{series: make(map[string]*histogramWindow)}
+	if !m.windows.CompareAndSwap(nil, w) {
+		return fmt.Errorf("histogram windows are already started")
+	}
+
+	go func() {
+		ticker := time.NewTicker(m.config.PushInterval)
+		defer ticker.Stop()
+
+		var published []HistogramWindow
+		for {
+			select {
+			case <-ctx.Done():
+				m.windows.Store(nil)
+				return
+			case <-ticker.C:
+			}
+
+			windows := w.rotate()
+			if export != nil {
+				export(windows)
+			} else {
+				m.publishWindows(windows, published)
+				published = windows
+			}
+		}
+	}()
+
+	return nil
+}
+
+// publishWindows sets the summary gauges of windows and deletes those of the
+// series that were published last time but not observed since
+func (m *Metrics) publishWindows(windows, last []HistogramWindow) {
+	seen := make(map[string]bool, len(windows))
+	for _, w := range windows {
+		seen[w.Name+"\xff"+seriesKey(w.Labels)] = true
+		for suffix, value := range map[string]float64{"min": w.Min, "max": w.Max, "avg": w.Avg, "p95": w.P95} {
+			name := w.Name + "_window_" + suffix
+			m.handleError(name, m.setGauge(name, value, w.Labels))
+		}
+	}
+
+	for _, w := range last {
+		if !seen[w.Name+"\xff"+seriesKey(w.Labels)] {
+			for _, suffix := range []string{"min", "max", "avg", "p95"} {
+				m.DeleteSeries(w.Name+"_window_"+suffix, w.Labels)
+			}
+		}
+	}
+}
+
+// observer wraps the observer of a histogram series to also record into the
+// working window
+func (w *histogramWindows) observer(name string, labels prometheus.Labels, o prometheus.Observer) prometheus.Observer {
+	return &windowObserver{Observer: o, w: w, name: name, labels: MetricLabels(labels)}
+}
+
+// add records an observation into the working window
+func (w *histogramWindows) add(name string, labels MetricLabels, value float64) {
+	key := name + "\xff" + seriesKey(labels)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s, ok := w.series[key]
+	if !ok {
+		s = &histogramWindow{name: name, labels: maps.Clone(labels), min: value, max: value}
+		w.series[key] = s
+	}
+
+	s.count++
+	s.sum += value
+	s.min = min(s.min, value)
+	s.max = max(s.max, value)
+
+	// Reservoir sampling keeps every value with equal probability
+	if len(s.samples) < windowSamples {
+		s.samples = append(s.samples, value)
+	} else if i := rand.Uint64N(s.count); i < windowSamples {
+		s.samples[i] = value
+	}
+}
+
+// rotate summarizes and resets the working window
+func (w *histogramWindows) rotate() []HistogramWindow {
+	w.mu.Lock()
+	series := w.series
+	w.series = make(map[string]*histogramWindow, len(series))
+	w.mu.Unlock()
+
+	windows := make([]HistogramWindow, 0, len(series))
+	for _, s := range series {
+		windows = append(windows, s.summary())
+	}
+	return windows
+}
+
+// summary computes the summary of a window
+func (s *histogramWindow) summary() HistogramWindow {
+	slices.Sort(s.samples)
+	// Nearest-rank percentile
+	rank := int(math.Ceil(0.95*float64(len(s.samples)))) - 1
+
+	return HistogramWindow{
+		Name:   s.name,
+		Labels: s.labels,
+		Count:  s.count,
+		Min:    s.min,
+		Max:    s.max,
+		Avg:    s.sum / float64(s.count),
+		P95:    s.samples[max(rank, 0)],
+	}
+}
+
+// windowObserver records observations into a histogram and its window
+type windowObserver struct {
+	prometheus.Observer
+	w      *histogramWindows
+	name   string
+	labels MetricLabels
+}
+
+func (o *windowObserver) Observe(value float64) {
+	o.Observer.Observe(value)
+	o.w.add(o.name, o.labels, value)
+}
+
+func (o *windowObserver) ObserveWithExemplar(value float64, exemplar prometheus.Labels) {
+	observe(o.Observer, value, MetricLabels(exemplar))
+	o.w.add(o.name, o.labels, value)
+}
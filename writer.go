@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// responseWriter counts the bytes of a response, including streamed,
+// flushed and hijacked responses
+type responseWriter struct {
+	gin.ResponseWriter
+
+	written  atomic.Int64
+	streamed atomic.Bool
+
+	// onHijack is called once the connection was hijacked
+	onHijack func()
+}
+
+// newResponseWriter wraps a Gin response writer
+func newResponseWriter(w gin.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w}
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(b)
+	w.written.Add(int64(n))
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	n, err := w.ResponseWriter.WriteString(s)
+	w.written.Add(int64(n))
+	return n, err
+}
+
+// Flush marks the response as streamed
+func (w *responseWriter) Flush() {
+	w.streamed.Store(true)
+	w.ResponseWriter.Flush()
+}
+
+// Hijack marks the response as streamed and counts bytes written to the
+// hijacked connection
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := w.ResponseWriter.Hijack()
+	if err != nil {
+		return conn, buf, err
+	}
+	w.streamed.Store(true)
+
+	counted := &countingConn{Conn: conn, written: &w.written}
+	if buf != nil && buf.Writer.Buffered() == 0 {
+		buf.Writer.Reset(counted)
+	}
+	if w.onHijack != nil {
+		w.onHijack()
+	}
+	return counted, buf, nil
+}
+
+// size returns the number of body bytes written, or -1 if nothing was written
+func (w *responseWriter) size() int64 {
+	if n := w.written.Load(); n > 0 || w.Written() {
+		return n
+	}
+	return -1
+}
+
+// countingConn counts the bytes written to a hijacked connection
+type countingConn struct {
+	net.Conn
+	written *atomic.Int64
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.written.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,97 @@
+package metrics
+
+import "sync"
+
+// WebSocketConnection records the metrics of one connection with its
+// attributes attached, see WebSocketMetrics.ForConnection
+type WebSocketConnection struct {
+	ws    *WebSocketMetrics
+	id    string
+	attrs MetricLabels
+	key   string
+	once  sync.Once
+}
+
+// ForConnection opens a connection and returns a recorder attaching attrs,
+// e.g. the client platform and protocol version, to its message and byte
+// counts, so hub code does not pass them on every call:
+//
+//	conn := ws.ForConnection(id, metrics.MetricLabels{"platform": "ios", "protocol": "v2"})
+//	defer conn.Close()
+//	conn.MessageSent("state")
+//	conn.BytesSent(len(payload))
+//
+// attrs must have few values and the same names for every connection;
+// connID identifies the connection to the hub and is never a label. The
+// open connections per attributes are in websocket_connections_open, whose
+// series is deleted when the last connection with its attributes closes.
+// As a metric needs the same labels everywhere, use either connections or
+// MessageSent and MessageReceived for messages.
+func (ws *WebSocketMetrics) ForConnection(connID string, attrs MetricLabels) *WebSocketConnection {
+	conn := &WebSocketConnection{ws: ws, id: connID, attrs: attrs, key: seriesKey(attrs)}
+
+	// Deleting the series of the last connection must not race with a new one
+	ws.mu.Lock()
+	ws.conns[conn.key]++
+	ws.m.IncrementGauge("websocket_connections_open", attrs)
+	ws.mu.Unlock()
+
+	ws.ConnectionOpened()
+	return conn
+}
+
+// ID returns the connection ID passed to ForConnection
+func (c *WebSocketConnection) ID() string {
+	return c.id
+}
+
+// labels returns the attributes with extra labels added
+func (c *WebSocketConnection) labels(extra MetricLabels) MetricLabels {
+	labels := make(MetricLabels, len(c.attrs)+len(extra))
+	for k, v := range c.attrs {
+		labels[k] = v
+	}
+	for k, v := range extra {
+		labels[k] = v
+	}
+	return labels
+}
+
+// MessageSent increments the messages sent on the connection
+func (c *WebSocketConnection) MessageSent(messageType string) {
+	c.ws.m.IncrementCounter("websocket_messages_sent_total", c.labels(MetricLabels{"type": messageType}))
+}
+
+// MessageReceived increments the messages received on the connection
+func (c *WebSocketConnection) MessageReceived(messageType string) {
+	c.ws.m.IncrementCounter("websocket_messages_received_total", c.labels(MetricLabels{"type": messageType}))
+}
+
+// BytesSent adds to the bytes sent on the connection
+func (c *WebSocketConnection) BytesSent(n int) {
+	c.ws.m.IncrementCounterBy("websocket_bytes_sent_total", float64(n), c.attrs)
+}
+
+// BytesReceived adds to the bytes received on the connection
+func (c *WebSocketConnection) BytesReceived(n int) {
+	c.ws.m.IncrementCounterBy("websocket_bytes_received_total", float64(n), c.attrs)
+}
+
+// Close closes the connection, deleting the open connections series of its
+// attributes if it was the last one. Closing again does nothing.
+func (c *WebSocketConnection) Close() {
+	c.once.Do(func() {
+		ws := c.ws
+		ws.mu.Lock()
+		ws.conns[c.key]--
+		if ws.conns[c.key] == 0 {
+			delete(ws.conns, c.key)
+			ws.m.DeleteSeries("websocket_connections_open", c.attrs)
+		} else {
+			ws.m.DecrementGauge("websocket_connections_open", c.attrs)
+		}
+		ws.mu.Unlock()
+
+		ws.ConnectionClosed()
+	})
+}